@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,8 +22,8 @@ import (
 	"myproject/internal/client"
 	"myproject/internal/store"
 	"myproject/internal/logging"
-	// 新增: Opus 编码依赖
-	"github.com/hraban/opus"
+	"myproject/internal/transport"
+	"myproject/internal/wirelog"
 	"sync"
 	"sync/atomic"
 )
@@ -30,12 +34,39 @@ type App struct {
 	store            *store.DB
 	ctx              context.Context
 	opusDecoder      *audio.OpusDecoder
+	playResampler    *audio.Resampler // 解码输出 → playbackSampleRate，采样率一致时为 nil
+	// 应用内软件音量（作用于播放 PCM，与系统音量无关）与提示音闪避
+	playGain    *audio.GainStage
+	notifyGain  *audio.GainStage // 仅用于计算提示音增益，由前端施加到提示音
+	duckMu      sync.Mutex
+	duckEnabled bool
+	duckDB      float64
 	volumeController *audio.VolumeController
 	// 新增: 录音上行编码器与缓冲
-	micEnc   *opus.Encoder
-	micBuf   []float32
+	micEnc   *audio.OpusEncoder
 	micMu    sync.Mutex
 	micOn    bool
+	micMode  string
+	micVAD   *audio.VAD // 为 nil 时不做语音活动检测
+	vadAutoStop bool
+	micOpts  map[string]any // 最近一次 start_listen 的参数，自动模式重新监听时复用
+	// 麦克风预处理链（编码前），为 nil 时关闭
+	micPre      *audio.Preprocessor
+	micLevelsAt time.Time
+
+	// 插话（barge-in）：speaking 期间检测用户说话并中断播报，bargeIn 为 nil 时关闭
+	bargeMu       sync.Mutex
+	bargeIn       *audio.BargeInDetector
+	bargeInReason string
+	playbackMuted int32 // 插话触发后丢弃下行音频，直到下一次 speaking
+
+	// 下行 TTS 录音，为 nil 时不录制
+	recMu    sync.Mutex
+	recorder *audio.Recorder
+
+	// 线级会话录制（收发的每个帧），为 nil 时不录制
+	wireMu  sync.Mutex
+	wireRec *wirelog.Recorder
 
 	// 新增：并发测试运行控制
 	ltMu            sync.Mutex
@@ -43,6 +74,12 @@ type App struct {
 	ltRunning       bool
 }
 
+// 下发给前端的播放 PCM 采样率，与服务端协商的采样率无关
+const playbackSampleRate = 48000
+
+// 软件音量变化的渐变时长（毫秒）
+const playbackRampMs = 30
+
 // NewApp creates a new App application struct
 func NewApp() *App { return &App{} }
 
@@ -60,6 +97,79 @@ func getStr(m map[string]any, k string) string {
 	return ""
 }
 
+func getFloat(m map[string]any, k string) float64 {
+	switch t := m[k].(type) {
+	case float64:
+		return t
+	case int:
+		return float64(t)
+	case string:
+		f, _ := strconv.ParseFloat(t, 64)
+		return f
+	}
+	return 0
+}
+
+func getBool(m map[string]any, k string) bool {
+	switch t := m[k].(type) {
+	case bool:
+		return t
+	case string:
+		return t == "true" || t == "1" || t == "yes"
+	}
+	return false
+}
+
+// connectedInfo connected 事件内容；sample_rate 为上行音频采样率，前端麦克风按此重采样
+func connectedInfo(c *client.Client, protocol string) map[string]string {
+	return map[string]string{"protocol": protocol, "sample_rate": strconv.Itoa(c.Config().Audio.SampleRate)}
+}
+
+// bindClientEvents 将客户端重连与会话状态事件转发给前端
+func (a *App) bindClientEvents(c *client.Client) {
+	c.OnReconnect = func(ev client.ReconnectEvent) {
+		payload := map[string]any{"state": string(ev.State), "protocol": ev.Protocol, "attempt": ev.Attempt, "delay_ms": ev.Delay.Milliseconds()}
+		if ev.Err != nil { payload["error"] = ev.Err.Error() }
+		runtime.EventsEmit(a.ctx, "reconnect", payload)
+		switch ev.State {
+		case client.ReconnectStateReconnected:
+			info := connectedInfo(c, ev.Protocol)
+			info["reconnected"] = "true"
+			runtime.EventsEmit(a.ctx, "connected", info)
+		case client.ReconnectStateRenewed:
+			info := connectedInfo(c, ev.Protocol)
+			info["renewed"] = "true"
+			runtime.EventsEmit(a.ctx, "connected", info)
+		}
+	}
+	c.OnSessionChanged = func(oldID, newID string) {
+		runtime.EventsEmit(a.ctx, "session_changed", map[string]string{"old": oldID, "new": newID})
+		if r := a.activeRecorder(); r != nil { a.logRecordErr(r.SetSession(newID)) }
+	}
+	c.OnTTS = func(ctx context.Context, msg client.TTSMessage) {
+		r := a.activeRecorder()
+		if r == nil { return }
+		switch msg.State {
+		case "sentence_start":
+			a.logRecordErr(r.SentenceStart(msg.Text))
+		case "stop":
+			a.logRecordErr(r.TTSStop())
+		}
+	}
+	c.OnWire = func(f transport.WireFrame) {
+		a.wireMu.Lock()
+		r := a.wireRec
+		a.wireMu.Unlock()
+		if r != nil { r.Record(f) }
+	}
+	c.OnStateChanged = func(from, to client.DeviceState) {
+		runtime.EventsEmit(a.ctx, "state_changed", map[string]string{"from": string(from), "to": string(to)})
+		a.onStateForBargeIn(from, to)
+		a.onStateForRelisten(from, to)
+		a.onStateForDucking(from, to)
+	}
+}
+
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
@@ -73,13 +183,16 @@ func (a *App) startup(ctx context.Context) {
 
 	// 初始化 Opus 解码器（默认使用更高质量：48kHz 单声道）
 	var err error
-	a.opusDecoder, err = audio.NewOpusDecoder(48000, 1)
+	a.opusDecoder, err = audio.NewOpusDecoder(playbackSampleRate, 1)
 	if err != nil {
 		log.Warn("Opus 解码器初始化失败", "err", err)
 	} else {
 		log.Info("Opus 解码器初始化成功")
 	}
 
+	a.playGain = audio.NewGainStage(playbackSampleRate, 1, playbackRampMs)
+	a.notifyGain = audio.NewGainStage(playbackSampleRate, 1, playbackRampMs)
+
 	// 初始化音量控制器
 	a.volumeController = audio.NewVolumeController()
 	if a.volumeController.IsVolumeSupported() {
@@ -89,30 +202,21 @@ func (a *App) startup(ctx context.Context) {
 	}
 
 	// helper: 根据 hello 的音频参数重建解码器
-	rebuildDecoder := func(ap map[string]any) {
+	rebuildDecoder := func(ap *client.AudioParams) {
 		if ap == nil { return }
-		getInt := func(k string, def int) int {
-			if v, ok := ap[k]; ok {
-				switch t := v.(type) {
-				case float64:
-					return int(t)
-				case int:
-					return t
-				case json.Number:
-					if iv, err := t.Int64(); err == nil { return int(iv) }
-				}
-			}
-			return def
-		}
-		sr := getInt("sample_rate", 48000)
-		ch := getInt("channels", 1)
+		sr := ap.SampleRate
+		ch := ap.Channels
 		if sr <= 0 { sr = 48000 }
 		if ch <= 0 { ch = 1 }
 		// 若与当前不一致则重建
 		if a.opusDecoder == nil || a.opusDecoder.GetSampleRate() != sr || a.opusDecoder.GetChannels() != ch {
 			if dec, err := audio.NewOpusDecoder(sr, ch); err == nil {
 				a.opusDecoder = dec
-				logging.L().With("module", "audio").Info("重建 Opus 解码器", "sample_rate", sr, "channels", ch)
+				a.playResampler = nil
+				if sr != playbackSampleRate {
+					a.playResampler, _ = audio.NewResampler(sr, playbackSampleRate, ch)
+				}
+				logging.L().With("module", "audio").Info("重建 Opus 解码器", "sample_rate", sr, "channels", ch, "playback_rate", playbackSampleRate)
 			} else {
 				logging.L().With("module", "audio").Warn("重建 Opus 解码器失败", "err", err)
 			}
@@ -167,12 +271,13 @@ func (a *App) startup(ctx context.Context) {
 			if v := getStr(kv, "device_id"); v != "" { cfg.DeviceID = v }
 			cfg.AuthToken = getStr(kv, "token")
 			cfg.MQTTKeepAliveSec = 240
+			a.configureBargeIn(kv, cfg.Audio.SampleRate)
+			a.configurePreprocess(kv, cfg.Audio.SampleRate)
 			c := client.New(cfg)
+			a.bindClientEvents(c)
+			// 根据 hello 动态调整解码器
+			c.OnHello = func(ctx context.Context, msg client.HelloResponse) { rebuildDecoder(msg.AudioParams) }
 			c.OnJSON = func(ctx context.Context, msg map[string]any) {
-				// 根据 hello 动态调整解码器
-				if t, _ := msg["type"].(string); t == "hello" {
-					if ap, _ := msg["audio_params"].(map[string]any); ap != nil { rebuildDecoder(ap) }
-				}
 				b, _ := json.Marshal(msg)
 				_ = a.store.SaveMessage(context.Background(), c.SessionID, "in", "json", string(b), time.Now().Unix())
 				runtime.EventsEmit(a.ctx, "text", string(b))
@@ -181,11 +286,12 @@ func (a *App) startup(ctx context.Context) {
 				// 在 Go 端解码 Opus 数据（MQTT 通道）
 				a.handleOpusAudio(data)
 			}
+			c.OnAudioGap = func(ctx context.Context, lost int) { a.handleAudioGap(lost) }
 			c.OnError = func(ctx context.Context, err error) { runtime.EventsEmit(a.ctx, "error", err.Error()) }
 			c.OnClosed = func() { runtime.EventsEmit(a.ctx, "disconnected") }
 			if err := c.OpenMQTT(context.Background()); err == nil {
 				a.client = c
-				runtime.EventsEmit(a.ctx, "connected", map[string]string{"protocol": "mqtt"})
+				runtime.EventsEmit(a.ctx, "connected", connectedInfo(c, "mqtt"))
 			} else {
 				runtime.EventsEmit(a.ctx, "error", err.Error())
 			}
@@ -235,12 +341,14 @@ func (a *App) startup(ctx context.Context) {
 				// 默认开启（与参考项目一致）
 				cfg.EnableToken = true
 			}
+			cfg.Reconnect.Enabled = getBool(kv, "auto_reconnect")
+			a.configureBargeIn(kv, cfg.Audio.SampleRate)
+			a.configurePreprocess(kv, cfg.Audio.SampleRate)
 			c := client.New(cfg)
+			a.bindClientEvents(c)
+			// 根据 hello 动态调整解码器
+			c.OnHello = func(ctx context.Context, msg client.HelloResponse) { rebuildDecoder(msg.AudioParams) }
 			c.OnJSON = func(ctx context.Context, msg map[string]any) {
-				// 根据 hello 动态调整解码器
-				if t, _ := msg["type"].(string); t == "hello" {
-					if ap, _ := msg["audio_params"].(map[string]any); ap != nil { rebuildDecoder(ap) }
-				}
 				b, _ := json.Marshal(msg)
 				_ = a.store.SaveMessage(context.Background(), c.SessionID, "in", "json", string(b), time.Now().Unix())
 				runtime.EventsEmit(a.ctx, "text", string(b))
@@ -249,11 +357,12 @@ func (a *App) startup(ctx context.Context) {
 				// 在 Go 端解码 Opus 数据（WebSocket 通道）
 				a.handleOpusAudio(data)
 			}
+			c.OnAudioGap = func(ctx context.Context, lost int) { a.handleAudioGap(lost) }
 			c.OnError = func(ctx context.Context, err error) { runtime.EventsEmit(a.ctx, "error", err.Error()) }
 			c.OnClosed = func() { runtime.EventsEmit(a.ctx, "disconnected") }
 			if err := c.OpenWebsocket(context.Background()); err == nil {
 				a.client = c
-				runtime.EventsEmit(a.ctx, "connected", map[string]string{"protocol": "ws"})
+				runtime.EventsEmit(a.ctx, "connected", connectedInfo(c, "ws"))
 			} else {
 				runtime.EventsEmit(a.ctx, "error", err.Error())
 			}
@@ -300,12 +409,14 @@ func (a *App) startup(ctx context.Context) {
 				// 仅在传入非空时覆盖默认 DeviceID
 				if v := getStr(kv, "device_id"); v != "" { cfg.DeviceID = v }
 				if tok := getStr(kv, "token"); tok != "" { cfg.AuthToken = tok }
+				cfg.Reconnect.Enabled = getBool(kv, "auto_reconnect")
+				a.configureBargeIn(kv, cfg.Audio.SampleRate)
+				a.configurePreprocess(kv, cfg.Audio.SampleRate)
 				a.client = client.New(cfg)
+				a.bindClientEvents(a.client)
+				// 根据 hello 动态调整解码器
+				a.client.OnHello = func(ctx context.Context, msg client.HelloResponse) { rebuildDecoder(msg.AudioParams) }
 				a.client.OnJSON = func(ctx context.Context, msg map[string]any) {
-					// 根据 hello 动态调整解码器
-					if t, _ := msg["type"].(string); t == "hello" {
-						if ap, _ := msg["audio_params"].(map[string]any); ap != nil { rebuildDecoder(ap) }
-					}
 					b, _ := json.Marshal(msg)
 					_ = a.store.SaveMessage(context.Background(), a.client.SessionID, "in", "json", string(b), time.Now().Unix())
 					runtime.EventsEmit(a.ctx, "text", string(b))
@@ -314,13 +425,14 @@ func (a *App) startup(ctx context.Context) {
 					// 在 Go 端解码 Opus 数据（协议切换）
 				 a.handleOpusAudio(data)
 				}
+				a.client.OnAudioGap = func(ctx context.Context, lost int) { a.handleAudioGap(lost) }
 				a.client.OnError = func(ctx context.Context, err error) { runtime.EventsEmit(a.ctx, "error", err.Error()) }
 				a.client.OnClosed = func() { runtime.EventsEmit(a.ctx, "disconnected") }
 			}
 			if err := a.client.SwitchProtocol(context.Background(), protocol); err != nil {
 				runtime.EventsEmit(a.ctx, "error", err.Error())
 			} else {
-				runtime.EventsEmit(a.ctx, "connected", map[string]string{"protocol": protocol})
+				runtime.EventsEmit(a.ctx, "connected", connectedInfo(a.client, protocol))
 			}
 		}
 	})
@@ -333,35 +445,16 @@ func (a *App) startup(ctx context.Context) {
 		}
 	})
 	// start/stop listen
+	// start_listen 可选参数: {mode: "manual"|"auto", vad: bool, vad_auto_stop: bool,
+	// vad_threshold_db, vad_min_level_db, vad_hangover_ms}；auto 模式默认启用 VAD 并在尾部静音后自动停止，
+	// 播报结束客户端重新监听时再次开启麦克风（listen_started 事件）
 	runtime.EventsOn(ctx, "start_listen", func(args ...interface{}) {
-		if a.client != nil { _ = a.client.SendListenStart(context.Background(), "manual") }
-		// 开启本地麦克风编码
-		a.micMu.Lock()
-		a.micOn = true
-		if a.micEnc == nil {
-			enc, err := opus.NewEncoder(16000, 1, opus.AppVoIP)
-			if err != nil {
-				logging.L().With("module", "audio").Warn("创建 Opus 编码器失败", "err", err)
-			} else {
-				// 可选: 设置比特率与复杂度
-				_ = enc.SetBitrate(24000)
-				_ = enc.SetComplexity(5)
-				a.micEnc = enc
-			}
-		}
-		// 清空缓冲
-		a.micBuf = nil
-		a.micMu.Unlock()
-	})
-	runtime.EventsOn(ctx, "stop_listen", func(args ...interface{}) {
-		if a.client != nil { _ = a.client.SendListenStop(context.Background(), "manual") }
-		// 关闭本地麦克风编码（保留编码器以便复用，清空状态）
-		a.micMu.Lock()
-		a.micOn = false
-		a.micBuf = nil
-		a.micMu.Unlock()
+		var kv map[string]any
+		if len(args) == 1 { kv, _ = args[0].(map[string]any) }
+		a.startMicListen(kv)
 	})
-	// 录音帧（前端 16kHz/单声道/Float32），按 60ms = 960 样本编码
+	runtime.EventsOn(ctx, "stop_listen", func(args ...interface{}) { a.stopMicListen() })
+	// 录音帧（前端单声道 Float32，采样率与 Config.Audio 一致），按协商帧长编码
 	runtime.EventsOn(ctx, "mic_frame", func(args ...interface{}) {
 		if len(args) != 1 { return }
 		var samples []float32
@@ -487,37 +580,241 @@ func (a *App) startup(ctx context.Context) {
 	})
 }
 
-// 将前端录音帧编码为 Opus 并上行
+// shutdown 应用退出前收尾：结束录音文件，避免留下不完整的 Ogg/WAV
+func (a *App) shutdown(ctx context.Context) {
+	a.logRecordErr(a.StopRecording())
+	a.logRecordErr(a.StopWireCapture())
+}
+
+// newMicEncoder 按连接协商的音频参数与编码配置创建上行编码器
+func newMicEncoder(cfg client.Config) (*audio.OpusEncoder, error) {
+	return audio.NewOpusEncoder(audio.EncoderConfig{
+		SampleRate:     cfg.Audio.SampleRate,
+		Channels:       cfg.Audio.Channels,
+		FrameDuration:  cfg.Audio.FrameDuration,
+		Bitrate:        cfg.Encoder.Bitrate,
+		Complexity:     cfg.Encoder.Complexity,
+		DTX:            cfg.Encoder.DTX,
+		InBandFEC:      cfg.Encoder.InBandFEC,
+		PacketLossPerc: cfg.Encoder.PacketLossPerc,
+	})
+}
+
+// startMicListen 发送 listen start 并开启本地麦克风
+func (a *App) startMicListen(kv map[string]any) {
+	mode := getStr(kv, "mode")
+	if mode != "auto" { mode = "manual" }
+	if a.client != nil { _ = a.client.SendListenStart(context.Background(), mode) }
+	a.armMic(kv)
+}
+
+// armMic 按当前连接的音频参数开启本地麦克风编码（及可选的 VAD），不发送 listen 消息
+func (a *App) armMic(kv map[string]any) {
+	mode := getStr(kv, "mode")
+	if mode != "auto" { mode = "manual" }
+	useVAD := mode == "auto" || getBool(kv, "vad")
+	autoStop := mode == "auto"
+	if _, ok := kv["vad_auto_stop"]; ok { autoStop = getBool(kv, "vad_auto_stop") }
+
+	a.micMu.Lock()
+	defer a.micMu.Unlock()
+	a.micOn = true
+	a.micMode = mode
+	a.micOpts = kv
+	a.micEnc = nil
+	a.micVAD = nil
+	a.vadAutoStop = false
+	if a.client == nil { return }
+	cfg := a.client.Config()
+	enc, err := newMicEncoder(cfg)
+	if err != nil {
+		logging.L().With("module", "audio").Warn("创建 Opus 编码器失败", "err", err)
+	} else {
+		a.micEnc = enc
+	}
+	if useVAD {
+		vc := audio.DefaultVADConfig(cfg.Audio.SampleRate)
+		if v := getFloat(kv, "vad_threshold_db"); v > 0 { vc.ThresholdDB = v }
+		if v := getFloat(kv, "vad_min_level_db"); v < 0 { vc.MinLevelDB = v }
+		if v := getFloat(kv, "vad_hangover_ms"); v > 0 { vc.HangoverMs = int(v) }
+		a.micVAD = audio.NewVAD(vc)
+		a.vadAutoStop = autoStop
+	}
+}
+
+// onStateForRelisten 自动模式下播报结束后客户端已重新发送 listen start，
+// 若麦克风此前被 VAD 自动停止则按上次参数重新开启，并通知前端恢复采集
+func (a *App) onStateForRelisten(from, to client.DeviceState) {
+	if from != client.StateSpeaking || to != client.StateListening {
+		return
+	}
+	a.micMu.Lock()
+	on, opts := a.micOn, a.micOpts
+	a.micMu.Unlock()
+	if on || getStr(opts, "mode") != "auto" {
+		return
+	}
+	a.armMic(opts)
+	runtime.EventsEmit(a.ctx, "listen_started", map[string]string{"reason": "auto"})
+}
+
+// stopMicListen 先发送缓冲中不足一帧的尾音，再发送 listen stop
+func (a *App) stopMicListen() {
+	a.micMu.Lock()
+	if !a.micOn {
+		a.micMu.Unlock()
+		return
+	}
+	a.micOn = false
+	a.micVAD = nil
+	mode := a.micMode
+	var tail []byte
+	if a.micEnc != nil {
+		var err error
+		if tail, err = a.micEnc.Flush(); err != nil {
+			logging.L().With("module", "audio").Warn("Opus 编码失败", "err", err)
+		}
+	}
+	a.micMu.Unlock()
+	if a.client != nil {
+		if len(tail) > 0 { _ = a.client.SendOpusUpstream(context.Background(), tail) }
+		_ = a.client.SendListenStop(context.Background(), mode)
+	}
+}
+
+// configureBargeIn 按连接参数开启/关闭插话检测：barge_in, barge_in_reason,
+// barge_in_margin_db, barge_in_min_level_db, barge_in_sustain_ms
+func (a *App) configureBargeIn(kv map[string]any, sampleRate int) {
+	a.bargeMu.Lock()
+	defer a.bargeMu.Unlock()
+	if !getBool(kv, "barge_in") {
+		a.bargeIn = nil
+		return
+	}
+	bc := audio.DefaultBargeInConfig(sampleRate)
+	bc.PlaybackRate = playbackSampleRate
+	if v := getFloat(kv, "barge_in_margin_db"); v > 0 { bc.MarginDB = v }
+	if v := getFloat(kv, "barge_in_min_level_db"); v < 0 { bc.MinLevelDB = v }
+	if v := getFloat(kv, "barge_in_sustain_ms"); v > 0 { bc.SustainMs = int(v) }
+	a.bargeIn = audio.NewBargeInDetector(bc)
+	a.bargeInReason = client.AbortReasonWakeWord
+	if getStr(kv, "barge_in_reason") == client.AbortReasonUserInterrupt { a.bargeInReason = client.AbortReasonUserInterrupt }
+}
+
+// configurePreprocess 按连接参数配置麦克风预处理：mic_preprocess=true 开启（默认关闭）；
+// mic_highpass_hz, mic_agc, mic_agc_target_db, mic_gate, mic_gate_db, mic_limiter 覆盖默认值
+func (a *App) configurePreprocess(kv map[string]any, sampleRate int) {
+	a.micMu.Lock()
+	defer a.micMu.Unlock()
+	if !getBool(kv, "mic_preprocess") {
+		a.micPre = nil
+		return
+	}
+	pc := audio.DefaultPreprocessConfig(sampleRate)
+	if _, ok := kv["mic_highpass_hz"]; ok { pc.HighPassHz = getFloat(kv, "mic_highpass_hz") }
+	if _, ok := kv["mic_agc"]; ok { pc.AGC = getBool(kv, "mic_agc") }
+	if v := getFloat(kv, "mic_agc_target_db"); v < 0 { pc.AGCTargetDB = v }
+	if _, ok := kv["mic_gate"]; ok { pc.Gate = getBool(kv, "mic_gate") }
+	if v := getFloat(kv, "mic_gate_db"); v < 0 { pc.GateThreshold = v }
+	if _, ok := kv["mic_limiter"]; ok { pc.Limiter = getBool(kv, "mic_limiter") }
+	a.micPre = audio.NewPreprocessor(pc)
+}
+
+// emitMicLevels 向前端推送各级输入电平（限频 10Hz），调用方持有 micMu
+func (a *App) emitMicLevels(lv audio.StageLevels) {
+	if time.Since(a.micLevelsAt) < 100*time.Millisecond {
+		return
+	}
+	a.micLevelsAt = time.Now()
+	runtime.EventsEmit(a.ctx, "mic_levels", map[string]any{
+		"input_db": lv.InputDB, "highpass_db": lv.HighPassDB, "agc_db": lv.AGCDB, "gate_db": lv.GateDB,
+		"output_db": lv.OutputDB, "peak_db": lv.PeakDB, "gain_db": lv.GainDB, "gate_open": lv.GateOpen, "limited": lv.Limited,
+	})
+}
+
+func (a *App) bargeInDetector() *audio.BargeInDetector {
+	a.bargeMu.Lock()
+	defer a.bargeMu.Unlock()
+	return a.bargeIn
+}
+
+// onStateForBargeIn 进入 speaking 时布防（前端需保持麦克风采集），离开时撤防
+func (a *App) onStateForBargeIn(from, to client.DeviceState) {
+	if to == client.StateSpeaking {
+		atomic.StoreInt32(&a.playbackMuted, 0)
+	}
+	d := a.bargeInDetector()
+	if d == nil {
+		return
+	}
+	switch {
+	case to == client.StateSpeaking:
+		d.Reset()
+		runtime.EventsEmit(a.ctx, "barge_in", map[string]string{"state": "armed"})
+	case from == client.StateSpeaking:
+		runtime.EventsEmit(a.ctx, "barge_in", map[string]string{"state": "disarmed"})
+	}
+}
+
+// triggerBargeIn 中断播报：丢弃待播放音频、发送 abort 并切换到自动监听
+func (a *App) triggerBargeIn() {
+	log := logging.L().With("module", "audio")
+	atomic.StoreInt32(&a.playbackMuted, 1)
+	if a.playResampler != nil { a.playResampler.Reset() }
+	runtime.EventsEmit(a.ctx, "playback_flush")
+	runtime.EventsEmit(a.ctx, "barge_in", map[string]string{"state": "triggered"})
+	if a.client == nil { return }
+	if err := a.client.SendAbort(context.Background(), a.bargeInReason); err != nil {
+		log.Warn("插话中断失败", "err", err)
+		return
+	}
+	log.Info("检测到插话，已中断播报", "reason", a.bargeInReason)
+	a.startMicListen(map[string]any{"mode": "auto"})
+}
+
+// 将前端录音帧编码为 Opus 并上行；启用 VAD 时同时检测说话起止
 func (a *App) encodeAndSendMic(samples []float32) {
+	// 播报期间仅做插话检测，不上行
+	if d := a.bargeInDetector(); d != nil && a.client != nil && a.client.State() == client.StateSpeaking {
+		if d.Process(samples) { a.triggerBargeIn() }
+		return
+	}
+
 	a.micMu.Lock()
 	enc := a.micEnc
 	active := a.micOn
-	// 追加样本
-	if len(samples) > 0 {
-		a.micBuf = append(a.micBuf, samples...)
-	}
-	// 每帧 60ms@16kHz = 960 样本
-	const frameSize = 960
-	var frames [][]float32
-	for active && len(a.micBuf) >= frameSize {
-		frame := make([]float32, frameSize)
-		copy(frame, a.micBuf[:frameSize])
-		a.micBuf = a.micBuf[frameSize:]
-		frames = append(frames, frame)
+	autoStop := a.vadAutoStop
+	var packets [][]byte
+	var events []audio.VADEvent
+	var err error
+	if active && a.micPre != nil {
+		var lv audio.StageLevels
+		samples, lv = a.micPre.Process(samples)
+		a.emitMicLevels(lv)
+	}
+	if active && enc != nil {
+		packets, err = enc.Write(samples)
+	}
+	if active && a.micVAD != nil {
+		events = a.micVAD.Process(samples)
 	}
 	a.micMu.Unlock()
 
-	if !active || enc == nil || a.client == nil { return }
-	for _, f := range frames {
-		// 编码
-		out := make([]byte, 4000)
-		n, err := enc.EncodeFloat32(f, out)
-		if err != nil {
-			logging.L().With("module", "audio").Warn("Opus 编码失败", "err", err)
-			continue
+	if err != nil {
+		logging.L().With("module", "audio").Warn("Opus 编码失败", "err", err)
+	}
+	if !active || a.client == nil { return }
+	for _, p := range packets {
+		_ = a.client.SendOpusUpstream(context.Background(), p)
+	}
+	for _, ev := range events {
+		runtime.EventsEmit(a.ctx, "vad", map[string]string{"state": ev.String()})
+		if ev == audio.VADSpeechEnd && autoStop {
+			logging.L().With("module", "audio").Info("检测到说话结束，自动停止监听")
+			a.stopMicListen()
+			runtime.EventsEmit(a.ctx, "listen_stopped", map[string]string{"reason": "vad"})
+			return
 		}
-		if n <= 0 { continue }
-		_ = a.client.SendOpusUpstream(context.Background(), out[:n])
 	}
 }
 
@@ -537,6 +834,31 @@ func (a *App) SendTextMessage(text string) error {
 	return nil
 }
 
+// SpeakAudioFile 以录音文件（WAV/Ogg-Opus）代替麦克风上行一轮语音；fast 为 true 时不按实时节奏发送。
+// 本轮结束后发出 "speak_file_done" 事件
+func (a *App) SpeakAudioFile(path string, fast bool) error {
+	if a.client == nil {
+		return errors.New("未连接")
+	}
+	c := a.client
+	turn, err := c.SpeakSource(context.Background(), audio.FileSource{Path: path}, client.SpeakOptions{Fast: fast})
+	if err != nil {
+		return err
+	}
+	go func() {
+		res, err := turn.Wait(context.Background())
+		done := map[string]any{"path": path}
+		if err != nil {
+			done["error"] = err.Error()
+		} else {
+			done["stt"] = res.STT
+			done["first_event_ms"] = res.FirstEvent.Milliseconds()
+		}
+		runtime.EventsEmit(a.ctx, "speak_file_done", done)
+	}()
+	return nil
+}
+
 // GetWindowState 获取当前窗口状态
 func (a *App) GetWindowState() string {
 	if a.ctx == nil {
@@ -674,10 +996,20 @@ func (a *App) handleOpusAudio(opusData []byte) {
 		return
 	}
 
+	rec := a.activeRecorder()
+	if rec != nil {
+		a.logRecordErr(rec.SetSession(a.client.GetSessionID()))
+		a.logRecordErr(rec.WriteOpus(opusData, a.opusDecoder.GetSampleRate(), a.opusDecoder.GetChannels()))
+	}
+
+	if atomic.LoadInt32(&a.playbackMuted) == 1 {
+		log.Debug("插话后丢弃下行音频", "len", len(opusData))
+		return
+	}
 	log.Debug("收到 Opus 数据", "len", len(opusData))
 
-	// 使用 Go 端的 Opus 解码器解码
-	pcmData, err := a.opusDecoder.DecodeFrameToFloat32(opusData)
+	// 使用 Go 端的 Opus 解码器解码（含丢帧补偿）
+	pcmData, err := a.opusDecoder.DecodeFrameWithRecovery(opusData)
 	if err != nil {
 		log.Warn("Go Opus 解码失败，回退发送原始数据", "len", len(opusData), "err", err)
 		runtime.EventsEmit(a.ctx, "audio", opusData)
@@ -685,11 +1017,139 @@ func (a *App) handleOpusAudio(opusData []byte) {
 	}
 
 	log.Debug("Go Opus 解码成功", "opus_bytes", len(opusData), "samples", len(pcmData))
+	if rec != nil {
+		a.logRecordErr(rec.WritePCM(pcmData, a.opusDecoder.GetSampleRate(), a.opusDecoder.GetChannels()))
+	}
+
+	// 统一重采样到播放采样率
+	if a.playResampler != nil {
+		pcmData = a.playResampler.Process(pcmData)
+	}
+	// 先施加应用内增益，插话检测的回声参考须与扬声器实际播放的电平一致
+	if a.playGain != nil {
+		a.playGain.Process(pcmData)
+	}
+	if d := a.bargeInDetector(); d != nil {
+		d.ObservePlayback(pcmData)
+	}
 
 	// 发送解码后的 PCM 数据给前端
 	runtime.EventsEmit(a.ctx, "audio_pcm", pcmData)
 }
 
+// StartRecording 开始录制下行 TTS 音频。format: ogg（原样保存 Opus 包）| wav（解码后的 PCM）；
+// split: session | sentence。文件写入 recordings/<session_id>/，并记录到 SQLite
+func (a *App) StartRecording(format, split string) error {
+	r, err := audio.NewRecorder(audio.RecorderConfig{Dir: "recordings", Format: format, Split: split})
+	if err != nil {
+		return err
+	}
+	r.OnSaved = func(rec audio.Recording) {
+		row := store.Recording{
+			SessionID: rec.SessionID, Sentence: rec.Sentence, Path: rec.Path, Format: rec.Format,
+			SampleRate: rec.SampleRate, Channels: rec.Channels, Frames: rec.Frames,
+			DurationMs: rec.Duration.Milliseconds(), CreatedAt: rec.StartedAt.Unix(),
+		}
+		if err := a.store.SaveRecording(context.Background(), row); err != nil {
+			logging.L().With("module", "recorder").Warn("保存录音记录失败", "path", rec.Path, "err", err)
+		}
+		runtime.EventsEmit(a.ctx, "recording_saved", row)
+	}
+	a.recMu.Lock()
+	old := a.recorder
+	a.recorder = r
+	a.recMu.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// StopRecording 停止录制并关闭当前文件
+func (a *App) StopRecording() error {
+	a.recMu.Lock()
+	r := a.recorder
+	a.recorder = nil
+	a.recMu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Close()
+}
+
+// IsRecording 是否正在录制下行音频
+func (a *App) IsRecording() bool { return a.activeRecorder() != nil }
+
+// ListRecordings 列出录音文件；sessionID 为空时列出全部
+func (a *App) ListRecordings(sessionID string, limit int) ([]store.Recording, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return a.store.Recordings(context.Background(), sessionID, limit)
+}
+
+// StartWireCapture 开始线级会话录制，供 wirelog 回放复现问题；path 为空时写入 captures 目录，返回文件路径
+func (a *App) StartWireCapture(path string) (string, error) {
+	if path == "" {
+		if err := os.MkdirAll("captures", 0o755); err != nil { return "", err }
+		path = filepath.Join("captures", time.Now().Format("20060102-150405")+".jsonl")
+	}
+	// 记录连接参数便于回放时对照，不含 token/密码
+	meta := map[string]string{}
+	if a.client != nil {
+		cfg := a.client.Config()
+		meta["websocket_url"], meta["mqtt_broker"] = cfg.WebsocketURL, cfg.MQTTBroker
+		meta["client_id"], meta["device_id"] = cfg.ClientID, cfg.DeviceID
+		meta["protocol_version"] = strconv.Itoa(cfg.ProtocolVersion)
+	}
+	r, err := wirelog.Create(path, meta)
+	if err != nil { return "", err }
+	a.wireMu.Lock()
+	old := a.wireRec
+	a.wireRec = r
+	a.wireMu.Unlock()
+	if old != nil { a.logRecordErr(old.Close()) }
+	return path, nil
+}
+
+// StopWireCapture 结束线级录制并关闭文件
+func (a *App) StopWireCapture() error {
+	a.wireMu.Lock()
+	r := a.wireRec
+	a.wireRec = nil
+	a.wireMu.Unlock()
+	if r == nil { return nil }
+	return r.Close()
+}
+
+// IsWireCapturing 是否正在线级录制
+func (a *App) IsWireCapturing() bool {
+	a.wireMu.Lock()
+	defer a.wireMu.Unlock()
+	return a.wireRec != nil
+}
+
+func (a *App) activeRecorder() *audio.Recorder {
+	a.recMu.Lock()
+	defer a.recMu.Unlock()
+	return a.recorder
+}
+
+func (a *App) logRecordErr(err error) {
+	if err != nil {
+		logging.L().With("module", "recorder").Warn("录音失败", "err", err)
+	}
+}
+
+// handleAudioGap 下行丢帧时记录到解码器，下一帧到达时用 FEC/PLC 补偿，避免爆音
+func (a *App) handleAudioGap(lost int) {
+	if a.opusDecoder == nil {
+		return
+	}
+	logging.L().With("module", "audio").Debug("下行丢帧", "lost", lost)
+	a.opusDecoder.MarkLost(lost)
+}
+
 // GetSystemVolume 获取系统音量 (0.0 - 1.0)
 func (a *App) GetSystemVolume() float64 {
 	if a.volumeController == nil {
@@ -721,6 +1181,91 @@ func (a *App) SetSystemVolume(volume float64) error {
 	return nil
 }
 
+// GetPlaybackVolume 获取应用内播放音量 (0.0 - 4.0，1.0 为原始电平)
+func (a *App) GetPlaybackVolume() float64 {
+	if a.playGain == nil {
+		return 1.0
+	}
+	return a.playGain.Volume()
+}
+
+// SetPlaybackVolume 设置应用内播放音量，平滑渐变，不影响系统音量
+func (a *App) SetPlaybackVolume(volume float64) error {
+	if a.playGain == nil {
+		return fmt.Errorf("播放增益未初始化")
+	}
+	a.playGain.SetVolume(volume)
+	logging.L().With("module", "audio").Info("播放音量设置", "volume", volume)
+	return nil
+}
+
+// SetPlaybackMuted 静音/取消静音（应用内）
+func (a *App) SetPlaybackMuted(muted bool) error {
+	if a.playGain == nil {
+		return fmt.Errorf("播放增益未初始化")
+	}
+	a.playGain.SetMuted(muted)
+	a.notifyGain.SetMuted(muted)
+	a.emitNotificationGain()
+	logging.L().With("module", "audio").Info("播放静音", "muted", muted)
+	return nil
+}
+
+// IsPlaybackMuted 是否已静音（应用内）
+func (a *App) IsPlaybackMuted() bool {
+	return a.playGain != nil && a.playGain.Muted()
+}
+
+// SetDucking 开启/关闭 TTS 播报期间的提示音闪避，duckDB 为衰减量（如 -12）
+func (a *App) SetDucking(enabled bool, duckDB float64) error {
+	if duckDB > 0 {
+		return fmt.Errorf("闪避衰减量需 <= 0 dB")
+	}
+	a.duckMu.Lock()
+	a.duckEnabled, a.duckDB = enabled, duckDB
+	a.duckMu.Unlock()
+	speaking := a.client != nil && a.client.State() == client.StateSpeaking
+	a.applyDucking(speaking)
+	return nil
+}
+
+// GetNotificationGain 获取提示音当前应施加的增益（含静音与闪避）
+func (a *App) GetNotificationGain() float64 {
+	if a.notifyGain == nil {
+		return 1.0
+	}
+	return a.notifyGain.Target()
+}
+
+// onStateForDucking 进入 speaking 时闪避提示音，离开时恢复
+func (a *App) onStateForDucking(from, to client.DeviceState) {
+	if to == client.StateSpeaking || from == client.StateSpeaking {
+		a.applyDucking(to == client.StateSpeaking)
+	}
+}
+
+func (a *App) applyDucking(speaking bool) {
+	if a.notifyGain == nil {
+		return
+	}
+	a.duckMu.Lock()
+	db := 0.0
+	if a.duckEnabled && speaking {
+		db = a.duckDB
+	}
+	a.duckMu.Unlock()
+	a.notifyGain.SetDuck(db)
+	a.emitNotificationGain()
+}
+
+// emitNotificationGain 通知前端提示音增益变化
+func (a *App) emitNotificationGain() {
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, "notification_gain", map[string]any{"gain": a.notifyGain.Target()})
+}
+
 // IsSystemVolumeSupported 检查是否支持系统音量控制
 func (a *App) IsSystemVolumeSupported() bool {
 	if a.volumeController == nil {
@@ -932,19 +1477,9 @@ func (a *App) startLoadTest(payload map[string]any) {
 				cfgW.ClientID = fmt.Sprintf("loadtest-%d-%d", time.Now().UnixNano(), worker)
 			}
 			c := client.New(cfgW)
-			respCh := make(chan struct{}, 16)
 			c.OnBinary = func(ctx context.Context, data []byte) {}
 			c.OnClosed = func() { atomic.AddInt64(&closedCnt, 1) }
 			c.OnError = func(ctx context.Context, err error) { atomic.AddInt64(&errCnt, 1) }
-			c.OnJSON = func(ctx context.Context, msg map[string]any) {
-				if t, ok := msg["type"].(string); ok {
-					if t == "hello" { return }
-					select { case respCh <- struct{}{}: default: }
-					return
-				}
-				if _, ok := msg["text"]; ok { select { case respCh <- struct{}{}: default: }; return }
-				if _, ok := msg["content"]; ok { select { case respCh <- struct{}{}: default: }; return }
-			}
 
 			// 连接
 			t0 := time.Now()
@@ -958,22 +1493,28 @@ func (a *App) startLoadTest(payload map[string]any) {
 			// 请求循环
 			for j := 0; j < perConn; j++ {
 				select { case <-ctx2.Done(): return; default: }
-				// 清空旧响应
-				for { select { case <-respCh: continue; default: break }
-					break }
-				t1 := time.Now()
-				_ = c.SendListenStart(context.Background(), "ptt")
-				_ = c.SendDetectText(context.Background(), fmt.Sprintf("%s #%d.%d", message, worker, j))
-				select {
-				case <-respCh:
-					muResp.Lock(); resps = append(resps, float64(time.Since(t1).Milliseconds())); muResp.Unlock()
+				// 每个请求为一轮对话：tts stop 结束，响应延迟取首个 stt/llm/tts/音频事件
+				reqCtx, reqCancel := context.WithTimeout(ctx2, respTO)
+				turn, err := c.Ask(reqCtx, fmt.Sprintf("%s #%d.%d", message, worker, j))
+				if err != nil {
+					reqCancel()
+					atomic.AddInt64(&errCnt, 1)
+					atomic.AddInt64(&doneReq, 1)
+					continue
+				}
+				res, err := turn.Wait(context.Background())
+				reqCancel()
+				switch {
+				case err == nil:
+					muResp.Lock(); resps = append(resps, float64(res.FirstEvent.Milliseconds())); muResp.Unlock()
 					atomic.AddInt64(&reqOK, 1)
-				case <-time.After(respTO):
-					atomic.AddInt64(&reqTO, 1)
-				case <-ctx2.Done():
+				case ctx2.Err() != nil:
 					return
+				case errors.Is(err, context.DeadlineExceeded):
+					atomic.AddInt64(&reqTO, 1)
+				default:
+					atomic.AddInt64(&errCnt, 1)
 				}
-				_ = c.SendListenStop(context.Background(), "ptt")
 				atomic.AddInt64(&doneReq, 1)
 			}
 			c.Close()
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "myproject/internal/chaosproxy"
+    "myproject/internal/logging"
+)
+
+const usage = `Usage: chaosproxy [flags]
+
+Sits between a client and a xiaozhi server and injects latency, jitter, packet
+loss, duplication, reordering, bandwidth caps and forced disconnects.
+
+  WebSocket: point the client at the printed URL; every message is forwarded to
+             -ws-target with its own delay. Loss/dup/reorder do not apply (TCP).
+  UDP:       datagrams sent to -udp-listen are forwarded to -udp-target. For
+             MQTT+UDP the server announces its UDP address in the hello, so the
+             server must be configured to announce the proxy address instead.
+
+Press Enter to force-disconnect every connection.
+
+Flags:
+`
+
+func main() {
+    wsListen := flag.String("ws-listen", "127.0.0.1:9000", "WebSocket listen address")
+    wsTarget := flag.String("ws-target", "", "Upstream WebSocket URL, e.g. ws://host:8000/xiaozhi/v1/ (empty disables)")
+    udpListen := flag.String("udp-listen", "127.0.0.1:9001", "UDP listen address")
+    udpTarget := flag.String("udp-target", "", "Upstream UDP host:port (empty disables)")
+
+    var f chaosproxy.Faults
+    flag.DurationVar(&f.Latency, "latency", 0, "One-way latency")
+    flag.DurationVar(&f.Jitter, "jitter", 0, "Uniform jitter added to latency (+/-)")
+    flag.Float64Var(&f.Loss, "loss", 0, "UDP loss probability 0..1")
+    flag.Float64Var(&f.Duplicate, "dup", 0, "UDP duplication probability 0..1")
+    flag.Float64Var(&f.Reorder, "reorder", 0, "UDP reorder probability 0..1")
+    flag.DurationVar(&f.ReorderDelay, "reorder-delay", 40*time.Millisecond, "Extra delay for reordered UDP packets")
+    flag.IntVar(&f.Bandwidth, "bandwidth", 0, "Bandwidth cap in bytes/second (0 = unlimited)")
+    direction := flag.String("direction", "both", "Apply faults to: both|up|down")
+    disconnectAfter := flag.Duration("disconnect-after", 0, "Cut each connection/UDP mapping after this long (0 = never)")
+    seed := flag.Int64("seed", 0, "Random seed (0 = time based)")
+    statsEvery := flag.Duration("stats", 5*time.Second, "Print stats at this interval (0 = off)")
+    logLevel := flag.String("log-level", "info", "Log level: debug|info|warn|error")
+    flag.Usage = func() {
+        fmt.Fprint(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    logging.Init(*logLevel)
+
+    cfg := chaosproxy.Config{DisconnectAfter: *disconnectAfter, Seed: *seed}
+    switch *direction {
+    case "both":
+        cfg.Up, cfg.Down = f, f
+    case "up":
+        cfg.Up = f
+    case "down":
+        cfg.Down = f
+    default:
+        fmt.Fprintf(os.Stderr, "bad -direction %q\n", *direction)
+        os.Exit(2)
+    }
+    if *wsTarget == "" && *udpTarget == "" {
+        fmt.Fprintln(os.Stderr, "at least one of -ws-target or -udp-target is required")
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    var ws *chaosproxy.WSProxy
+    var udp *chaosproxy.UDPProxy
+    if *wsTarget != "" {
+        ws = chaosproxy.NewWS(*wsTarget, cfg)
+        if err := ws.Start(*wsListen); err != nil {
+            fmt.Fprintln(os.Stderr, "start websocket proxy:", err)
+            os.Exit(1)
+        }
+        defer ws.Close()
+        fmt.Printf("WebSocket: %s -> %s\n", ws.URL(), *wsTarget)
+    }
+    if *udpTarget != "" {
+        udp = chaosproxy.NewUDP(cfg)
+        addr, err := udp.Listen(*udpListen, *udpTarget)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "start udp proxy:", err)
+            os.Exit(1)
+        }
+        defer udp.Close()
+        fmt.Printf("UDP:       %s -> %s\n", addr, *udpTarget)
+    }
+
+    printStats := func() {
+        if ws != nil { fmt.Printf("ws  %+v\n", ws.Stats()) }
+        if udp != nil { fmt.Printf("udp %+v\n", udp.Stats()) }
+    }
+    go func() {
+        sc := bufio.NewScanner(os.Stdin)
+        for sc.Scan() {
+            n := 0
+            if ws != nil { n += ws.Disconnect() }
+            if udp != nil { n += udp.Disconnect() }
+            fmt.Printf("Disconnected %d connection(s)\n", n)
+        }
+    }()
+    var tick <-chan time.Time
+    if *statsEvery > 0 {
+        t := time.NewTicker(*statsEvery)
+        defer t.Stop()
+        tick = t.C
+    }
+    sig := make(chan os.Signal, 1)
+    signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+    for {
+        select {
+        case <-tick:
+            printStats()
+        case <-sig:
+            printStats()
+            return
+        }
+    }
+}
@@ -3,6 +3,7 @@ package main
 import (
     "context"
     "encoding/json"
+    "errors"
     "flag"
     "fmt"
     "math"
@@ -13,8 +14,10 @@ import (
     "sync/atomic"
     "time"
 
+    "myproject/internal/audio"
     "myproject/internal/client"
     "myproject/internal/logging"
+    "myproject/internal/mockserver"
 )
 
 type summary struct {
@@ -76,6 +79,8 @@ func main() {
         protocol   = flag.String("protocol", "ws", "Protocol: ws|mqtt")
         // WebSocket
         wsURL      = flag.String("ws", "", "WebSocket URL (e.g., ws://127.0.0.1:8000)")
+        mock       = flag.Bool("mock", false, "Run against an in-process mock server (protocol=ws, overrides -ws)")
+        mockRT     = flag.Bool("mock-realtime", false, "With -mock: pace TTS audio at real-time frame duration")
         // MQTT
         mqttBroker = flag.String("broker", "", "MQTT broker URL (e.g., ssl://host:8883)")
         mqttUser   = flag.String("username", "", "MQTT username")
@@ -94,6 +99,8 @@ func main() {
         conc       = flag.Int("c", 10, "Concurrency (number of connections)")
         perConn    = flag.Int("n", 10, "Requests per connection")
         message    = flag.String("message", "hello", "Text to send for each request")
+        audioFile  = flag.String("audio-file", "", "WAV/Ogg-Opus file streamed as upstream audio instead of -message")
+        fast       = flag.Bool("fast", false, "With -audio-file: send frames without real-time pacing")
         helloTO    = flag.Duration("hello-timeout", 10*time.Second, "Hello wait timeout")
         respTO     = flag.Duration("resp-timeout", 10*time.Second, "Response wait timeout per request")
         jsonOut    = flag.Bool("json", false, "Output JSON summary")
@@ -112,6 +119,16 @@ func main() {
     if *clientID != "" { cfg.ClientID = *clientID }
     if *deviceID != "" { cfg.DeviceID = strings.ToLower(*deviceID) }
 
+    if *mock {
+        srv := mockserver.New(mockserver.Config{Token: *token, Realtime: *mockRT})
+        if err := srv.Start("127.0.0.1:0"); err != nil {
+            fmt.Fprintf(os.Stderr, "start mock server: %v\n", err)
+            os.Exit(2)
+        }
+        defer srv.Close()
+        *protocol, *wsURL = "ws", srv.URL()
+    }
+
     switch strings.ToLower(*protocol) {
     case "ws", "websocket":
         if *wsURL == "" {
@@ -135,6 +152,21 @@ func main() {
         os.Exit(2)
     }
 
+    // Encode the audio file once; all workers stream the same frames
+    var frames [][]byte
+    if *audioFile != "" {
+        var err error
+        frames, err = audio.FileSource{Path: *audioFile}.OpusFrames(cfg.Audio.SampleRate, cfg.Audio.Channels, cfg.Audio.FrameDuration)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "load audio file: %v\n", err)
+            os.Exit(2)
+        }
+        if len(frames) == 0 {
+            fmt.Fprintln(os.Stderr, "audio file contains no audio")
+            os.Exit(2)
+        }
+    }
+
     totalReq := (*conc) * (*perConn)
 
     var (
@@ -163,32 +195,10 @@ func main() {
             }
 
             c := client.New(cfgW)
-            // channel to receive first response after each detect
-            respCh := make(chan struct{}, 16)
             // ignore binary
             c.OnBinary = func(ctx context.Context, data []byte) {}
             c.OnClosed = func() { atomic.AddInt64(&closedCnt, 1) }
             c.OnError = func(ctx context.Context, err error) { atomic.AddInt64(&errCnt, 1) }
-            c.OnJSON = func(ctx context.Context, msg map[string]any) {
-                // classify hello vs others
-                if t, ok := msg["type"].(string); ok {
-                    if t == "hello" {
-                        return
-                    }
-                    // tts or any non-hello counts as a response
-                    select { case respCh <- struct{}{}: default: }
-                    return
-                }
-                // also consider payloads with text/content fields
-                if _, ok := msg["text"]; ok {
-                    select { case respCh <- struct{}{}: default: }
-                    return
-                }
-                if _, ok := msg["content"]; ok {
-                    select { case respCh <- struct{}{}: default: }
-                    return
-                }
-            }
 
             // connect
             ctx, cancel := context.WithTimeout(context.Background(), cfgW.HelloTimeout)
@@ -204,28 +214,31 @@ func main() {
 
             // requests
             for j := 0; j < *perConn; j++ {
-                // drain stale responses
-                for {
-                    select { case <-respCh: continue; default: }
-                    break
+                // one turn per request: completes on tts stop, latency = first stt/llm/tts/audio event
+                reqCtx, reqCancel := context.WithTimeout(context.Background(), *respTO)
+                var turn *client.Turn
+                var err error
+                if frames != nil {
+                    turn, err = c.SpeakWithOptions(reqCtx, frames, client.SpeakOptions{Fast: *fast})
+                } else {
+                    turn, err = c.Ask(reqCtx, fmt.Sprintf("%s #%d.%d", *message, worker, j))
                 }
-                t1 := time.Now()
-                // send detect text
-                _ = c.SendListenStart(context.Background(), "ptt") // optional; ignore error
-                if err := c.SendDetectText(context.Background(), fmt.Sprintf("%s #%d.%d", *message, worker, j)); err != nil {
+                if err != nil {
+                    reqCancel()
                     atomic.AddInt64(&errCnt, 1)
+                    continue
                 }
-
-                // wait for response
-                select {
-                case <-respCh:
-                    ms := float64(time.Since(t1).Milliseconds())
-                    muResp.Lock(); resps = append(resps, ms); muResp.Unlock()
+                res, err := turn.Wait(context.Background())
+                reqCancel()
+                switch {
+                case err == nil:
+                    muResp.Lock(); resps = append(resps, float64(res.FirstEvent.Milliseconds())); muResp.Unlock()
                     atomic.AddInt64(&reqOK, 1)
-                case <-time.After(*respTO):
+                case errors.Is(err, context.DeadlineExceeded):
                     atomic.AddInt64(&reqTO, 1)
+                default:
+                    atomic.AddInt64(&errCnt, 1)
                 }
-                _ = c.SendListenStop(context.Background(), "ptt") // optional; ignore error
             }
             c.Close()
         }(i)
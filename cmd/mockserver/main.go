@@ -0,0 +1,114 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "syscall"
+
+    "myproject/internal/logging"
+    "myproject/internal/mockserver"
+    "myproject/internal/mqttbroker"
+)
+
+const usage = `Usage: mockserver <command> [flags]
+
+Commands:
+  ws      WebSocket mock server
+  mqtt    Embedded MQTT broker + hello responder handing out AES-CTR UDP endpoints
+  broker  Embedded MQTT 3.1.1 broker only
+
+Run "mockserver <command> -h" for command flags.
+`
+
+func main() {
+    if len(os.Args) < 2 {
+        fmt.Fprint(os.Stderr, usage)
+        os.Exit(2)
+    }
+    var err error
+    switch os.Args[1] {
+    case "ws":
+        err = runWS(os.Args[2:])
+    case "mqtt":
+        err = runMQTT(os.Args[2:], true)
+    case "broker":
+        err = runMQTT(os.Args[2:], false)
+    case "-h", "--help", "help":
+        fmt.Print(usage)
+        return
+    default:
+        fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+        os.Exit(2)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+// sessionFlags registers the flags shared by the ws and mqtt commands
+func sessionFlags(fs *flag.FlagSet, cfg *mockserver.Config) {
+    fs.StringVar(&cfg.SessionID, "session", "", "Fixed session_id (random per connection if empty)")
+    fs.BoolVar(&cfg.Realtime, "realtime", false, "Pace TTS audio at real-time frame duration")
+    fs.BoolVar(&cfg.Echo, "echo", false, "Play upstream audio back as TTS audio")
+}
+
+func runWS(args []string) error {
+    fs := flag.NewFlagSet("ws", flag.ExitOnError)
+    addr := fs.String("addr", "127.0.0.1:8000", "Listen address")
+    cfg := &mockserver.Config{}
+    sessionFlags(fs, cfg)
+    fs.StringVar(&cfg.Token, "token", "", "Required auth token (header or access_token/token query)")
+    logLevel := fs.String("log-level", "info", "Log level: debug|info|warn|error")
+    _ = fs.Parse(args)
+    logging.Init(*logLevel)
+
+    srv := mockserver.New(*cfg)
+    if err := srv.Start(*addr); err != nil {
+        return fmt.Errorf("start mock server: %w", err)
+    }
+    defer srv.Close()
+    fmt.Printf("WebSocket mock server: %s\n", srv.URL())
+    waitSignal()
+    return nil
+}
+
+func runMQTT(args []string, responder bool) error {
+    name := "broker"
+    if responder { name = "mqtt" }
+    fs := flag.NewFlagSet(name, flag.ExitOnError)
+    addr := fs.String("addr", "127.0.0.1:1883", "Broker listen address")
+    user := fs.String("username", "", "Required MQTT username (any if empty)")
+    pass := fs.String("password", "", "Required MQTT password")
+    mcfg := mockserver.MQTTConfig{}
+    if responder {
+        sessionFlags(fs, &mcfg.Config)
+        fs.StringVar(&mcfg.RequestTopic, "pub", "device-server", "Topic devices publish to")
+        fs.StringVar(&mcfg.UDPHost, "udp-host", "127.0.0.1", "UDP listen host, also announced in hello")
+    }
+    logLevel := fs.String("log-level", "info", "Log level: debug|info|warn|error")
+    _ = fs.Parse(args)
+    logging.Init(*logLevel)
+
+    b := mqttbroker.New(mqttbroker.Config{Username: *user, Password: *pass})
+    if err := b.Start(*addr); err != nil {
+        return fmt.Errorf("start broker: %w", err)
+    }
+    defer b.Close()
+    fmt.Printf("MQTT broker: %s\n", b.URL())
+    if responder {
+        r := mockserver.NewMQTTResponder(b, mcfg)
+        defer r.Close()
+        fmt.Printf("Hello responder: publish to %q, subscribe to %q\n", mcfg.RequestTopic, mockserver.DefaultReplyTopic("<client-id>"))
+    }
+    waitSignal()
+    return nil
+}
+
+func waitSignal() {
+    ch := make(chan os.Signal, 1)
+    signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+    <-ch
+}
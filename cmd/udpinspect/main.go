@@ -0,0 +1,188 @@
+package main
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "myproject/internal/udpinspect"
+)
+
+const usage = `Usage: udpinspect [flags] <capture.pcap|capture.pcapng>
+
+Decodes MQTT+UDP audio packets (docs/mqtt-udp.md section 4) from a capture,
+decrypts them with the session key/nonce from the server hello, and reports
+sequence gaps, reordering, duplicates, timestamp drift and SSRC changes.
+
+Flags:
+`
+
+func main() {
+    keyHex := flag.String("key", "", "Session AES key from the hello udp.key (hex); omit to skip decryption")
+    nonceHex := flag.String("nonce", "", "Session nonce from the hello udp.nonce (hex)")
+    port := flag.Uint("port", 0, "Only analyze datagrams to or from this UDP port")
+    asJSON := flag.Bool("json", false, "Print the report as JSON")
+    verbose := flag.Bool("v", false, "Print every event (default: first 20 per stream)")
+    oggDir := flag.String("ogg-dir", "", "Export each stream's decrypted Opus to <dir>/<src>_<dst>.ogg")
+    sampleRate := flag.Int("sample-rate", 24000, "Sample rate written to exported Ogg headers")
+    channels := flag.Int("channels", 1, "Channel count written to exported Ogg headers")
+    flag.Usage = func() {
+        fmt.Fprint(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if flag.NArg() != 1 || *port > 0xFFFF {
+        flag.Usage()
+        os.Exit(2)
+    }
+    if err := run(flag.Arg(0), *keyHex, *nonceHex, uint16(*port), *asJSON, *verbose, *oggDir, *sampleRate, *channels); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+func run(path, keyHex, nonceHex string, port uint16, asJSON, verbose bool, oggDir string, sampleRate, channels int) error {
+    opt := udpinspect.Options{Port: port}
+    var err error
+    if keyHex != "" {
+        if opt.Key, err = hex.DecodeString(keyHex); err != nil {
+            return fmt.Errorf("bad -key: %w", err)
+        }
+        if opt.Nonce, err = hex.DecodeString(nonceHex); err != nil {
+            return fmt.Errorf("bad -nonce: %w", err)
+        }
+    }
+    if oggDir != "" && len(opt.Key) == 0 {
+        return fmt.Errorf("-ogg-dir requires -key and -nonce")
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    dgs, err := udpinspect.ReadCapture(f)
+    f.Close()
+    if err != nil {
+        return fmt.Errorf("read %s: %w", path, err)
+    }
+    rep, err := udpinspect.Analyze(dgs, opt)
+    if err != nil {
+        return err
+    }
+
+    if oggDir != "" {
+        if err := exportOgg(rep, oggDir, sampleRate, channels); err != nil {
+            return err
+        }
+    }
+    if asJSON {
+        return printJSON(rep)
+    }
+    printReport(rep, len(opt.Key) > 0, verbose)
+    return nil
+}
+
+func streamName(s *udpinspect.Stream) string {
+    r := strings.NewReplacer(":", "-", "[", "", "]", "")
+    return r.Replace(s.Src.String()) + "_" + r.Replace(s.Dst.String())
+}
+
+func exportOgg(rep *udpinspect.Report, dir string, sampleRate, channels int) error {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return err
+    }
+    for _, s := range rep.Streams {
+        path := filepath.Join(dir, streamName(s)+".ogg")
+        f, err := os.Create(path)
+        if err != nil {
+            return err
+        }
+        err = s.WriteOgg(f, sampleRate, channels)
+        if cerr := f.Close(); err == nil { err = cerr }
+        if err != nil {
+            return fmt.Errorf("write %s: %w", path, err)
+        }
+        fmt.Fprintf(os.Stderr, "Exported %s\n", path)
+    }
+    return nil
+}
+
+// jsonStream drops the per-packet payloads, which are large and not useful in a report
+type jsonStream struct {
+    Src         string             `json:"src"`
+    Dst         string             `json:"dst"`
+    Packets     int                `json:"packets"`
+    Bytes       int                `json:"bytes"`
+    SSRCs       []uint32           `json:"ssrcs"`
+    Lost        int                `json:"lost"`
+    Reordered   int                `json:"reordered"`
+    Duplicates  int                `json:"duplicates"`
+    TSBackwards int                `json:"ts_backwards"`
+    TOCMismatch int                `json:"toc_mismatch"`
+    DriftMinMs  int64              `json:"drift_min_ms"`
+    DriftMaxMs  int64              `json:"drift_max_ms"`
+    DriftLastMs int64              `json:"drift_last_ms"`
+    Events      []udpinspect.Event `json:"events"`
+}
+
+func printJSON(rep *udpinspect.Report) error {
+    out := struct {
+        Datagrams int          `json:"datagrams"`
+        Skipped   int          `json:"skipped"`
+        Streams   []jsonStream `json:"streams"`
+    }{Datagrams: rep.Datagrams, Skipped: rep.Skipped, Streams: []jsonStream{}}
+    for _, s := range rep.Streams {
+        out.Streams = append(out.Streams, jsonStream{
+            Src: s.Src.String(), Dst: s.Dst.String(),
+            Packets: len(s.Packets), Bytes: s.Bytes, SSRCs: s.SSRCs,
+            Lost: s.Lost, Reordered: s.Reordered, Duplicates: s.Duplicates,
+            TSBackwards: s.TSBackwards, TOCMismatch: s.TOCMismatch,
+            DriftMinMs: s.DriftMin.Milliseconds(), DriftMaxMs: s.DriftMax.Milliseconds(), DriftLastMs: s.DriftLast.Milliseconds(),
+            Events: s.Events,
+        })
+    }
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    return enc.Encode(out)
+}
+
+func printReport(rep *udpinspect.Report, decrypted, verbose bool) {
+    fmt.Printf("Datagrams: %d (%d skipped), audio streams: %d\n", rep.Datagrams, rep.Skipped, len(rep.Streams))
+    for _, s := range rep.Streams {
+        fmt.Printf("\n%s -> %s\n", s.Src, s.Dst)
+        span := time.Duration(0)
+        if n := len(s.Packets); n > 1 {
+            span = s.Packets[n-1].Time.Sub(s.Packets[0].Time)
+        }
+        fmt.Printf("  packets %d, payload %d bytes over %s\n", len(s.Packets), s.Bytes, span.Round(time.Millisecond))
+        ssrcs := make([]string, len(s.SSRCs))
+        for i, v := range s.SSRCs {
+            ssrcs[i] = fmt.Sprintf("%08x", v)
+        }
+        fmt.Printf("  ssrc %s\n", strings.Join(ssrcs, ", "))
+        fmt.Printf("  lost %d, reordered %d, duplicates %d, ts backwards %d\n", s.Lost, s.Reordered, s.Duplicates, s.TSBackwards)
+        fmt.Printf("  drift min %s, max %s, last %s\n", s.DriftMin, s.DriftMax, s.DriftLast)
+        if decrypted {
+            fmt.Printf("  toc mismatch %d", s.TOCMismatch)
+            if len(s.Packets) > 0 && s.TOCMismatch*2 > len(s.Packets) {
+                fmt.Print(" (key/nonce probably wrong)")
+            }
+            fmt.Println()
+        }
+        events := s.Events
+        if !verbose && len(events) > 20 {
+            events = events[:20]
+        }
+        for _, e := range events {
+            fmt.Printf("  %s seq=%d %-12s %s\n", e.Time.Format("15:04:05.000"), e.Seq, e.Kind, e.Detail)
+        }
+        if len(events) < len(s.Events) {
+            fmt.Printf("  ... %d more events (use -v)\n", len(s.Events)-len(events))
+        }
+    }
+}
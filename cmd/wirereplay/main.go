@@ -0,0 +1,188 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "sync/atomic"
+    "syscall"
+    "time"
+
+    "myproject/internal/logging"
+    "myproject/internal/mqttbroker"
+    "myproject/internal/transport"
+    "myproject/internal/wirelog"
+)
+
+const usage = `Usage: wirereplay <command> [flags] <archive.jsonl>
+
+Commands:
+  info    Print archive header and frame counts
+  server  Act as a fake server answering a live client with the recorded downstream traffic
+  client  Act as a fake client re-sending the recorded upstream traffic to a real server
+
+Run "wirereplay <command> -h" for command flags.
+`
+
+func main() {
+    if len(os.Args) < 2 {
+        fmt.Fprint(os.Stderr, usage)
+        os.Exit(2)
+    }
+    var err error
+    switch os.Args[1] {
+    case "info":
+        err = runInfo(os.Args[2:])
+    case "server":
+        err = runServer(os.Args[2:])
+    case "client":
+        err = runClient(os.Args[2:])
+    case "-h", "--help", "help":
+        fmt.Print(usage)
+        return
+    default:
+        fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+        os.Exit(2)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+func openArchive(fs *flag.FlagSet) (*wirelog.Archive, error) {
+    if fs.NArg() != 1 {
+        return nil, fmt.Errorf("expected one archive path, got %d", fs.NArg())
+    }
+    return wirelog.Open(fs.Arg(0))
+}
+
+func runInfo(args []string) error {
+    fs := flag.NewFlagSet("info", flag.ExitOnError)
+    _ = fs.Parse(args)
+    a, err := openArchive(fs)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Started:  %s\n", a.Header.Started.Format(time.RFC3339))
+    fmt.Printf("Protocol: %s\n", a.Protocol())
+    fmt.Printf("Duration: %s\n", a.Duration())
+    for k, v := range a.Header.Meta {
+        fmt.Printf("Meta:     %s=%s\n", k, v)
+    }
+    counts := map[string]int{}
+    for _, f := range a.Frames {
+        kind := "text"
+        if f.Binary { kind = "binary" }
+        counts[f.Dir+" "+f.Channel+" "+kind]++
+    }
+    for k, n := range counts {
+        fmt.Printf("Frames:   %-20s %d\n", k, n)
+    }
+    return nil
+}
+
+func runServer(args []string) error {
+    fs := flag.NewFlagSet("server", flag.ExitOnError)
+    addr := fs.String("addr", "", "Listen address (default 127.0.0.1:8000 for ws, 127.0.0.1:1883 for mqtt)")
+    speed := fs.Float64("speed", 1, "Playback speed multiplier")
+    pub := fs.String("pub", "device-server", "MQTT topic devices publish to")
+    udpHost := fs.String("udp-host", "127.0.0.1", "UDP listen host, also announced in hello")
+    logLevel := fs.String("log-level", "info", "Log level: debug|info|warn|error")
+    _ = fs.Parse(args)
+    logging.Init(*logLevel)
+    a, err := openArchive(fs)
+    if err != nil {
+        return err
+    }
+
+    srv := wirelog.NewServer(a, wirelog.ServerConfig{Speed: *speed, RequestTopic: *pub, UDPHost: *udpHost})
+    defer srv.Close()
+    if a.Protocol() == "mqtt" {
+        if *addr == "" { *addr = "127.0.0.1:1883" }
+        b := mqttbroker.New(mqttbroker.Config{})
+        if err := b.Start(*addr); err != nil {
+            return fmt.Errorf("start broker: %w", err)
+        }
+        defer b.Close()
+        stopMQTT := srv.ServeMQTT(b)
+        defer stopMQTT()
+        fmt.Printf("Replaying %d frames over MQTT+UDP: %s\n", len(a.Frames), b.URL())
+    } else {
+        if *addr == "" { *addr = "127.0.0.1:8000" }
+        if err := srv.Start(*addr); err != nil {
+            return fmt.Errorf("start replay server: %w", err)
+        }
+        fmt.Printf("Replaying %d frames over WebSocket: %s\n", len(a.Frames), srv.URL())
+    }
+    ch := make(chan os.Signal, 1)
+    signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+    <-ch
+    return nil
+}
+
+// headerFlags collects repeated -H "Key: Value" flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string { return fmt.Sprint(map[string]string(h)) }
+
+func (h headerFlags) Set(v string) error {
+    k, val, ok := strings.Cut(v, ":")
+    if !ok {
+        return fmt.Errorf("header %q: want Key: Value", v)
+    }
+    h[strings.TrimSpace(k)] = strings.TrimSpace(val)
+    return nil
+}
+
+func runClient(args []string) error {
+    fs := flag.NewFlagSet("client", flag.ExitOnError)
+    headers := headerFlags{}
+    cfg := wirelog.PlayerConfig{Headers: headers}
+    fs.Var(headers, "H", "Extra WebSocket handshake header, repeatable (e.g. -H 'Authorization: Bearer xxx')")
+    fs.StringVar(&cfg.URL, "ws", "", "WebSocket URL")
+    fs.StringVar(&cfg.Broker, "broker", "", "MQTT broker URL")
+    fs.StringVar(&cfg.Username, "username", "", "MQTT username")
+    fs.StringVar(&cfg.Password, "password", "", "MQTT password")
+    fs.StringVar(&cfg.ClientID, "client-id", "", "MQTT client ID")
+    fs.StringVar(&cfg.PublishTopic, "pub", "device-server", "MQTT publish topic")
+    fs.StringVar(&cfg.SubscribeTopic, "sub", "", "MQTT subscribe topic")
+    fs.IntVar(&cfg.KeepAliveSec, "keepalive", 240, "MQTT keepalive seconds")
+    fs.Float64Var(&cfg.Speed, "speed", 1, "Playback speed multiplier")
+    fs.DurationVar(&cfg.HelloTimeout, "hello-timeout", 10*time.Second, "Hello wait timeout")
+    fs.DurationVar(&cfg.Linger, "linger", 0, "Keep receiving after the last upstream frame (default: recorded tail)")
+    out := fs.String("o", "", "Record this run's traffic to an archive for comparison")
+    logLevel := fs.String("log-level", "info", "Log level: debug|info|warn|error")
+    _ = fs.Parse(args)
+    logging.Init(*logLevel)
+    a, err := openArchive(fs)
+    if err != nil {
+        return err
+    }
+
+    var sent, recv int64
+    var rec *wirelog.Recorder
+    if *out != "" {
+        if rec, err = wirelog.Create(*out, map[string]string{"replay_of": fs.Arg(0)}); err != nil {
+            return err
+        }
+    }
+    cfg.OnFrame = func(f transport.WireFrame) {
+        // called from both the send loop and transport read goroutines
+        if f.Dir == transport.WireUp { atomic.AddInt64(&sent, 1) } else { atomic.AddInt64(&recv, 1) }
+        if rec != nil { rec.Record(f) }
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    start := time.Now()
+    err = wirelog.Play(ctx, a, cfg)
+    if rec != nil {
+        if cerr := rec.Close(); err == nil { err = cerr }
+    }
+    fmt.Printf("Sent %d frames, received %d frames in %s\n", atomic.LoadInt64(&sent), atomic.LoadInt64(&recv), time.Since(start).Round(time.Millisecond))
+    return err
+}
@@ -83,7 +83,18 @@ function Message({ role, text, time, detail, onShowDetail, avatar }) {
   )
 }
 
-function InputBar({ onSend, onPTTStart, onPTTStop, recording, pttTime }) {
+// MicMeter 麦克风预处理的输入/输出电平（-60..0 dBFS），噪声门关闭时变暗
+function MicMeter({ levels }) {
+  const pct = (db) => Math.max(0, Math.min(100, (Number(db) + 60) / 60 * 100))
+  return (
+    <div className={`mic-meter ${levels.gate_open ? '' : 'gated'}`} title={`输入 ${Number(levels.input_db).toFixed(0)} dB · 输出 ${Number(levels.output_db).toFixed(0)} dB · 增益 ${Number(levels.gain_db).toFixed(0)} dB`}>
+      <div className="bar"><div className="fill in" style={{ width: `${pct(levels.input_db)}%` }} /></div>
+      <div className="bar"><div className={`fill out ${levels.limited ? 'limited' : ''}`} style={{ width: `${pct(levels.output_db)}%` }} /></div>
+    </div>
+  )
+}
+
+function InputBar({ onSend, onPTTStart, onPTTStop, recording, pttTime, autoListen, micLevels }) {
   const [val, setVal] = useState('')
   const taRef = useRef(null)
   const composingRef = useRef(false)
@@ -121,18 +132,30 @@ function InputBar({ onSend, onPTTStart, onPTTStop, recording, pttTime }) {
 
   return (
     <div className="input-bar">
-      <button
-        className={`mic ${recording ? 'recording' : ''}`}
-        title="按住说话"
-        onMouseDown={onPTTStart}
-        onMouseUp={onPTTStop}
-        onMouseLeave={() => recording && onPTTStop()}
-        onTouchStart={(e)=>{ e.preventDefault(); onPTTStart() }}
-        onTouchEnd={(e)=>{ e.preventDefault(); onPTTStop() }}
-      >
-        {recording ? '●' : '🎤'}
-      </button>
+      {autoListen ? (
+        // 自动对话：点击开始/结束，说话结束由后端 VAD 判定
+        <button
+          className={`mic ${recording ? 'recording' : ''}`}
+          title="点击开始自动对话"
+          onClick={() => (recording ? onPTTStop() : onPTTStart())}
+        >
+          {recording ? '●' : '🎤'}
+        </button>
+      ) : (
+        <button
+          className={`mic ${recording ? 'recording' : ''}`}
+          title="按住说话"
+          onMouseDown={onPTTStart}
+          onMouseUp={onPTTStop}
+          onMouseLeave={() => recording && onPTTStop()}
+          onTouchStart={(e)=>{ e.preventDefault(); onPTTStart() }}
+          onTouchEnd={(e)=>{ e.preventDefault(); onPTTStop() }}
+        >
+          {recording ? '●' : '🎤'}
+        </button>
+      )}
       {recording && <div className="ptt-timer">{pttTime.toFixed(1)}s</div>}
+      {recording && micLevels && <MicMeter levels={micLevels} />}
       <textarea
         ref={taRef}
         className="text-input"
@@ -152,10 +175,15 @@ function InputBar({ onSend, onPTTStart, onPTTStop, recording, pttTime }) {
 function App() {
   const [messages, setMessages] = useState([])
   const [recording, setRecording] = useState(false)
+  // 麦克风预处理各级电平（mic_levels 事件，仅开启预处理时下发）
+  const [micLevels, setMicLevels] = useState(null)
+  useEffect(() => { if (!recording) setMicLevels(null) }, [recording])
   const [currentPage, setCurrentPage] = useState('chat') // 'chat' | 'settings' | 'db' | 'loadtest'
   const [windowSize, setWindowSize] = useState({ width: window.innerWidth, height: window.innerHeight })
   // 新增：麦克风录音器引用
   const micRef = useRef(null)
+  // 上行音频采样率（connected 事件下发），麦克风按此重采样
+  const micSampleRateRef = useRef(16000)
   const makeDefaultForm = () => ({
     protocol: 'ws',
     ws: 'ws://127.0.0.1:8000',
@@ -163,6 +191,14 @@ function App() {
     enable_token: true,
     // 新增：控制系统提示气泡显隐
     show_system_bubbles: true,
+    // 自动对话：VAD 判定说话结束，播报结束后自动继续监听
+    auto_listen: false,
+    // 连接意外断开后按退避自动重连
+    auto_reconnect: false,
+    // 麦克风预处理（高通、AGC、噪声门、限幅），默认关闭
+    mic_preprocess: false,
+    // 插话：播报期间检测到用户说话时中断播报，默认关闭
+    barge_in: false,
     // 统一设备ID：默认使用系统 MAC
     use_system_mac: true,
     system_mac: '',
@@ -367,10 +403,7 @@ function App() {
           const fd = ap.frame_duration || ap.frameDuration
           const tp = obj.transport === 'websocket' ? 'WebSocket' : (obj.transport || '未知')
 
-          // 同步前端音频采样率
-          if (audioPlayerRef.current && sr) {
-            try { audioPlayerRef.current.setSampleRate(Number(sr)) } catch {}
-          }
+          // Go 端已将播放 PCM 统一重采样到 48kHz，前端无需跟随服务端采样率
 
           summarized = `会话握手成功 · 传输: ${escapeHtml(String(tp))} · 音频: ${escapeHtml(String(fmt || ''))} ${escapeHtml(String(sr || '?'))}Hz ${escapeHtml(String(ch || '?'))}声道 · 帧 ${escapeHtml(String(fd || '?'))}ms`
           return appendMsg('system', summarized, JSON.stringify(obj, null, 2))
@@ -395,12 +428,6 @@ function App() {
           const sessionId = obj.session_id || obj.sessionId || 'default'
           const content = obj.text ?? obj.content ?? ''
 
-          // 同步采样率（如果提供）
-          const ttsSr = obj.sample_rate || obj.sampleRate
-          if (audioPlayerRef.current && ttsSr) {
-            try { audioPlayerRef.current.setSampleRate(Number(ttsSr)) } catch {}
-          }
-
           if (state === 'sentence_start') {
             const id = crypto.randomUUID()
             const html = escapeHtml(String(content))
@@ -608,9 +635,18 @@ function App() {
     const offConnected = EOn('connected', (info) => {
       setConnecting(false)
       const proto = (info && info.protocol) || form.protocol
+      const rate = Number(info && info.sample_rate)
+      if (rate > 0) {
+        micSampleRateRef.current = rate
+        micRef.current && micRef.current.setTargetSampleRate(rate)
+      }
       setSubtitle(`在线 · ${proto === 'ws' ? 'WebSocket' : 'MQTT'}`)
-      // 若是自动连接触发，则不再追加“已连接（…）”提示，避免两条系统消息
-      if (!autoConnectingRef.current) {
+      if (info && info.reconnected === 'true') {
+        appendMsg('system', `已重新连接（${proto}）`)
+      } else if (info && info.renewed === 'true') {
+        appendMsg('system', '已重新握手，会话已更新')
+      } else if (!autoConnectingRef.current) {
+        // 若是自动连接触发，则不再追加“已连接（…）”提示，避免两条系统消息
         appendMsg('system', `已连接（${proto}）`)
       }
       // 连接已建立，重置自动连接标志
@@ -637,6 +673,61 @@ function App() {
       }
       hasPlayedAudioRef.current = false // 重置音频播放标志
     })
+    // 自动重连进度：等待与尝试中更新副标题，放弃后按断开处理
+    const offReconnect = EOn('reconnect', (ev) => {
+      const state = ev?.state
+      if (state === 'waiting') {
+        setConnected(false)
+        setSubtitle(`重连中 · 第 ${ev.attempt} 次（${Math.round((ev.delay_ms || 0) / 1000)}s 后）`)
+        if (audioPlayerRef.current) { audioPlayerRef.current.stop() }
+      } else if (state === 'attempting') {
+        setSubtitle(`重连中 · 第 ${ev.attempt || 1} 次`)
+      } else if (state === 'failed' && ev.error) {
+        console.warn('重连失败', ev.error)
+      } else if (state === 'gave_up') {
+        appendMsg('system', '❌ 自动重连失败，已断开', ev.error || '')
+      }
+    })
+    // 后端 VAD 检测到说话结束并已自动停止监听
+    const offMicLevels = EOn('mic_levels', (lv) => { setMicLevels(lv) })
+    const offListenStopped = EOn('listen_stopped', () => {
+      setRecording(false)
+      clearInterval(timerRef.current)
+      try { micRef.current && micRef.current.stop() } catch {}
+    })
+    // 自动对话：播报结束后后端已重新进入监听，恢复麦克风采集
+    const offListenStarted = EOn('listen_started', async () => {
+      setRecording(true)
+      setPttTime(0)
+      clearInterval(timerRef.current)
+      timerRef.current = setInterval(()=>setPttTime(t=>t+0.1), 100)
+      try {
+        await startMic()
+      } catch (e) {
+        console.error('启动麦克风失败:', e)
+      }
+    })
+    // 插话：speaking 期间保持麦克风采集供后端检测；触发后清空待播放音频
+    const offPlaybackFlush = EOn('playback_flush', () => {
+      try { audioPlayerRef.current && audioPlayerRef.current.stop() } catch {}
+    })
+    const offBargeIn = EOn('barge_in', async (ev) => {
+      const state = ev?.state
+      if (state === 'armed') {
+        try {
+          await startMic()
+        } catch (e) {
+          console.warn('插话检测启动麦克风失败', e)
+        }
+      } else if (state === 'triggered') {
+        setRecording(true)
+      } else if (state === 'disarmed') {
+        setRecording(r => {
+          if (!r) { try { micRef.current && micRef.current.stop() } catch {} }
+          return r
+        })
+      }
+    })
     const offError = EOn('error', (err) => {
       setConnecting(false)
       setConnected(false)
@@ -673,6 +764,10 @@ function App() {
           enable_token: toBool(obj?.enable_token ?? f.enable_token),
           // 新增：恢复系统气泡显隐
           show_system_bubbles: toBool(obj?.show_system_bubbles ?? f.show_system_bubbles),
+          auto_listen: toBool(obj?.auto_listen ?? f.auto_listen),
+          auto_reconnect: toBool(obj?.auto_reconnect ?? f.auto_reconnect),
+          mic_preprocess: toBool(obj?.mic_preprocess ?? f.mic_preprocess),
+          barge_in: toBool(obj?.barge_in ?? f.barge_in),
           // 新增：恢复是否使用系统 MAC
           use_system_mac: toBool(obj?.use_system_mac ?? f.use_system_mac),
         }))
@@ -683,7 +778,7 @@ function App() {
     // 请求加载配置
     EEmit('load_config')
     return () => {
-      offText && offText(); offAudio && offAudio(); offAudioPCM && offAudioPCM(); offConnected && offConnected(); offDisconnected && offDisconnected(); offError && offError(); offConfig && offConfig()
+      offText && offText(); offAudio && offAudio(); offAudioPCM && offAudioPCM(); offConnected && offConnected(); offDisconnected && offDisconnected(); offReconnect && offReconnect(); offMicLevels && offMicLevels(); offListenStopped && offListenStopped(); offListenStarted && offListenStarted(); offPlaybackFlush && offPlaybackFlush(); offBargeIn && offBargeIn(); offError && offError(); offConfig && offConfig()
     }
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, [])
@@ -747,6 +842,20 @@ function App() {
     }
   }
 
+  // 启动麦克风采集并将帧发送给后端
+  const startMic = async () => {
+    if (!micRef.current) {
+      micRef.current = new MicRecorder({
+        targetSampleRate: micSampleRateRef.current,
+        onFrame: (arr) => {
+          // 将Float32数组转普通数组以便 Wails 传输
+          try { EEmit('mic_frame', arr) } catch (e) { console.warn('mic_frame emit failed', e) }
+        }
+      })
+    }
+    await micRef.current.start()
+  }
+
   const startPTT = async () => {
     setRecording(true)
     setPttTime(0)
@@ -763,7 +872,7 @@ function App() {
       }
     }
 
-    EEmit('start_listen')
+    EEmit('start_listen', { mode: toBool(form.auto_listen) ? 'auto' : 'manual' })
     // 开始一次 PTT 会话：下一条普通文本优先作为“用户”气泡显示（带超时）
     pttExpectUserFirstRef.current = true
     if (pttFirstTimeoutRef.current) { clearTimeout(pttFirstTimeoutRef.current) }
@@ -774,16 +883,7 @@ function App() {
 
     // 启动麦克风采集并将帧发送给后端
     try {
-      if (!micRef.current) {
-        micRef.current = new MicRecorder({
-          targetSampleRate: 16000,
-          onFrame: (arr) => {
-            // 将Float32数组转普通数组以便 Wails 传输
-            try { EEmit('mic_frame', arr) } catch (e) { console.warn('mic_frame emit failed', e) }
-          }
-        })
-      }
-      await micRef.current.start()
+      await startMic()
     } catch (e) {
       console.error('启动麦克风失败:', e)
       appendMsg('system', `🎙️ 启动麦克风失败: ${escapeHtml(e?.message || String(e))}`)
@@ -852,7 +952,7 @@ function App() {
       }
       // 确保保存与连接时携带统一设备ID
       resolved.device_id = effectiveDeviceId
-      EventsEmit('connect_ws', { url: resolved.ws, client_id: resolved.client_id, device_id: resolved.device_id, token: resolved.token, enable_token: toBool(resolved.enable_token) })
+      EventsEmit('connect_ws', { url: resolved.ws, client_id: resolved.client_id, device_id: resolved.device_id, token: resolved.token, enable_token: toBool(resolved.enable_token), auto_reconnect: toBool(resolved.auto_reconnect), mic_preprocess: toBool(resolved.mic_preprocess), barge_in: toBool(resolved.barge_in) })
       EventsEmit('save_config', resolved)
     } else {
       // MQTT 分支：也支持 OTA 下发
@@ -943,6 +1043,9 @@ function App() {
         client_id: resolved.client_id,
         device_id: resolved.device_id,
         token: resolved.token,
+        auto_reconnect: toBool(resolved.auto_reconnect),
+        mic_preprocess: toBool(resolved.mic_preprocess),
+        barge_in: toBool(resolved.barge_in),
       })
       EventsEmit('save_config', resolved)
     }
@@ -1053,7 +1156,7 @@ function App() {
               />
             ))}
           </div>
-          <InputBar onSend={onSend} onPTTStart={startPTT} onPTTStop={stopPTT} recording={recording} pttTime={pttTime} />
+          <InputBar onSend={onSend} onPTTStart={startPTT} onPTTStop={stopPTT} recording={recording} pttTime={pttTime} autoListen={toBool(form.auto_listen)} micLevels={micLevels} />
         </>
       )}
       </div>
@@ -1,4 +1,4 @@
-// 简易麦克风录音器：捕获麦克风，重采样到16kHz单声道，并以Float32数组回调
+// 简易麦克风录音器：捕获麦克风，重采样到目标采样率（与连接协商的上行采样率一致）单声道，并以Float32数组回调
 
 import { EventsEmit as _EventsEmit } from '../../wailsjs/runtime/runtime'
 
@@ -73,6 +73,11 @@ class MicRecorder {
 
   setOnFrame(cb) { this.onFrame = cb }
 
+  // 连接的上行采样率变化时更新，下一个采集块起生效
+  setTargetSampleRate(rate) {
+    if (rate > 0) this.targetSampleRate = rate
+  }
+
   // 线性重采样到目标采样率
   _resampleToTarget(input, inRate, outRate) {
     if (inRate === outRate) return new Float32Array(input)
@@ -1,5 +1,5 @@
 import { useEffect, useRef, useState } from 'react'
-import { GetSystemVolume, SetSystemVolume, IsSystemVolumeSupported } from '../../wailsjs/go/main/App'
+import { GetSystemVolume, SetSystemVolume, IsSystemVolumeSupported, GetPlaybackVolume, SetPlaybackVolume } from '../../wailsjs/go/main/App'
 import { EventsEmit } from '../../wailsjs/runtime/runtime'
 import './SettingsPage.css'
 
@@ -55,10 +55,13 @@ function SettingsPage({ form, setForm, onConnect, onDisconnect, connecting, audi
           console.warn('获取系统音量失败:', error)
           setVolume(50)
         }
-      } else if (audioPlayer) {
-        const currentVolume = audioPlayer.getVolume()
-        if (typeof currentVolume === 'number') {
-          setVolume(Math.round(currentVolume * 100))
+      } else {
+        // 应用音量由 Go 端软件增益实现，不影响系统音量
+        try {
+          const appVol = await GetPlaybackVolume()
+          setVolume(Math.round(appVol * 100))
+        } catch (error) {
+          console.warn('获取应用音量失败:', error)
         }
       }
     }
@@ -136,10 +139,12 @@ function SettingsPage({ form, setForm, onConnect, onDisconnect, connecting, audi
       } catch (error) {
         console.error('设置系统音量失败:', error)
       }
-    }
-    
-    if (audioPlayer) {
-      audioPlayer.setVolume(volumeValue / 100)
+    } else {
+      try {
+        await SetPlaybackVolume(volumeValue / 100)
+      } catch (error) {
+        console.error('设置应用音量失败:', error)
+      }
     }
   }
 
@@ -203,6 +208,58 @@ function SettingsPage({ form, setForm, onConnect, onDisconnect, connecting, audi
             />
           </div>
 
+          <div className="row">
+            <label>自动对话</label>
+            <input 
+              type="checkbox" 
+              checked={!!toBool(form.auto_listen)} 
+              onChange={(e)=>{
+                const checked = e.target.checked
+                setForm(s => ({ ...s, auto_listen: checked }))
+                EventsEmit('save_config', { auto_listen: checked })
+              }} 
+            />
+          </div>
+
+          <div className="row">
+            <label>自动重连</label>
+            <input 
+              type="checkbox" 
+              checked={!!toBool(form.auto_reconnect)} 
+              onChange={(e)=>{
+                const checked = e.target.checked
+                setForm(s => ({ ...s, auto_reconnect: checked }))
+                EventsEmit('save_config', { auto_reconnect: checked })
+              }} 
+            />
+          </div>
+
+          <div className="row">
+            <label>麦克风预处理</label>
+            <input 
+              type="checkbox" 
+              checked={!!toBool(form.mic_preprocess)} 
+              onChange={(e)=>{
+                const checked = e.target.checked
+                setForm(s => ({ ...s, mic_preprocess: checked }))
+                EventsEmit('save_config', { mic_preprocess: checked })
+              }} 
+            />
+          </div>
+
+          <div className="row">
+            <label>插话打断</label>
+            <input 
+              type="checkbox" 
+              checked={!!toBool(form.barge_in)} 
+              onChange={(e)=>{
+                const checked = e.target.checked
+                setForm(s => ({ ...s, barge_in: checked }))
+                EventsEmit('save_config', { barge_in: checked })
+              }} 
+            />
+          </div>
+
           {/* 使用OTA 与 启用Token 改为通用设置，两个协议都可使用 */}
           <div className="row">
             <label>使用OTA</label>
@@ -0,0 +1,170 @@
+package audio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// BargeInDetector 播放（speaking）期间检测用户插话：麦克风电平需高出估计回声电平
+// （播放电平 + EchoCouplingDB）MarginDB，且持续 SustainMs 才触发，避免把扬声器回声当作说话。
+// 下行音频往往快于实时到达，播放器按到达顺序首尾相接播放，因此按同样方式推算每段 PCM
+// 实际发声的时间区间，回声电平取当前正在播放的片段，而不是解码时刻的电平
+
+// BargeInConfig 插话检测参数
+type BargeInConfig struct {
+	SampleRate     int
+	PlaybackRate   int     // 送往播放器的 PCM 采样率（单声道），默认 48000
+	WindowMs       int     // 分析窗口，默认 20ms
+	MinLevelDB     float64 // 麦克风绝对电平下限（dBFS），默认 -35
+	MarginDB       float64 // 高出估计回声电平的余量，默认 10
+	EchoCouplingDB float64 // 扬声器到麦克风的耦合增益估计，默认 -6
+	EchoDecayDB    float64 // 播放停止后回声估计每秒衰减量，默认 40
+	SustainMs      int     // 持续时长，默认 300ms
+}
+
+// DefaultBargeInConfig 返回默认参数
+func DefaultBargeInConfig(sampleRate int) BargeInConfig {
+	return BargeInConfig{SampleRate: sampleRate, PlaybackRate: 48000, WindowMs: 20, MinLevelDB: -35, MarginDB: 10, EchoCouplingDB: -6, EchoDecayDB: 40, SustainMs: 300}
+}
+
+type BargeInDetector struct {
+	cfg         BargeInConfig
+	window      int
+	sustainWins int
+
+	mu        sync.Mutex
+	buf       []float32
+	voicedRun int
+	triggered bool
+	queued    []playSegment // 已送往播放器、尚未播完的片段，按播放顺序
+	queueEnd  time.Time     // 播放队列预计播完的时刻
+	lastDB    float64       // 最近播完片段的电平（含此前尾音的衰减）
+	lastEnd   time.Time
+
+	now func() time.Time
+}
+
+// playSegment 一段 PCM 预计的发声区间与电平
+type playSegment struct {
+	start, end time.Time
+	db         float64
+}
+
+// NewBargeInDetector 创建检测器；零值参数使用 DefaultBargeInConfig 中的默认值
+func NewBargeInDetector(cfg BargeInConfig) *BargeInDetector {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 16000
+	}
+	def := DefaultBargeInConfig(cfg.SampleRate)
+	if cfg.PlaybackRate <= 0 {
+		cfg.PlaybackRate = def.PlaybackRate
+	}
+	if cfg.WindowMs <= 0 {
+		cfg.WindowMs = def.WindowMs
+	}
+	if cfg.MinLevelDB == 0 {
+		cfg.MinLevelDB = def.MinLevelDB
+	}
+	if cfg.MarginDB <= 0 {
+		cfg.MarginDB = def.MarginDB
+	}
+	if cfg.EchoCouplingDB == 0 {
+		cfg.EchoCouplingDB = def.EchoCouplingDB
+	}
+	if cfg.EchoDecayDB <= 0 {
+		cfg.EchoDecayDB = def.EchoDecayDB
+	}
+	if cfg.SustainMs <= 0 {
+		cfg.SustainMs = def.SustainMs
+	}
+	d := &BargeInDetector{
+		cfg:         cfg,
+		window:      cfg.SampleRate * cfg.WindowMs / 1000,
+		sustainWins: ceilDiv(cfg.SustainMs, cfg.WindowMs),
+		now:         time.Now,
+	}
+	d.Reset()
+	return d
+}
+
+// Reset 重置检测状态（每次进入 speaking 时调用）
+func (d *BargeInDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buf = nil
+	d.voicedRun = 0
+	d.triggered = false
+	d.queued = nil
+	d.queueEnd = time.Time{}
+	d.lastDB = -120
+	d.lastEnd = time.Time{}
+}
+
+// ObservePlayback 记录送往播放器的 PCM：排在队列末尾（队列已空时立即）播放，
+// 其电平在实际发声期间计入回声估计
+func (d *BargeInDetector) ObservePlayback(pcm []float32) {
+	if len(pcm) == 0 {
+		return
+	}
+	db := levelDB(pcm)
+	dur := time.Duration(len(pcm)) * time.Second / time.Duration(d.cfg.PlaybackRate)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	start := d.now()
+	if d.queueEnd.After(start) {
+		start = d.queueEnd
+	}
+	d.queueEnd = start.Add(dur)
+	d.queued = append(d.queued, playSegment{start: start, end: d.queueEnd, db: db})
+}
+
+// Process 输入麦克风单声道 Float32 PCM；检测到持续插话时返回 true（每轮仅触发一次，直到 Reset）
+func (d *BargeInDetector) Process(mic []float32) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.triggered {
+		return false
+	}
+	d.buf = append(d.buf, mic...)
+	now := d.now()
+	for len(d.buf) >= d.window {
+		db := levelDB(d.buf[:d.window])
+		d.buf = d.buf[d.window:]
+		echo := d.echoBaseLocked(now) + d.cfg.EchoCouplingDB
+		if db >= d.cfg.MinLevelDB && db >= echo+d.cfg.MarginDB {
+			d.voicedRun++
+		} else {
+			d.voicedRun = 0
+		}
+		if d.voicedRun >= d.sustainWins {
+			d.triggered = true
+			d.buf = nil
+			return true
+		}
+	}
+	return false
+}
+
+// echoBaseLocked 返回 now 时刻的播放电平：正在播放的片段电平与已播完片段按时间衰减的尾音取大者
+func (d *BargeInDetector) echoBaseLocked(now time.Time) float64 {
+	for len(d.queued) > 0 && !d.queued[0].end.After(now) {
+		seg := d.queued[0]
+		d.queued = d.queued[1:]
+		d.lastDB = math.Max(seg.db, d.decayedLocked(seg.end))
+		d.lastEnd = seg.end
+	}
+	base := d.decayedLocked(now)
+	if len(d.queued) > 0 && !d.queued[0].start.After(now) {
+		base = math.Max(base, d.queued[0].db)
+	}
+	return base
+}
+
+// decayedLocked 最近播完片段的电平衰减到 t 时刻的值
+func (d *BargeInDetector) decayedLocked(t time.Time) float64 {
+	if d.lastEnd.IsZero() {
+		return -120
+	}
+	return d.lastDB - d.cfg.EchoDecayDB*t.Sub(d.lastEnd).Seconds()
+}
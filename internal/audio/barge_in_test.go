@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// newTestBargeIn 16kHz 麦克风、默认参数（20ms 窗口，持续 300ms），时钟由 now 控制
+func newTestBargeIn(now *time.Time) *BargeInDetector {
+	d := NewBargeInDetector(BargeInConfig{SampleRate: 16000})
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+// feedBargeIn 以 20ms 一帧送入 n 帧 db 电平的噪声，返回是否触发
+func feedBargeIn(d *BargeInDetector, r *rand.Rand, n int, db float64) bool {
+	hit := false
+	for i := 0; i < n; i++ {
+		hit = d.Process(noise(r, 320, db)) || hit
+	}
+	return hit
+}
+
+func TestBargeInEchoFollowsPlayback(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	for _, tc := range []struct {
+		name  string
+		at    time.Duration
+		micDB float64
+		want  bool
+	}{
+		// 3 秒播放一次性到达，第 2.5 秒仍在播放第三段：回声 -10-6=-16dB，需高于 -6dB
+		{"echo while playing", 2500 * time.Millisecond, -20, false},
+		{"speech over playback", 2500 * time.Millisecond, -3, true},
+		// 播完后尾音按 40dB/s 衰减
+		{"echo tail", 3100 * time.Millisecond, -20, false},
+		{"after tail", 3500 * time.Millisecond, -20, true},
+		{"below min level", 10 * time.Second, -40, false},
+	} {
+		now := t0
+		d := newTestBargeIn(&now)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 3; i++ {
+			d.ObservePlayback(noise(r, 48000, -10))
+		}
+		now = t0.Add(tc.at)
+		if got := feedBargeIn(d, r, 25, tc.micDB); got != tc.want {
+			t.Errorf("%s: triggered %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBargeInSustain(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := newTestBargeIn(&now)
+	r := rand.New(rand.NewSource(2))
+
+	// 300ms 需连续 15 帧，中间一帧静音即重新计数
+	if feedBargeIn(d, r, 14, -20) {
+		t.Fatal("triggered before sustain")
+	}
+	if feedBargeIn(d, r, 1, -60) || feedBargeIn(d, r, 14, -20) {
+		t.Fatal("triggered after the run was broken")
+	}
+	if !d.Process(noise(r, 320, -20)) {
+		t.Fatal("not triggered after 15 voiced windows")
+	}
+	// 每轮仅触发一次，Reset 后重新布防
+	if feedBargeIn(d, r, 20, -20) {
+		t.Error("triggered twice without Reset")
+	}
+	d.Reset()
+	if !feedBargeIn(d, r, 15, -20) {
+		t.Error("not triggered after Reset")
+	}
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+
+	"myproject/internal/audio/oggopus"
+)
+
+// FileSource 以录音文件作为上行音频（替代麦克风，便于复现测试）：
+// 支持 WAV（PCM16/Float32）与 Ogg/Opus，按协商参数混缩声道、重采样（Ogg/Opus 直接以目标采样率解码）并重新编码。
+// 实现 client.UpstreamSource。
+type FileSource struct {
+	Path    string
+	Encoder EncoderConfig // 码率等编码参数；采样率/声道/帧长取协商值。零值使用 24kbps、复杂度 5
+}
+
+// LoadAudioFile 按文件头识别格式并解码为 PCM；oggRate 为 Ogg/Opus 的解码采样率
+// （libopus 支持 8/12/16/24/48kHz，可直接输出目标采样率而无需再重采样），其他值按 48kHz 解码
+func LoadAudioFile(path string, oggRate int) (*PCM, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	switch {
+	case bytes.Equal(magic, []byte("RIFF")):
+		return DecodeWAV(br)
+	case bytes.Equal(magic, []byte("OggS")):
+		ogg, err := oggopus.Read(br)
+		if err != nil {
+			return nil, err
+		}
+		return decodeOggOpus(ogg, oggRate)
+	}
+	return nil, fmt.Errorf("无法识别的音频格式: %s（支持 WAV 与 Ogg/Opus）", path)
+}
+
+// decodeOggOpus 以 rate 解码全部音频包并去除 pre-skip
+func decodeOggOpus(o *oggopus.Stream, rate int) (*PCM, error) {
+	if o.Channels < 1 || o.Channels > 2 {
+		return nil, fmt.Errorf("不支持的 Opus 声道数: %d", o.Channels)
+	}
+	switch rate {
+	case 8000, 12000, 16000, 24000, 48000:
+	default:
+		rate = 48000
+	}
+	dec, err := NewOpusDecoder(rate, o.Channels)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	pcm := &PCM{SampleRate: rate, Channels: o.Channels}
+	for i, pkt := range o.Packets {
+		if len(pkt) == 0 {
+			continue
+		}
+		f, err := dec.DecodeFrameToFloat32(pkt)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个 Opus 包: %w", i, err)
+		}
+		pcm.Samples = append(pcm.Samples, f...)
+	}
+	// pre-skip 以 48kHz 样本计
+	skip := o.PreSkip * rate / 48000 * o.Channels
+	if skip > len(pcm.Samples) {
+		skip = len(pcm.Samples)
+	}
+	pcm.Samples = pcm.Samples[skip:]
+	return pcm, nil
+}
+
+// OpusFrames 读取文件并编码为 sampleRate/channels/frameDurationMs 的 Opus 帧
+func (s FileSource) OpusFrames(sampleRate, channels, frameDurationMs int) ([][]byte, error) {
+	pcm, err := LoadAudioFile(s.Path, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+	samples := remixChannels(pcm.Samples, pcm.Channels, channels)
+	if pcm.SampleRate != sampleRate {
+		rs, err := NewResampler(pcm.SampleRate, sampleRate, channels)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(rs.Process(samples), rs.Flush()...)
+	}
+	return encodeFrames(samples, s.Encoder, sampleRate, channels, frameDurationMs)
+}
+
+// remixChannels 交错 PCM 声道转换：多声道取平均混为单声道，单声道复制到各声道
+func remixChannels(in []float32, from, to int) []float32 {
+	if from == to {
+		return in
+	}
+	n := len(in) / from
+	out := make([]float32, n*to)
+	for i := 0; i < n; i++ {
+		var sum float32
+		for ch := 0; ch < from; ch++ {
+			sum += in[i*from+ch]
+		}
+		v := sum / float32(from)
+		for ch := 0; ch < to; ch++ {
+			out[i*to+ch] = v
+		}
+	}
+	return out
+}
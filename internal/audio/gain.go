@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"math"
+	"sync"
+)
+
+// GainStage 应用内软件增益（不影响系统音量）：音量、静音与闪避（ducking）叠加为目标增益，
+// 目标变化时在 rampMs 内线性渐变，避免爆音；增益大于 1 时对超过 softClipKnee 的样本软限幅
+
+const (
+	maxSoftwareGain = 4.0 // 约 +12dB
+	softClipKnee    = 0.8 // 软限幅起始电平，输出渐近于 ±1
+)
+
+type GainStage struct {
+	mu       sync.Mutex
+	channels int
+	ramp     int // 渐变帧数
+
+	volume float64 // 用户音量（线性）
+	muted  bool
+	duckDB float64 // 闪避衰减量，0 表示不闪避
+
+	current float64 // 当前实际增益
+	step    float64 // 每帧增益步进
+	left    int     // 剩余渐变帧数
+}
+
+// NewGainStage 创建增益级；sampleRate/channels 用于换算渐变时长
+func NewGainStage(sampleRate, channels, rampMs int) *GainStage {
+	if channels <= 0 {
+		channels = 1
+	}
+	return &GainStage{channels: channels, ramp: sampleRate * rampMs / 1000, volume: 1, current: 1}
+}
+
+// SetVolume 设置音量（线性，0..4，1 为原始电平）
+func (g *GainStage) SetVolume(v float64) {
+	if v < 0 || math.IsNaN(v) {
+		v = 0
+	}
+	if v > maxSoftwareGain {
+		v = maxSoftwareGain
+	}
+	g.mu.Lock()
+	g.volume = v
+	g.retargetLocked()
+	g.mu.Unlock()
+}
+
+// Volume 返回用户音量
+func (g *GainStage) Volume() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.volume
+}
+
+// SetMuted 静音/取消静音
+func (g *GainStage) SetMuted(m bool) {
+	g.mu.Lock()
+	g.muted = m
+	g.retargetLocked()
+	g.mu.Unlock()
+}
+
+// Muted 是否静音
+func (g *GainStage) Muted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.muted
+}
+
+// SetDuck 设置闪避衰减（dB，<=0），0 取消闪避
+func (g *GainStage) SetDuck(db float64) {
+	if db > 0 || math.IsNaN(db) {
+		db = 0
+	}
+	g.mu.Lock()
+	g.duckDB = db
+	g.retargetLocked()
+	g.mu.Unlock()
+}
+
+// Target 返回综合音量、静音与闪避后的目标增益
+func (g *GainStage) Target() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.targetLocked()
+}
+
+// Process 原地对交错 PCM 施加增益
+func (g *GainStage) Process(pcm []float32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.left == 0 && g.current == 1 {
+		return
+	}
+	for i := 0; i+g.channels <= len(pcm); i += g.channels {
+		if g.left > 0 {
+			g.current += g.step
+			g.left--
+			if g.left == 0 {
+				g.current = g.targetLocked()
+			}
+		}
+		for ch := 0; ch < g.channels; ch++ {
+			v := pcm[i+ch] * float32(g.current)
+			if g.current > 1 {
+				v = softClip(v)
+			}
+			pcm[i+ch] = v
+		}
+	}
+}
+
+// softClip 在 softClipKnee 以下保持线性，以上用 tanh 平滑压缩到 ±1 以内
+func softClip(v float32) float32 {
+	x := math.Abs(float64(v))
+	if x <= softClipKnee {
+		return v
+	}
+	y := softClipKnee + (1-softClipKnee)*math.Tanh((x-softClipKnee)/(1-softClipKnee))
+	return float32(math.Copysign(y, float64(v)))
+}
+
+func (g *GainStage) targetLocked() float64 {
+	if g.muted {
+		return 0
+	}
+	return g.volume * dbToAmp(g.duckDB)
+}
+
+func (g *GainStage) retargetLocked() {
+	target := g.targetLocked()
+	if g.ramp <= 0 {
+		g.current, g.left = target, 0
+		return
+	}
+	g.step = (target - g.current) / float64(g.ramp)
+	g.left = g.ramp
+}
@@ -0,0 +1,73 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGainStageRamp(t *testing.T) {
+	g := NewGainStage(48000, 1, 10)
+	g.SetVolume(0.5)
+	pcm := make([]float32, 960)
+	for i := range pcm {
+		pcm[i] = 0.5
+	}
+	g.Process(pcm)
+	// 10ms 内从 1 线性降到 0.5，不应出现跳变
+	for i := 1; i < len(pcm); i++ {
+		if d := math.Abs(float64(pcm[i] - pcm[i-1])); d > 1e-3 {
+			t.Fatalf("step %v at sample %d", d, i)
+		}
+	}
+	if got := pcm[len(pcm)-1]; math.Abs(float64(got)-0.25) > 1e-6 {
+		t.Errorf("settled at %v, want 0.25", got)
+	}
+
+	g.SetMuted(true)
+	g.Process(make([]float32, 480))
+	pcm = []float32{0.5, -0.5}
+	g.Process(pcm)
+	if pcm[0] != 0 || pcm[1] != 0 {
+		t.Errorf("muted output %v", pcm)
+	}
+}
+
+func TestGainStageSoftClip(t *testing.T) {
+	g := NewGainStage(48000, 1, 0)
+	g.SetVolume(maxSoftwareGain)
+	pcm := sineWave(48000, 1000, 4800, 1, 0.9)
+	g.Process(pcm)
+	var peak float64
+	for _, v := range pcm {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	if peak > 1 {
+		t.Errorf("peak %.3f exceeds full scale", peak)
+	}
+
+	// 低电平信号仍按增益线性放大
+	quiet := []float32{0.1, -0.1}
+	g.Process(quiet)
+	if math.Abs(float64(quiet[0])-0.1*maxSoftwareGain) > 1e-6 || quiet[1] != -quiet[0] {
+		t.Errorf("quiet samples %v, want ±%v", quiet, 0.1*maxSoftwareGain)
+	}
+}
+
+func TestGainStageDuck(t *testing.T) {
+	g := NewGainStage(48000, 1, 0)
+	g.SetVolume(0.5)
+	g.SetDuck(-12)
+	if got, want := g.Target(), 0.5*dbToAmp(-12); math.Abs(got-want) > 1e-9 {
+		t.Errorf("ducked target %v, want %v", got, want)
+	}
+	// 正值视为不闪避
+	g.SetDuck(6)
+	if got := g.Target(); got != 0.5 {
+		t.Errorf("target %v after positive duck, want 0.5", got)
+	}
+	g.SetDuck(-12)
+	g.SetDuck(0)
+	if got := g.Target(); got != 0.5 {
+		t.Errorf("target %v after clearing duck, want 0.5", got)
+	}
+}
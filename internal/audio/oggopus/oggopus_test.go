@@ -0,0 +1,61 @@
+package oggopus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 24000, 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// TOC 0xf8：CELT 20ms 单帧；第二个包超过 255 字节，需多个段
+	pkts := [][]byte{{0xf8, 1, 2}, append([]byte{0xf8}, bytes.Repeat([]byte{9}, 600)...), {0xf8}}
+	for _, p := range pkts {
+		if err := w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WritePacket([]byte{}); err == nil {
+		t.Error("empty packet accepted")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Packets() != 3 || w.Duration48k() != 3*960 {
+		t.Errorf("packets %d duration %d", w.Packets(), w.Duration48k())
+	}
+
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Channels != 1 || s.PreSkip != 0 || len(s.Packets) != len(pkts) {
+		t.Fatalf("stream channels %d preskip %d packets %d", s.Channels, s.PreSkip, len(s.Packets))
+	}
+	for i := range pkts {
+		if !bytes.Equal(s.Packets[i], pkts[i]) {
+			t.Errorf("packet %d: %d bytes, want %d", i, len(s.Packets[i]), len(pkts[i]))
+		}
+	}
+}
+
+func TestPacketSamples48k(t *testing.T) {
+	for _, c := range []struct {
+		pkt  []byte
+		want int
+	}{
+		{[]byte{0x08}, 960},        // SILK 20ms
+		{[]byte{0x18}, 2880},       // SILK 60ms
+		{[]byte{0xf9}, 1920},       // CELT 20ms，两帧
+		{[]byte{0xfb, 0x03}, 2880}, // CELT 20ms，code 3 三帧
+		{[]byte{0xfb, 0x07}, 0},    // 超过 120ms
+		{nil, 0},
+	} {
+		if got := PacketSamples48k(c.pkt); got != c.want {
+			t.Errorf("%x: %d, want %d", c.pkt, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package oggopus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Stream 从 Ogg 容器中解出的 Opus 流
+type Stream struct {
+	Channels int
+	PreSkip  int // 解码后需丢弃的起始样本数（48kHz）
+	Packets  [][]byte
+}
+
+// Read 读取 Ogg/Opus 文件中第一个逻辑流的全部音频包（不含 OpusHead/OpusTags）
+func Read(r io.Reader) (*Stream, error) {
+	var (
+		serial  uint32
+		started bool
+		partial []byte // 跨页的包
+		packets [][]byte
+	)
+	for {
+		var hdr [27]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("读取 Ogg 页头失败: %w", err)
+		}
+		if string(hdr[0:4]) != "OggS" {
+			return nil, errors.New("不是 Ogg 文件或页同步丢失")
+		}
+		segs := make([]byte, hdr[26])
+		if _, err := io.ReadFull(r, segs); err != nil {
+			return nil, fmt.Errorf("读取 Ogg 段表失败: %w", err)
+		}
+		total := 0
+		for _, s := range segs {
+			total += int(s)
+		}
+		body := make([]byte, total)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("读取 Ogg 页数据失败: %w", err)
+		}
+
+		// 只取第一个逻辑流，忽略复用的其他流
+		pageSerial := binary.LittleEndian.Uint32(hdr[14:18])
+		if !started {
+			serial, started = pageSerial, true
+		} else if pageSerial != serial {
+			continue
+		}
+		// 续页标志未置位时丢弃残留的半包
+		if hdr[5]&oggContinued == 0 {
+			partial = nil
+		}
+		off := 0
+		for _, s := range segs {
+			partial = append(partial, body[off:off+int(s)]...)
+			off += int(s)
+			if s < 255 {
+				packets = append(packets, partial)
+				partial = nil
+			}
+		}
+		if hdr[5]&oggEOS != 0 {
+			break
+		}
+	}
+
+	if len(packets) < 2 {
+		return nil, errors.New("Ogg 流缺少 OpusHead/OpusTags 头")
+	}
+	head := packets[0]
+	if len(head) < 19 || !bytes.HasPrefix(head, []byte("OpusHead")) {
+		return nil, errors.New("不是 Ogg/Opus 流")
+	}
+	if !bytes.HasPrefix(packets[1], []byte("OpusTags")) {
+		return nil, errors.New("Ogg/Opus 缺少 OpusTags")
+	}
+	return &Stream{
+		Channels: int(head[9]),
+		PreSkip:  int(binary.LittleEndian.Uint16(head[10:12])),
+		Packets:  packets[2:],
+	}, nil
+}
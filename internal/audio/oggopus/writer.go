@@ -0,0 +1,191 @@
+// Package oggopus 纯 Go 的 Ogg/Opus 容器读写（RFC 7845），不依赖 libopus，只处理已编码的 Opus 包
+package oggopus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Writer 将 Opus 包原样封装为 Ogg/Opus 文件（RFC 7845）：
+// 首页 OpusHead、次页 OpusTags，之后每页一个音频包，granule position 为累计的 48kHz 样本数
+type Writer struct {
+	w       io.Writer
+	serial  uint32
+	seq     uint32
+	granule uint64
+	pending []byte // 最后一个包延迟写出，以便在 Close 时标记 EOS
+	packets int
+	closed  bool
+}
+
+// Ogg 页头标志
+const (
+	oggContinued = 0x01
+	oggBOS       = 0x02
+	oggEOS       = 0x04
+)
+
+// NewWriter 写入头页；sampleRate 记录为原始输入采样率，仅供播放器参考
+func NewWriter(w io.Writer, sampleRate, channels int, serial uint32) (*Writer, error) {
+	if channels < 1 || channels > 2 {
+		return nil, fmt.Errorf("不支持的 Opus 声道数: %d", channels)
+	}
+	o := &Writer{w: w, serial: serial}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = byte(channels)
+	// pre-skip 为 0：录制的是服务端已编码的包，无法得知其编码器延迟
+	binary.LittleEndian.PutUint32(head[12:], uint32(sampleRate))
+	// output gain 0、mapping family 0
+	if err := o.writePage(head, 0, oggBOS); err != nil {
+		return nil, err
+	}
+
+	vendor := "xiaozhi-client-go"
+	tags := make([]byte, 8+4+len(vendor)+4)
+	copy(tags, "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:], uint32(len(vendor)))
+	copy(tags[12:], vendor)
+	if err := o.writePage(tags, 0, 0); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WritePacket 追加一个 Opus 包
+func (o *Writer) WritePacket(pkt []byte) error {
+	if o.closed {
+		return errors.New("ogg writer 已关闭")
+	}
+	n := PacketSamples48k(pkt)
+	if n <= 0 {
+		return fmt.Errorf("无效的 Opus 包（%d 字节）", len(pkt))
+	}
+	if err := o.flushPending(0); err != nil {
+		return err
+	}
+	o.granule += uint64(n)
+	o.pending = append([]byte(nil), pkt...)
+	o.packets++
+	return nil
+}
+
+// Packets 已写入的音频包数
+func (o *Writer) Packets() int { return o.packets }
+
+// Duration48k 已写入音频的 48kHz 样本数
+func (o *Writer) Duration48k() uint64 { return o.granule }
+
+// Close 写出最后一页（EOS）；不关闭底层 io.Writer
+func (o *Writer) Close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	if o.pending == nil {
+		// 无音频时写一个空的 EOS 页结束逻辑流
+		return o.writePage(nil, o.granule, oggEOS)
+	}
+	return o.flushPending(oggEOS)
+}
+
+func (o *Writer) flushPending(flags byte) error {
+	if o.pending == nil {
+		return nil
+	}
+	pkt := o.pending
+	o.pending = nil
+	return o.writePage(pkt, o.granule, flags)
+}
+
+// writePage 单个包写成一页（包长不超过 255*255 字节，Opus 包远小于此）
+func (o *Writer) writePage(pkt []byte, granule uint64, flags byte) error {
+	nseg := 0
+	if pkt != nil {
+		nseg = len(pkt)/255 + 1
+	}
+	if nseg > 255 {
+		return fmt.Errorf("Opus 包过大: %d 字节", len(pkt))
+	}
+	page := make([]byte, 27+nseg+len(pkt))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.seq)
+	page[26] = byte(nseg)
+	for i := 0; i < nseg; i++ {
+		page[27+i] = 255
+	}
+	if nseg > 0 {
+		page[27+nseg-1] = byte(len(pkt) % 255)
+	}
+	copy(page[27+nseg:], pkt)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+	o.seq++
+	_, err := o.w.Write(page)
+	return err
+}
+
+// PacketSamples48k 按 TOC 字节计算包时长（48kHz 样本数），无效包返回 0
+func PacketSamples48k(pkt []byte) int {
+	if len(pkt) == 0 {
+		return 0
+	}
+	cfg := int(pkt[0] >> 3)
+	var frame int // 单帧样本数 @48kHz
+	switch {
+	case cfg < 12: // SILK：10/20/40/60ms
+		frame = []int{480, 960, 1920, 2880}[cfg%4]
+	case cfg < 16: // Hybrid：10/20ms
+		frame = []int{480, 960}[cfg%2]
+	default: // CELT：2.5/5/10/20ms
+		frame = []int{120, 240, 480, 960}[cfg%4]
+	}
+	var count int
+	switch pkt[0] & 0x03 {
+	case 0:
+		count = 1
+	case 1, 2:
+		count = 2
+	default:
+		if len(pkt) < 2 {
+			return 0
+		}
+		count = int(pkt[1] & 0x3F)
+	}
+	// 单包最长 120ms
+	if n := frame * count; n > 0 && n <= 5760 {
+		return n
+	}
+	return 0
+}
+
+// Ogg 使用非反射的 CRC-32（多项式 0x04C11DB7，初值 0）
+var oggCRCTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
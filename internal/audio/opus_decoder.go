@@ -14,8 +14,13 @@ type OpusDecoder struct {
 	sampleRate int
 	channels   int
 	frameSize  int // 每帧的样本数
+
+	pendingLost int // 待补偿的丢失帧数，见 MarkLost
 }
 
+// 一次最多补偿的丢失帧数，更长的中断直接跳过，避免输出大段合成音
+const maxConcealFrames = 5
+
 // NewOpusDecoder 创建新的 Opus 解码器
 func NewOpusDecoder(sampleRate, channels int) (*OpusDecoder, error) {
 	// 创建 Opus 解码器
@@ -61,6 +66,77 @@ func (d *OpusDecoder) DecodeFrame(opusData []byte) ([]byte, error) {
 	return pcmBytes, nil
 }
 
+// ConcealFrameToFloat32 丢包隐藏（PLC）：按上一帧时长生成一帧补偿音频
+func (d *OpusDecoder) ConcealFrameToFloat32() ([]float32, error) {
+	n, err := d.decoder.LastPacketDuration()
+	if err != nil || n <= 0 {
+		n = d.frameSize
+	}
+	pcm := make([]float32, n*d.channels)
+	if err := d.decoder.DecodePLCFloat32(pcm); err != nil {
+		return nil, fmt.Errorf("opus 丢包隐藏失败: %v", err)
+	}
+	return pcm, nil
+}
+
+// MarkLost 标记下一帧之前丢失了 n 帧。补偿音频在下一次 DecodeFrameWithRecovery 时生成：
+// 紧邻下一包的丢失帧优先用其带内 FEC 恢复，其余用 PLC
+func (d *OpusDecoder) MarkLost(n int) {
+	if n > 0 {
+		d.pendingLost += n
+	}
+}
+
+// DecodeFrameWithRecovery 解码一帧并在前面补上 MarkLost 标记的丢失帧；
+// 本帧损坏时以 PLC 音频代替，保证播放连续
+func (d *OpusDecoder) DecodeFrameWithRecovery(opusData []byte) ([]float32, error) {
+	var out []float32
+	if lost := d.pendingLost; lost > 0 {
+		d.pendingLost = 0
+		if lost > maxConcealFrames {
+			lost = maxConcealFrames
+		}
+		for i := 0; i < lost-1; i++ {
+			pcm, err := d.ConcealFrameToFloat32()
+			if err != nil {
+				break
+			}
+			out = append(out, pcm...)
+		}
+		if pcm, err := d.recoverFEC(opusData); err == nil {
+			out = append(out, pcm...)
+		} else if pcm, err := d.ConcealFrameToFloat32(); err == nil {
+			out = append(out, pcm...)
+		}
+	}
+
+	pcm, err := d.DecodeFrameToFloat32(opusData)
+	if err != nil {
+		plc, perr := d.ConcealFrameToFloat32()
+		if perr != nil {
+			return out, err
+		}
+		pcm = plc
+	}
+	return append(out, pcm...), nil
+}
+
+// recoverFEC 用 opusData 携带的带内 FEC 恢复其前一帧；无 FEC 数据时 libopus 退化为 PLC
+func (d *OpusDecoder) recoverFEC(opusData []byte) ([]float32, error) {
+	if len(opusData) == 0 {
+		return nil, fmt.Errorf("空的 Opus 数据")
+	}
+	n, err := d.decoder.LastPacketDuration()
+	if err != nil || n <= 0 {
+		n = d.frameSize
+	}
+	pcm := make([]float32, n*d.channels)
+	if err := d.decoder.DecodeFECFloat32(opusData, pcm); err != nil {
+		return nil, fmt.Errorf("opus FEC 恢复失败: %v", err)
+	}
+	return pcm, nil
+}
+
 // DecodeFrameToFloat32 解码 Opus 音频帧，直接返回 Float32 PCM（-1.0..1.0）
 func (d *OpusDecoder) DecodeFrameToFloat32(opusData []byte) ([]float32, error) {
 	if len(opusData) == 0 {
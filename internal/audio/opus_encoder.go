@@ -0,0 +1,181 @@
+package audio
+
+import (
+	"fmt"
+
+	"github.com/hraban/opus"
+)
+
+// EncoderConfig Opus 编码参数；帧长取协商的 audio_params
+type EncoderConfig struct {
+	SampleRate    int
+	Channels      int
+	FrameDuration int // 毫秒
+
+	// 始终为 libopus 默认的可变码率：hraban/opus 未暴露 OPUS_SET_VBR
+	Bitrate        int // bps，0 为自动
+	Complexity     int // 0-10
+	DTX            bool
+	InBandFEC      bool
+	PacketLossPerc int // 预期丢包率（%），启用 FEC 时据此分配冗余
+}
+
+// 单个 Opus 包的最大字节数
+const maxOpusPacket = 4000
+
+// OpusEncoder Opus 音频编码器：缓冲 PCM，按帧长切分后编码
+type OpusEncoder struct {
+	encoder    *opus.Encoder
+	sampleRate int
+	channels   int
+	frameSize  int // 每帧的样本数（含全部声道）
+	buf        []float32
+}
+
+// NewOpusEncoder 创建新的 Opus 编码器
+func NewOpusEncoder(cfg EncoderConfig) (*OpusEncoder, error) {
+	if cfg.Channels <= 0 {
+		cfg.Channels = 1
+	}
+	switch cfg.FrameDuration {
+	case 10, 20, 40, 60, 80, 100, 120:
+	default:
+		return nil, fmt.Errorf("不支持的 Opus 帧长: %dms", cfg.FrameDuration)
+	}
+
+	encoder, err := opus.NewEncoder(cfg.SampleRate, cfg.Channels, opus.AppVoIP)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Opus 编码器失败: %v", err)
+	}
+	if cfg.Bitrate > 0 {
+		err = encoder.SetBitrate(cfg.Bitrate)
+	} else {
+		err = encoder.SetBitrateToAuto()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("设置码率失败: %v", err)
+	}
+	if err := encoder.SetComplexity(cfg.Complexity); err != nil {
+		return nil, fmt.Errorf("设置复杂度失败: %v", err)
+	}
+	if err := encoder.SetDTX(cfg.DTX); err != nil {
+		return nil, fmt.Errorf("设置 DTX 失败: %v", err)
+	}
+	if err := encoder.SetInBandFEC(cfg.InBandFEC); err != nil {
+		return nil, fmt.Errorf("设置带内 FEC 失败: %v", err)
+	}
+	if cfg.InBandFEC {
+		if err := encoder.SetPacketLossPerc(cfg.PacketLossPerc); err != nil {
+			return nil, fmt.Errorf("设置丢包率失败: %v", err)
+		}
+	}
+
+	return &OpusEncoder{
+		encoder:    encoder,
+		sampleRate: cfg.SampleRate,
+		channels:   cfg.Channels,
+		frameSize:  cfg.SampleRate * cfg.FrameDuration / 1000 * cfg.Channels,
+	}, nil
+}
+
+// Write 追加 PCM 样本（Float32，交错声道），返回凑满的完整帧的编码结果
+func (e *OpusEncoder) Write(samples []float32) ([][]byte, error) {
+	e.buf = append(e.buf, samples...)
+	var packets [][]byte
+	for len(e.buf) >= e.frameSize {
+		pkt, err := e.encode(e.buf[:e.frameSize])
+		e.buf = e.buf[e.frameSize:]
+		if err != nil {
+			return packets, err
+		}
+		if pkt != nil {
+			packets = append(packets, pkt)
+		}
+	}
+	return packets, nil
+}
+
+// Flush 将缓冲中不足一帧的剩余样本补零后编码（listen stop 时调用），无剩余时返回 nil
+func (e *OpusEncoder) Flush() ([]byte, error) {
+	if len(e.buf) == 0 {
+		return nil, nil
+	}
+	frame := make([]float32, e.frameSize)
+	copy(frame, e.buf)
+	e.buf = nil
+	return e.encode(frame)
+}
+
+// Reset 丢弃缓冲并重置编码器状态（新一轮监听开始时调用）
+func (e *OpusEncoder) Reset() {
+	e.buf = nil
+	_ = e.encoder.Reset()
+}
+
+func (e *OpusEncoder) encode(frame []float32) ([]byte, error) {
+	out := make([]byte, maxOpusPacket)
+	n, err := e.encoder.EncodeFloat32(frame, out)
+	if err != nil {
+		return nil, fmt.Errorf("Opus 编码失败: %v", err)
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	return out[:n], nil
+}
+
+// GetFrameSize 获取每帧的样本数
+func (e *OpusEncoder) GetFrameSize() int {
+	return e.frameSize
+}
+
+// GetSampleRate 获取采样率
+func (e *OpusEncoder) GetSampleRate() int {
+	return e.sampleRate
+}
+
+// GetChannels 获取声道数
+func (e *OpusEncoder) GetChannels() int {
+	return e.channels
+}
+
+// PCM16Encoder 将整段 PCM16 按协商参数编码为 Opus 帧，实现 client.PCMEncoder（供 Client.Speak 使用）
+type PCM16Encoder struct {
+	Encoder EncoderConfig // 码率等编码参数；采样率/声道/帧长取协商值。零值使用 24kbps、复杂度 5
+}
+
+// EncodePCM16 编码交错 PCM16，末尾不足一帧的部分补零
+func (e PCM16Encoder) EncodePCM16(pcm []int16, sampleRate, channels, frameDurationMs int) ([][]byte, error) {
+	samples := make([]float32, len(pcm))
+	for i, v := range pcm {
+		samples[i] = float32(v) / 32768
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+	return encodeFrames(samples, e.Encoder, sampleRate, channels, frameDurationMs)
+}
+
+// encodeFrames 以 cfg 的码率等参数和给定的采样率/声道/帧长编码整段 PCM，末帧补零
+func encodeFrames(samples []float32, cfg EncoderConfig, sampleRate, channels, frameDurationMs int) ([][]byte, error) {
+	if cfg == (EncoderConfig{}) {
+		cfg = EncoderConfig{Bitrate: 24000, Complexity: 5}
+	}
+	cfg.SampleRate, cfg.Channels, cfg.FrameDuration = sampleRate, channels, frameDurationMs
+	enc, err := NewOpusEncoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	frames, err := enc.Write(samples)
+	if err != nil {
+		return nil, err
+	}
+	tail, err := enc.Flush()
+	if err != nil {
+		return nil, err
+	}
+	if tail != nil {
+		frames = append(frames, tail)
+	}
+	return frames, nil
+}
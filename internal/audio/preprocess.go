@@ -0,0 +1,261 @@
+package audio
+
+import "math"
+
+// Preprocessor 麦克风预处理链（编码前）：高通滤波 → 自动增益（AGC）→ 噪声门 → 峰值限幅。
+// 输入为单声道 Float32 PCM，各级可单独关闭，Process 同时返回各级电平供界面显示输入电平表。
+
+// PreprocessConfig 预处理参数
+type PreprocessConfig struct {
+	SampleRate int
+
+	HighPassHz float64 // 高通截止频率，去除直流与低频风扇声；0 关闭
+
+	AGC          bool
+	AGCTargetDB  float64 // 目标电平（dBFS RMS），默认 -20
+	AGCMaxGainDB float64 // 最大增益，默认 24
+	AGCMinGainDB float64 // 最小增益（可衰减过响输入），默认 -12
+	AGCAttackMs  float64 // 增益下降速度（输入变响时），默认 20
+	AGCReleaseMs float64 // 增益上升速度（输入变轻时），默认 500
+
+	Gate          bool
+	GateThreshold float64 // 门限（dBFS，按高通后电平判断），默认 -50
+	GateRangeDB   float64 // 关门时的衰减量，默认 -40
+	GateHoldMs    float64 // 电平低于门限后保持打开的时长，默认 200
+	GateReleaseMs float64 // 关门渐变时长，默认 100
+
+	Limiter          bool
+	LimiterCeilingDB float64 // 峰值上限（dBFS），默认 -1
+	LimiterReleaseMs float64 // 默认 50
+}
+
+// DefaultPreprocessConfig 返回默认参数（全部开启）
+func DefaultPreprocessConfig(sampleRate int) PreprocessConfig {
+	return PreprocessConfig{
+		SampleRate: sampleRate,
+		HighPassHz: 80,
+
+		AGC:          true,
+		AGCTargetDB:  -20,
+		AGCMaxGainDB: 24,
+		AGCMinGainDB: -12,
+		AGCAttackMs:  20,
+		AGCReleaseMs: 500,
+
+		Gate:          true,
+		GateThreshold: -50,
+		GateRangeDB:   -40,
+		GateHoldMs:    200,
+		GateReleaseMs: 100,
+
+		Limiter:          true,
+		LimiterCeilingDB: -1,
+		LimiterReleaseMs: 50,
+	}
+}
+
+// StageLevels 一次 Process 中各级输出的 RMS 电平（dBFS）与状态
+type StageLevels struct {
+	InputDB    float64
+	HighPassDB float64
+	AGCDB      float64
+	GateDB     float64
+	OutputDB   float64
+	PeakDB     float64 // 输出峰值
+	GainDB     float64 // 当前 AGC 增益
+	GateOpen   bool
+	Limited    bool // 本次是否触发限幅
+}
+
+type Preprocessor struct {
+	cfg PreprocessConfig
+
+	// 高通 biquad 系数与状态
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+
+	// AGC
+	agcEnv     float64 // 电平包络（均方）
+	agcGain    float64 // 线性增益
+	agcEnvCoef float64
+	agcAtk     float64
+	agcRel     float64
+
+	// 噪声门
+	gateEnv     float64
+	gateGain    float64
+	gateHold    int // 剩余保持样本数
+	gateHoldN   int
+	gateEnvCoef float64
+	gateRel     float64
+	gateAtk     float64
+
+	// 限幅
+	limGain float64
+	limRel  float64
+}
+
+// NewPreprocessor 创建预处理链；参数通常在 DefaultPreprocessConfig 基础上修改
+func NewPreprocessor(cfg PreprocessConfig) *Preprocessor {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 16000
+	}
+	p := &Preprocessor{cfg: cfg}
+	sr := float64(cfg.SampleRate)
+	if cfg.HighPassHz > 0 {
+		// RBJ 二阶巴特沃斯高通
+		w0 := 2 * math.Pi * cfg.HighPassHz / sr
+		q := 1 / math.Sqrt2
+		alpha := math.Sin(w0) / (2 * q)
+		cosw := math.Cos(w0)
+		a0 := 1 + alpha
+		p.b0 = (1 + cosw) / 2 / a0
+		p.b1 = -(1 + cosw) / a0
+		p.b2 = (1 + cosw) / 2 / a0
+		p.a1 = -2 * cosw / a0
+		p.a2 = (1 - alpha) / a0
+	}
+	p.agcEnvCoef = timeCoef(100, sr)
+	p.agcAtk = timeCoef(cfg.AGCAttackMs, sr)
+	p.agcRel = timeCoef(cfg.AGCReleaseMs, sr)
+	p.gateEnvCoef = timeCoef(10, sr)
+	p.gateAtk = timeCoef(2, sr)
+	p.gateRel = timeCoef(cfg.GateReleaseMs, sr)
+	p.gateHoldN = int(cfg.GateHoldMs * sr / 1000)
+	p.limRel = timeCoef(cfg.LimiterReleaseMs, sr)
+	p.Reset()
+	return p
+}
+
+// Reset 清空滤波器与增益状态
+func (p *Preprocessor) Reset() {
+	p.x1, p.x2, p.y1, p.y2 = 0, 0, 0, 0
+	p.agcEnv = 0
+	p.agcGain = 1
+	p.gateEnv = 0
+	p.gateGain = 1
+	p.gateHold = 0
+	p.limGain = 1
+}
+
+// Process 处理一段样本，返回新切片（不修改输入）与各级电平
+func (p *Preprocessor) Process(in []float32) ([]float32, StageLevels) {
+	var lv StageLevels
+	if len(in) == 0 {
+		lv.GainDB = ampToDB(p.agcGain)
+		lv.GateOpen = p.gateGain > 0.5
+		return nil, lv
+	}
+	var sIn, sHP, sAGC, sGate, sOut, peak float64
+	out := make([]float32, len(in))
+	ceiling := dbToAmp(p.cfg.LimiterCeilingDB)
+	gateFloor := dbToAmp(p.cfg.GateRangeDB)
+	gateThr := dbToAmp(p.cfg.GateThreshold)
+
+	for i, s := range in {
+		x := float64(s)
+		sIn += x * x
+
+		// 高通
+		if p.cfg.HighPassHz > 0 {
+			y := p.b0*x + p.b1*p.x1 + p.b2*p.x2 - p.a1*p.y1 - p.a2*p.y2
+			p.x2, p.x1 = p.x1, x
+			p.y2, p.y1 = p.y1, y
+			x = y
+		}
+		sHP += x * x
+
+		// 噪声门判定基于高通后的电平，避免 AGC 放大后误开门
+		p.gateEnv = p.gateEnvCoef*p.gateEnv + (1-p.gateEnvCoef)*math.Abs(x)
+		open := !p.cfg.Gate || p.gateEnv >= gateThr
+		if open {
+			p.gateHold = p.gateHoldN
+		} else if p.gateHold > 0 {
+			p.gateHold--
+			open = true
+		}
+
+		// AGC：仅在门打开（有语音）时调整增益，避免静音段放大底噪
+		if p.cfg.AGC {
+			p.agcEnv = p.agcEnvCoef*p.agcEnv + (1-p.agcEnvCoef)*x*x
+			if open && p.agcEnv > 1e-10 {
+				want := dbToAmp(p.cfg.AGCTargetDB) / math.Sqrt(p.agcEnv)
+				want = math.Min(math.Max(want, dbToAmp(p.cfg.AGCMinGainDB)), dbToAmp(p.cfg.AGCMaxGainDB))
+				coef := p.agcRel
+				if want < p.agcGain {
+					coef = p.agcAtk
+				}
+				p.agcGain = coef*p.agcGain + (1-coef)*want
+			}
+			x *= p.agcGain
+		}
+		sAGC += x * x
+
+		// 噪声门
+		if p.cfg.Gate {
+			target, coef := 1.0, p.gateAtk
+			if !open {
+				target, coef = gateFloor, p.gateRel
+			}
+			p.gateGain = coef*p.gateGain + (1-coef)*target
+			x *= p.gateGain
+		}
+		sGate += x * x
+
+		// 峰值限幅：瞬时压低、缓慢恢复
+		if p.cfg.Limiter {
+			if a := math.Abs(x) * p.limGain; a > ceiling {
+				p.limGain = ceiling / math.Abs(x)
+				lv.Limited = true
+			} else {
+				p.limGain = p.limRel*p.limGain + (1 - p.limRel)
+			}
+			x *= p.limGain
+		}
+		if x > 1 {
+			x = 1
+		} else if x < -1 {
+			x = -1
+		}
+		sOut += x * x
+		if a := math.Abs(x); a > peak {
+			peak = a
+		}
+		out[i] = float32(x)
+	}
+
+	n := float64(len(in))
+	lv.InputDB = powToDB(sIn / n)
+	lv.HighPassDB = powToDB(sHP / n)
+	lv.AGCDB = powToDB(sAGC / n)
+	lv.GateDB = powToDB(sGate / n)
+	lv.OutputDB = powToDB(sOut / n)
+	lv.PeakDB = ampToDB(peak)
+	lv.GainDB = ampToDB(p.agcGain)
+	lv.GateOpen = p.gateGain > 0.5
+	return out, lv
+}
+
+// timeCoef 一阶平滑系数，ms 为时间常数
+func timeCoef(ms, sampleRate float64) float64 {
+	if ms <= 0 {
+		return 0
+	}
+	return math.Exp(-1000 / (ms * sampleRate))
+}
+
+func dbToAmp(db float64) float64 { return math.Pow(10, db/20) }
+
+func ampToDB(a float64) float64 {
+	if a < 1e-6 {
+		return -120
+	}
+	return 20 * math.Log10(a)
+}
+
+func powToDB(p float64) float64 {
+	if p < 1e-12 {
+		return -120
+	}
+	return 10 * math.Log10(p)
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// onlyStage 仅开启 enable 所设置的一级，其余参数取默认值
+func onlyStage(enable func(*PreprocessConfig)) *Preprocessor {
+	cfg := DefaultPreprocessConfig(16000)
+	cfg.HighPassHz, cfg.AGC, cfg.Gate, cfg.Limiter = 0, false, false, false
+	enable(&cfg)
+	return NewPreprocessor(cfg)
+}
+
+// runPre 以 20ms 一块送入 x，返回拼接后的输出与最后一块的电平
+func runPre(p *Preprocessor, x []float32) ([]float32, StageLevels) {
+	var out []float32
+	var lv StageLevels
+	for i := 0; i < len(x); i += 320 {
+		end := i + 320
+		if end > len(x) {
+			end = len(x)
+		}
+		var y []float32
+		y, lv = p.Process(x[i:end])
+		out = append(out, y...)
+	}
+	return out, lv
+}
+
+func TestPreprocessHighPassRemovesDC(t *testing.T) {
+	p := onlyStage(func(c *PreprocessConfig) { c.HighPassHz = 80 })
+	x := sineWave(16000, 1000, 16000, 1, 0.1)
+	for i := range x {
+		x[i] += 0.5
+	}
+	out, _ := runPre(p, x)
+	// 跳过前 100ms 的暂态
+	tail := out[1600:]
+	var mean float64
+	for _, v := range tail {
+		mean += float64(v)
+	}
+	mean /= float64(len(tail))
+	if math.Abs(mean) > 1e-3 {
+		t.Errorf("dc after high-pass %.5f", mean)
+	}
+	if got := toneLevel(tail, 16000, 1000); math.Abs(got-0.1) > 0.005 {
+		t.Errorf("1kHz amplitude %.4f, want 0.1", got)
+	}
+}
+
+func TestPreprocessAGCConverges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name   string
+		inDB   float64
+		gainDB float64
+	}{
+		{"quiet input boosted to target", -40, 20},
+		{"boost capped at max gain", -60, 24},
+		{"loud input capped at min gain", -6, -12},
+	} {
+		p := onlyStage(func(c *PreprocessConfig) { c.AGC = true })
+		_, lv := runPre(p, noise(r, 3*16000, tc.inDB))
+		if math.Abs(lv.GainDB-tc.gainDB) > 1 {
+			t.Errorf("%s: gain %.1f dB, want %.1f", tc.name, lv.GainDB, tc.gainDB)
+		}
+		if want := tc.inDB + tc.gainDB; math.Abs(lv.OutputDB-want) > 1.5 {
+			t.Errorf("%s: output %.1f dBFS, want %.1f", tc.name, lv.OutputDB, want)
+		}
+	}
+}
+
+func TestPreprocessGateClosesBelowThreshold(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	p := onlyStage(func(c *PreprocessConfig) { c.Gate = true })
+	_, lv := runPre(p, noise(r, 8000, -30))
+	if !lv.GateOpen || math.Abs(lv.OutputDB+30) > 1 {
+		t.Fatalf("speech: open %v, output %.1f dBFS", lv.GateOpen, lv.OutputDB)
+	}
+	// 低于门限后先保持 200ms
+	_, lv = runPre(p, noise(r, 1600, -70))
+	if !lv.GateOpen {
+		t.Error("gate closed during hold")
+	}
+	// 保持结束后按 100ms 时间常数渐变到 GateRangeDB
+	_, lv = runPre(p, noise(r, 24000, -70))
+	if lv.GateOpen {
+		t.Error("gate still open below threshold")
+	}
+	if lv.OutputDB > -70-38 {
+		t.Errorf("closed gate output %.1f dBFS, want about %.0f", lv.OutputDB, -70-40.0)
+	}
+}
+
+func TestPreprocessLimiterCeiling(t *testing.T) {
+	p := onlyStage(func(c *PreprocessConfig) { c.Limiter = true })
+	_, lv := runPre(p, sineWave(16000, 440, 4800, 1, 1))
+	if !lv.Limited || lv.PeakDB > -1+1e-6 {
+		t.Errorf("full-scale input: limited %v, peak %.3f dBFS, want <= -1", lv.Limited, lv.PeakDB)
+	}
+	// 恢复后低电平信号原样通过
+	runPre(p, make([]float32, 16000))
+	quiet := sineWave(16000, 440, 3200, 1, 0.1)
+	out, lv := runPre(p, quiet)
+	if lv.Limited {
+		t.Error("quiet input limited")
+	}
+	for i := range out {
+		if math.Abs(float64(out[i]-quiet[i])) > 1e-6 {
+			t.Fatalf("sample %d: %v, want %v", i, out[i], quiet[i])
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"myproject/internal/audio/oggopus"
+)
+
+// Recorder 录制下行 TTS 音频，便于附在问题报告中：
+// ogg 格式原样封装收到的 Opus 包（无损），wav 格式写入解码后的 PCM。
+// 按会话或按 TTS 句子切分文件，每个文件关闭时通过 OnSaved 回调（用于写入存储）。
+
+// 录制格式
+const (
+	RecordOgg = "ogg"
+	RecordWAV = "wav"
+)
+
+// 切分方式
+const (
+	SplitSession  = "session"
+	SplitSentence = "sentence"
+)
+
+// RecorderConfig 录制参数
+type RecorderConfig struct {
+	Dir    string // 输出目录，文件写在 Dir/<session_id>/ 下
+	Format string // RecordOgg（默认）或 RecordWAV
+	Split  string // SplitSession（默认）或 SplitSentence
+}
+
+// Recording 一个已完成的录音文件
+type Recording struct {
+	SessionID  string
+	Sentence   string // 按句切分时为句子文本
+	Path       string
+	Format     string
+	SampleRate int
+	Channels   int
+	Frames     int // Opus 包数或 PCM 采样帧数
+	Duration   time.Duration
+	StartedAt  time.Time
+}
+
+type Recorder struct {
+	cfg RecorderConfig
+
+	// OnSaved 文件关闭后回调（在调用方 goroutine 中同步执行）
+	OnSaved func(rec Recording)
+
+	mu       sync.Mutex
+	session  string
+	sentence string
+	seq      int
+	cur      *recSegment
+}
+
+type recSegment struct {
+	rec Recording
+	f   *os.File
+	ogg *oggopus.Writer
+	wav *WAVWriter
+}
+
+// NewRecorder 创建录制器；目录在首个文件写入时创建
+func NewRecorder(cfg RecorderConfig) (*Recorder, error) {
+	switch cfg.Format {
+	case "":
+		cfg.Format = RecordOgg
+	case RecordOgg, RecordWAV:
+	default:
+		return nil, fmt.Errorf("不支持的录制格式: %s", cfg.Format)
+	}
+	switch cfg.Split {
+	case "":
+		cfg.Split = SplitSession
+	case SplitSession, SplitSentence:
+	default:
+		return nil, fmt.Errorf("不支持的切分方式: %s", cfg.Split)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "recordings"
+	}
+	return &Recorder{cfg: cfg}, nil
+}
+
+// Config 返回录制参数
+func (r *Recorder) Config() RecorderConfig { return r.cfg }
+
+// SetSession 会话变化时结束当前文件
+func (r *Recorder) SetSession(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == r.session {
+		return nil
+	}
+	r.session = id
+	return r.finishLocked()
+}
+
+// SentenceStart TTS 开始新句子；按句切分时结束上一句的文件
+func (r *Recorder) SentenceStart(text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sentence = text
+	if r.cfg.Split != SplitSentence {
+		return nil
+	}
+	return r.finishLocked()
+}
+
+// TTSStop 本轮播报结束；按句切分时结束当前文件
+func (r *Recorder) TTSStop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sentence = ""
+	if r.cfg.Split != SplitSentence {
+		return nil
+	}
+	return r.finishLocked()
+}
+
+// WriteOpus 记录收到的 Opus 包（仅 ogg 格式）；sampleRate/channels 为协商的下行参数
+func (r *Recorder) WriteOpus(pkt []byte, sampleRate, channels int) error {
+	if r.cfg.Format != RecordOgg || len(pkt) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seg, err := r.segmentLocked(sampleRate, channels)
+	if err != nil {
+		return err
+	}
+	if err := seg.ogg.WritePacket(pkt); err != nil {
+		return err
+	}
+	seg.rec.Frames = seg.ogg.Packets()
+	seg.rec.Duration = time.Duration(seg.ogg.Duration48k()) * time.Second / 48000
+	return nil
+}
+
+// WritePCM 记录解码后的 PCM（仅 wav 格式）
+func (r *Recorder) WritePCM(pcm []float32, sampleRate, channels int) error {
+	if r.cfg.Format != RecordWAV || len(pcm) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seg, err := r.segmentLocked(sampleRate, channels)
+	if err != nil {
+		return err
+	}
+	if err := seg.wav.WriteFloat32(pcm); err != nil {
+		return err
+	}
+	seg.rec.Frames = seg.wav.Frames()
+	seg.rec.Duration = time.Duration(seg.rec.Frames) * time.Second / time.Duration(sampleRate)
+	return nil
+}
+
+// Close 结束当前文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finishLocked()
+}
+
+// segmentLocked 返回当前文件，不存在或音频参数变化时新建
+func (r *Recorder) segmentLocked(sampleRate, channels int) (*recSegment, error) {
+	if seg := r.cur; seg != nil {
+		if seg.rec.SampleRate == sampleRate && seg.rec.Channels == channels {
+			return seg, nil
+		}
+		if err := r.finishLocked(); err != nil {
+			return nil, err
+		}
+	}
+	session := r.session
+	if session == "" {
+		session = "no-session"
+	}
+	dir := filepath.Join(r.cfg.Dir, sanitizeFileName(session))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建录音目录失败: %w", err)
+	}
+	now := time.Now()
+	r.seq++
+	path := filepath.Join(dir, fmt.Sprintf("%s-%03d.%s", now.Format("20060102-150405.000"), r.seq, r.cfg.Format))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建录音文件失败: %w", err)
+	}
+	seg := &recSegment{f: f, rec: Recording{
+		SessionID: r.session, Path: path, Format: r.cfg.Format,
+		SampleRate: sampleRate, Channels: channels, StartedAt: now,
+	}}
+	if r.cfg.Split == SplitSentence {
+		seg.rec.Sentence = r.sentence
+	}
+	if r.cfg.Format == RecordOgg {
+		seg.ogg, err = oggopus.NewWriter(f, sampleRate, channels, uint32(now.UnixNano()))
+	} else {
+		seg.wav, err = NewWAVWriter(f, sampleRate, channels)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	r.cur = seg
+	return seg, nil
+}
+
+func (r *Recorder) finishLocked() error {
+	seg := r.cur
+	if seg == nil {
+		return nil
+	}
+	r.cur = nil
+	var err error
+	if seg.ogg != nil {
+		err = seg.ogg.Close()
+	} else {
+		err = seg.wav.Close()
+	}
+	if cerr := seg.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("写入录音文件失败: %w", err)
+	}
+	if r.OnSaved != nil {
+		r.OnSaved(seg.rec)
+	}
+	return nil
+}
+
+// sanitizeFileName 替换路径中不安全的字符
+func sanitizeFileName(s string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			return c
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// Resampler 多相（polyphase）加窗 sinc 重采样器，支持任意输入/输出采样率。
+// 以流式方式处理交错多声道 PCM，跨调用保留滤波历史，分块输入与整段输入结果一致。
+type Resampler struct {
+	inRate   int
+	outRate  int
+	channels int
+
+	l, m     int // 输出/输入采样率之比化简为 l/m
+	halfTaps int // 单侧抽头数；降采样时按 inRate/outRate 放大，使过渡带相对输出采样率保持不变
+	taps     int // 每相抽头数
+	phases   int // 系数表相数；l 过大时量化相位并在相邻相之间线性插值
+	coeffs   [][]float32
+
+	buf   [][]float32 // 各声道待处理样本（含 taps-1 个历史样本）
+	pos   int         // 下一个输出样本在 buf 中的起始下标
+	phase int         // 下一个输出样本的分数相位（0..l-1）
+}
+
+const (
+	resamplerHalfTaps  = 16   // 升采样时的单侧抽头数，越大过渡带越窄
+	resamplerMaxPhases = 1024 // 系数表最多相数
+	resamplerRolloff   = 0.94 // 截止频率相对奈奎斯特频率的比例
+	resamplerKaiserB   = 8.6  // Kaiser 窗 beta，阻带约 -90dB
+)
+
+// NewResampler 创建重采样器；inRate == outRate 时直接透传
+func NewResampler(inRate, outRate, channels int) (*Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, fmt.Errorf("无效的采样率: %d -> %d", inRate, outRate)
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("无效的声道数: %d", channels)
+	}
+	g := gcd(inRate, outRate)
+	r := &Resampler{
+		inRate:   inRate,
+		outRate:  outRate,
+		channels: channels,
+		l:        outRate / g,
+		m:        inRate / g,
+		halfTaps: resamplerHalfTaps,
+	}
+	if outRate < inRate {
+		r.halfTaps = int(math.Ceil(float64(resamplerHalfTaps) * float64(inRate) / float64(outRate)))
+	}
+	r.taps = 2 * r.halfTaps
+	r.phases = r.l
+	if r.phases > resamplerMaxPhases {
+		r.phases = resamplerMaxPhases
+	}
+	r.buildCoeffs()
+	r.Reset()
+	return r, nil
+}
+
+// buildCoeffs 生成 phases+1 组系数（末组用于插值），每组归一化为单位直流增益
+func (r *Resampler) buildCoeffs() {
+	cutoff := 0.5 * resamplerRolloff // 以输入采样率为单位的截止频率
+	if r.outRate < r.inRate {
+		cutoff *= float64(r.outRate) / float64(r.inRate)
+	}
+	r.coeffs = make([][]float32, r.phases+1)
+	for p := 0; p <= r.phases; p++ {
+		frac := float64(p) / float64(r.phases)
+		h := make([]float64, r.taps)
+		sum := 0.0
+		for j := range h {
+			// 输出点位于 buf[pos+halfTaps-1+frac]
+			d := float64(j-(r.halfTaps-1)) - frac
+			h[j] = 2 * cutoff * sinc(2*cutoff*d) * kaiser(d/float64(r.halfTaps), resamplerKaiserB)
+			sum += h[j]
+		}
+		c := make([]float32, r.taps)
+		for j := range h {
+			c[j] = float32(h[j] / sum)
+		}
+		r.coeffs[p] = c
+	}
+}
+
+// Reset 清空滤波历史（新的音频流开始时调用）
+func (r *Resampler) Reset() {
+	r.buf = make([][]float32, r.channels)
+	for ch := range r.buf {
+		// 预填 halfTaps-1 个零，使首个输出样本与首个输入样本对齐
+		r.buf[ch] = make([]float32, r.halfTaps-1)
+	}
+	r.pos, r.phase = 0, 0
+}
+
+// Process 重采样交错 Float32 PCM，返回当前可输出的样本（末尾约 halfTaps 个输入样本留待下次）
+func (r *Resampler) Process(in []float32) []float32 {
+	if r.inRate == r.outRate {
+		return append([]float32(nil), in...)
+	}
+	frames := len(in) / r.channels
+	for ch := 0; ch < r.channels; ch++ {
+		b := r.buf[ch]
+		for i := 0; i < frames; i++ {
+			b = append(b, in[i*r.channels+ch])
+		}
+		r.buf[ch] = b
+	}
+	return r.drain()
+}
+
+// Flush 以静音补齐尾部，输出缓冲中剩余的样本，并重置状态
+func (r *Resampler) Flush() []float32 {
+	if r.inRate == r.outRate {
+		return nil
+	}
+	for ch := range r.buf {
+		r.buf[ch] = append(r.buf[ch], make([]float32, r.halfTaps)...)
+	}
+	out := r.drain()
+	r.Reset()
+	return out
+}
+
+// ProcessInt16 重采样交错 PCM16
+func (r *Resampler) ProcessInt16(in []int16) []int16 {
+	f := make([]float32, len(in))
+	for i, s := range in {
+		f[i] = float32(s) / 32768
+	}
+	return floatToInt16(r.Process(f))
+}
+
+// FlushInt16 同 Flush，输出 PCM16
+func (r *Resampler) FlushInt16() []int16 {
+	return floatToInt16(r.Flush())
+}
+
+// OutputLen 估算 inFrames 个输入帧对应的输出帧数
+func (r *Resampler) OutputLen(inFrames int) int {
+	return int(int64(inFrames) * int64(r.l) / int64(r.m))
+}
+
+// InRate 输入采样率
+func (r *Resampler) InRate() int { return r.inRate }
+
+// OutRate 输出采样率
+func (r *Resampler) OutRate() int { return r.outRate }
+
+// drain 计算所有窗口已凑满的输出样本，丢弃已消费的输入
+func (r *Resampler) drain() []float32 {
+	avail := len(r.buf[0])
+	out := make([]float32, 0, (r.OutputLen(avail-r.pos)+1)*r.channels)
+	for r.pos+r.taps <= avail {
+		c := r.phaseCoeffs(r.phase)
+		for ch := 0; ch < r.channels; ch++ {
+			win := r.buf[ch][r.pos : r.pos+r.taps]
+			var acc float32
+			for j, v := range win {
+				acc += v * c[j]
+			}
+			out = append(out, acc)
+		}
+		r.phase += r.m
+		r.pos += r.phase / r.l
+		r.phase %= r.l
+	}
+	// 保留未消费的样本作为下次的历史
+	for ch := range r.buf {
+		n := copy(r.buf[ch], r.buf[ch][r.pos:])
+		r.buf[ch] = r.buf[ch][:n]
+	}
+	r.pos = 0
+	return out
+}
+
+// phaseCoeffs 返回分数相位 phase/l 对应的系数
+func (r *Resampler) phaseCoeffs(phase int) []float32 {
+	if r.phases == r.l {
+		return r.coeffs[phase]
+	}
+	x := float64(phase) * float64(r.phases) / float64(r.l)
+	i := int(x)
+	t := float32(x - float64(i))
+	a, b := r.coeffs[i], r.coeffs[i+1]
+	c := make([]float32, r.taps)
+	for j := range c {
+		c[j] = a[j] + (b[j]-a[j])*t
+	}
+	return c
+}
+
+func floatToInt16(f []float32) []int16 {
+	out := make([]int16, len(f))
+	for i, v := range f {
+		s := math.Round(float64(v) * 32768)
+		if s > math.MaxInt16 {
+			s = math.MaxInt16
+		} else if s < math.MinInt16 {
+			s = math.MinInt16
+		}
+		out[i] = int16(s)
+	}
+	return out
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser Kaiser 窗，x ∈ [-1, 1]
+func kaiser(x, beta float64) float64 {
+	if x < -1 || x > 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 第一类零阶修正贝塞尔函数（级数展开）
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < 1e-12*sum {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
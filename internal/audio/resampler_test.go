@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func sineWave(rate, freq, frames, channels int, amp float64) []float32 {
+	out := make([]float32, frames*channels)
+	for i := 0; i < frames; i++ {
+		v := float32(amp * math.Sin(2*math.Pi*float64(freq)*float64(i)/float64(rate)))
+		for ch := 0; ch < channels; ch++ {
+			out[i*channels+ch] = v
+		}
+	}
+	return out
+}
+
+// toneLevel 用 Goertzel 算法测量 freq 处的幅度（跳过首尾过渡段）
+func toneLevel(x []float32, rate, freq int) float64 {
+	skip := len(x) / 10
+	x = x[skip : len(x)-skip]
+	w := 2 * math.Pi * float64(freq) / float64(rate)
+	coeff := 2 * math.Cos(w)
+	var s1, s2 float64
+	for _, v := range x {
+		s0 := float64(v) + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	power := s1*s1 + s2*s2 - coeff*s1*s2
+	return 2 * math.Sqrt(power) / float64(len(x))
+}
+
+func TestResamplerPreservesTone(t *testing.T) {
+	cases := []struct{ in, out, freq int }{
+		{16000, 48000, 1000},
+		{24000, 48000, 3000},
+		{48000, 16000, 1000},
+		{44100, 48000, 5000},
+		{48000, 44100, 5000},
+		{16000, 24000, 440},
+	}
+	for _, tc := range cases {
+		r, err := NewResampler(tc.in, tc.out, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := sineWave(tc.in, tc.freq, tc.in, 1, 0.5)
+		out := append(r.Process(in), r.Flush()...)
+		if want := tc.out; math.Abs(float64(len(out)-want)) > 2 {
+			t.Errorf("%d->%d: got %d samples, want ~%d", tc.in, tc.out, len(out), want)
+		}
+		if lvl := toneLevel(out, tc.out, tc.freq); math.Abs(lvl-0.5) > 0.01 {
+			t.Errorf("%d->%d: tone level %.4f, want 0.5", tc.in, tc.out, lvl)
+		}
+	}
+}
+
+func TestResamplerRejectsAliases(t *testing.T) {
+	r, err := NewResampler(48000, 16000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12kHz 超出 16kHz 输出的奈奎斯特频率，应被滤除而不是折叠到 4kHz
+	out := r.Process(sineWave(48000, 12000, 48000, 1, 0.5))
+	if lvl := toneLevel(out, 16000, 4000); lvl > 0.5e-3 {
+		t.Errorf("alias at 4kHz: level %.6f", lvl)
+	}
+}
+
+// 降采样的过渡带须落在输出奈奎斯特频率附近：通带边缘基本无衰减，
+// 紧邻奈奎斯特频率之上的分量折叠后的混叠须足够低
+func TestResamplerDownsampleBandEdge(t *testing.T) {
+	const in, out = 48000, 16000
+	db := func(lvl float64) float64 { return 20 * math.Log10(lvl/0.5) }
+
+	for _, freq := range []int{3000, 6000, 7000} {
+		r, _ := NewResampler(in, out, 1)
+		y := r.Process(sineWave(in, freq, in, 1, 0.5))
+		if got := db(toneLevel(y, out, freq)); got < -1.5 {
+			t.Errorf("passband %dHz: %.1f dB", freq, got)
+		}
+	}
+	for _, c := range []struct {
+		freq, alias int
+		maxDB       float64
+	}{
+		{8500, 7500, -30},
+		{9000, 7000, -60},
+		{10000, 6000, -60},
+	} {
+		r, _ := NewResampler(in, out, 1)
+		y := r.Process(sineWave(in, c.freq, in, 1, 0.5))
+		if got := db(toneLevel(y, out, c.alias)); got > c.maxDB {
+			t.Errorf("%dHz aliased to %dHz at %.1f dB, want < %.0f dB", c.freq, c.alias, got, c.maxDB)
+		}
+	}
+}
+
+func TestResamplerChunkedMatchesWhole(t *testing.T) {
+	in := sineWave(24000, 700, 24000, 2, 0.3)
+	whole, _ := NewResampler(24000, 48000, 2)
+	want := append(whole.Process(in), whole.Flush()...)
+
+	chunked, _ := NewResampler(24000, 48000, 2)
+	var got []float32
+	for len(in) > 0 {
+		n := 2 * 37 // 刻意使用与帧长无关的块大小
+		if n > len(in) {
+			n = len(in)
+		}
+		got = append(got, chunked.Process(in[:n])...)
+		in = in[n:]
+	}
+	got = append(got, chunked.Flush()...)
+
+	if len(got) != len(want) {
+		t.Fatalf("chunked len %d, whole len %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d: chunked %v, whole %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResamplerStereoChannelsIndependent(t *testing.T) {
+	r, _ := NewResampler(16000, 48000, 2)
+	in := make([]float32, 2*16000)
+	for i := 0; i < 16000; i++ {
+		in[2*i] = float32(0.5 * math.Sin(2*math.Pi*1000*float64(i)/16000))
+	}
+	out := r.Process(in)
+	left := make([]float32, len(out)/2)
+	right := make([]float32, len(out)/2)
+	for i := range left {
+		left[i], right[i] = out[2*i], out[2*i+1]
+	}
+	if lvl := toneLevel(left, 48000, 1000); math.Abs(lvl-0.5) > 0.01 {
+		t.Errorf("left level %.4f, want 0.5", lvl)
+	}
+	for i, v := range right {
+		if v != 0 {
+			t.Fatalf("right channel sample %d = %v, want silence", i, v)
+		}
+	}
+}
+
+func TestResamplerInt16(t *testing.T) {
+	r, _ := NewResampler(16000, 48000, 1)
+	f := sineWave(16000, 1000, 16000, 1, 0.5)
+	in := make([]int16, len(f))
+	for i, v := range f {
+		in[i] = int16(v * 32767)
+	}
+	out := append(r.ProcessInt16(in), r.FlushInt16()...)
+	g := make([]float32, len(out))
+	for i, v := range out {
+		g[i] = float32(v) / 32768
+	}
+	if lvl := toneLevel(g, 48000, 1000); math.Abs(lvl-0.5) > 0.01 {
+		t.Errorf("tone level %.4f, want 0.5", lvl)
+	}
+}
+
+func TestResamplerPassthrough(t *testing.T) {
+	r, _ := NewResampler(48000, 48000, 1)
+	in := []float32{0.1, 0.2, 0.3}
+	out := r.Process(in)
+	if len(out) != len(in) || out[1] != in[1] {
+		t.Fatalf("passthrough changed samples: %v", out)
+	}
+}
+
+func TestNewResamplerRejectsInvalid(t *testing.T) {
+	for _, c := range [][3]int{{0, 48000, 1}, {16000, -1, 1}, {16000, 48000, 0}} {
+		if _, err := NewResampler(c[0], c[1], c[2]); err == nil {
+			t.Errorf("NewResampler(%v) succeeded, want error", c)
+		}
+	}
+}
+
+func benchmarkResampler(b *testing.B, in, out, frameMs int) {
+	r, _ := NewResampler(in, out, 1)
+	frame := sineWave(in, 440, in*frameMs/1000, 1, 0.5)
+	b.SetBytes(int64(len(frame) * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Process(frame)
+	}
+}
+
+func BenchmarkResampler16kTo48k(b *testing.B)   { benchmarkResampler(b, 16000, 48000, 60) }
+func BenchmarkResampler24kTo48k(b *testing.B)   { benchmarkResampler(b, 24000, 48000, 60) }
+func BenchmarkResampler48kTo16k(b *testing.B)   { benchmarkResampler(b, 48000, 16000, 60) }
+func BenchmarkResampler44100To48k(b *testing.B) { benchmarkResampler(b, 44100, 48000, 60) }
+
+func BenchmarkResamplerInt16(b *testing.B) {
+	r, _ := NewResampler(24000, 48000, 1)
+	frame := make([]int16, 24000*60/1000)
+	b.SetBytes(int64(len(frame) * 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ProcessInt16(frame)
+	}
+}
@@ -0,0 +1,202 @@
+package audio
+
+import "math"
+
+// VAD 基于短时能量的语音活动检测：噪声底自适应跟踪，能量高出噪声底 ThresholdDB
+// 且持续 StartMs 判定为说话开始，随后连续静音超过 HangoverMs 判定为说话结束。
+// 噪声底另以最小值统计（最近数秒内的最低电平）兜底，持续的稳态噪声（风扇等）
+// 即使一开始被判为语音，也会在数秒后被计入噪声底
+
+// VADEvent 语音活动事件
+type VADEvent int
+
+const (
+	VADSpeechStart VADEvent = iota + 1
+	VADSpeechEnd
+)
+
+func (e VADEvent) String() string {
+	switch e {
+	case VADSpeechStart:
+		return "speech_start"
+	case VADSpeechEnd:
+		return "speech_end"
+	}
+	return "unknown"
+}
+
+// VADConfig VAD 参数
+type VADConfig struct {
+	SampleRate  int
+	WindowMs    int     // 分析窗口，默认 20ms
+	ThresholdDB float64 // 高出噪声底多少 dB 视为语音，默认 12
+	MinLevelDB  float64 // 语音的绝对电平下限（dBFS），默认 -45
+	StartMs     int     // 判定说话开始所需的持续语音时长，默认 60ms
+	HangoverMs  int     // 说话结束前允许的尾部静音时长，默认 800ms
+}
+
+// DefaultVADConfig 返回默认参数
+func DefaultVADConfig(sampleRate int) VADConfig {
+	return VADConfig{SampleRate: sampleRate, WindowMs: 20, ThresholdDB: 12, MinLevelDB: -45, StartMs: 60, HangoverMs: 800}
+}
+
+type VAD struct {
+	cfg        VADConfig
+	window     int // 每窗口样本数
+	startWins  int
+	hangWins   int
+	noiseDB    float64
+	buf        []float32
+	speaking   bool
+	voicedRun  int
+	silenceRun int
+
+	// 最小值统计：每 blockWins 个窗口记录一次块内最低电平
+	blockWins int
+	blockMin  float64
+	blockN    int
+	minBlocks []float64
+}
+
+// 噪声底初值与跟踪速度：静音时快速下降、缓慢上升，避免把语音计入噪声
+const (
+	vadInitialNoiseDB = -60
+	vadNoiseRise      = 0.02
+	vadNoiseFall      = 0.2
+
+	// 最小值统计的块长与块数（共 3s），历史填满后噪声底向该最小值缓慢上升
+	vadMinStatBlockMs = 500
+	vadMinStatBlocks  = 6
+	vadNoiseTrack     = 0.05
+)
+
+// NewVAD 创建 VAD；零值参数使用 DefaultVADConfig 中的默认值
+func NewVAD(cfg VADConfig) *VAD {
+	def := DefaultVADConfig(cfg.SampleRate)
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 16000
+	}
+	if cfg.WindowMs <= 0 {
+		cfg.WindowMs = def.WindowMs
+	}
+	if cfg.ThresholdDB <= 0 {
+		cfg.ThresholdDB = def.ThresholdDB
+	}
+	if cfg.MinLevelDB == 0 {
+		cfg.MinLevelDB = def.MinLevelDB
+	}
+	if cfg.StartMs <= 0 {
+		cfg.StartMs = def.StartMs
+	}
+	if cfg.HangoverMs <= 0 {
+		cfg.HangoverMs = def.HangoverMs
+	}
+	v := &VAD{
+		cfg:       cfg,
+		window:    cfg.SampleRate * cfg.WindowMs / 1000,
+		startWins: ceilDiv(cfg.StartMs, cfg.WindowMs),
+		hangWins:  ceilDiv(cfg.HangoverMs, cfg.WindowMs),
+		blockWins: ceilDiv(vadMinStatBlockMs, cfg.WindowMs),
+	}
+	v.Reset()
+	return v
+}
+
+// Reset 重置状态（新一轮监听开始时调用）
+func (v *VAD) Reset() {
+	v.noiseDB = vadInitialNoiseDB
+	v.buf = nil
+	v.speaking = false
+	v.voicedRun, v.silenceRun = 0, 0
+	v.blockN = 0
+	v.minBlocks = v.minBlocks[:0]
+}
+
+// Speaking 当前是否处于说话状态
+func (v *VAD) Speaking() bool { return v.speaking }
+
+// Process 输入单声道 Float32 PCM，返回期间产生的事件
+func (v *VAD) Process(samples []float32) []VADEvent {
+	var events []VADEvent
+	v.buf = append(v.buf, samples...)
+	for len(v.buf) >= v.window {
+		if ev, ok := v.step(levelDB(v.buf[:v.window])); ok {
+			events = append(events, ev)
+		}
+		v.buf = v.buf[v.window:]
+	}
+	return events
+}
+
+func (v *VAD) step(db float64) (VADEvent, bool) {
+	voiced := db >= v.cfg.MinLevelDB && db >= v.noiseDB+v.cfg.ThresholdDB
+	if !voiced {
+		rate := vadNoiseRise
+		if db < v.noiseDB {
+			rate = vadNoiseFall
+		}
+		v.noiseDB += (db - v.noiseDB) * rate
+	}
+	// 长时间判为语音时噪声底不会经上面的分支更新，由最小值统计拉升
+	if m, ok := v.trackMin(db); ok && m > v.noiseDB {
+		v.noiseDB += (m - v.noiseDB) * vadNoiseTrack
+	}
+
+	if voiced {
+		v.voicedRun++
+		v.silenceRun = 0
+	} else {
+		v.silenceRun++
+		v.voicedRun = 0
+	}
+	switch {
+	case !v.speaking && v.voicedRun >= v.startWins:
+		v.speaking = true
+		return VADSpeechStart, true
+	case v.speaking && v.silenceRun >= v.hangWins:
+		v.speaking = false
+		return VADSpeechEnd, true
+	}
+	return 0, false
+}
+
+// trackMin 更新最小值统计，历史未满 vadMinStatBlocks 块时 ok=false
+func (v *VAD) trackMin(db float64) (float64, bool) {
+	if v.blockN == 0 || db < v.blockMin {
+		v.blockMin = db
+	}
+	v.blockN++
+	if v.blockN >= v.blockWins {
+		if len(v.minBlocks) == vadMinStatBlocks {
+			v.minBlocks = append(v.minBlocks[:0], v.minBlocks[1:]...)
+		}
+		v.minBlocks = append(v.minBlocks, v.blockMin)
+		v.blockN = 0
+	}
+	if len(v.minBlocks) < vadMinStatBlocks {
+		return 0, false
+	}
+	m := math.Inf(1)
+	if v.blockN > 0 {
+		m = v.blockMin
+	}
+	for _, b := range v.minBlocks {
+		m = math.Min(m, b)
+	}
+	return m, true
+}
+
+// levelDB 计算窗口 RMS 电平（dBFS）
+func levelDB(x []float32) float64 {
+	var sum float64
+	for _, s := range x {
+		sum += float64(s) * float64(s)
+	}
+	rms := math.Sqrt(sum / float64(len(x)))
+	if rms < 1e-6 {
+		return -120
+	}
+	return 20 * math.Log10(rms)
+}
+
+func ceilDiv(a, b int) int { return (a + b - 1) / b }
@@ -0,0 +1,87 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noise 生成指定 RMS 电平（dBFS）的均匀白噪声
+func noise(r *rand.Rand, n int, db float64) []float32 {
+	amp := math.Pow(10, db/20) * math.Sqrt(3)
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amp * (2*r.Float64() - 1))
+	}
+	return out
+}
+
+type vadEventAt struct {
+	ev VADEvent
+	ms int
+}
+
+// feedVAD 以 20ms 一帧送入 x，返回事件及其发生时刻（相对 offsetMs）
+func feedVAD(v *VAD, x []float32, rate, offsetMs int) []vadEventAt {
+	frame := rate / 50
+	var out []vadEventAt
+	for i := 0; i+frame <= len(x); i += frame {
+		for _, ev := range v.Process(x[i : i+frame]) {
+			out = append(out, vadEventAt{ev, offsetMs + (i+frame)*1000/rate})
+		}
+	}
+	return out
+}
+
+func TestVADSpeechInQuiet(t *testing.T) {
+	const rate = 16000
+	r := rand.New(rand.NewSource(1))
+	v := NewVAD(VADConfig{SampleRate: rate})
+
+	var events []vadEventAt
+	events = append(events, feedVAD(v, noise(r, rate, -70), rate, 0)...)
+	events = append(events, feedVAD(v, sineWave(rate, 300, 2*rate, 1, 0.1), rate, 1000)...)
+	events = append(events, feedVAD(v, noise(r, 2*rate, -70), rate, 3000)...)
+	if len(events) != 2 || events[0].ev != VADSpeechStart || events[1].ev != VADSpeechEnd {
+		t.Fatalf("events %v, want start/end", events)
+	}
+	if events[0].ms < 1000 || events[0].ms > 1200 {
+		t.Errorf("speech start at %dms", events[0].ms)
+	}
+	if events[1].ms < 3000 || events[1].ms > 4000 {
+		t.Errorf("speech end at %dms", events[1].ms)
+	}
+}
+
+// 稳态噪声高于初始噪声底 ThresholdDB 以上：起初被判为语音，
+// 最小值统计须在数秒内把它计入噪声底，之后真正的语音仍可检出
+func TestVADStationaryNoise(t *testing.T) {
+	const rate = 16000
+	r := rand.New(rand.NewSource(2))
+	v := NewVAD(VADConfig{SampleRate: rate})
+
+	events := feedVAD(v, noise(r, 10*rate, -40), rate, 0)
+	if len(events) != 2 || events[0].ev != VADSpeechStart || events[1].ev != VADSpeechEnd {
+		t.Fatalf("events %v, want start/end", events)
+	}
+	if events[1].ms > 6000 {
+		t.Errorf("speech end at %dms, noise floor adapts too slowly", events[1].ms)
+	}
+	if v.noiseDB < -45 {
+		t.Errorf("noise floor %.1f dB, want near -40", v.noiseDB)
+	}
+
+	speech := sineWave(rate, 300, 2*rate, 1, 0.2)
+	bg := noise(r, len(speech), -40)
+	for i := range speech {
+		speech[i] += bg[i]
+	}
+	events = feedVAD(v, speech, rate, 10000)
+	events = append(events, feedVAD(v, noise(r, 2*rate, -40), rate, 12000)...)
+	if len(events) != 2 || events[0].ev != VADSpeechStart || events[1].ev != VADSpeechEnd {
+		t.Fatalf("speech over noise: events %v, want start/end", events)
+	}
+	if events[0].ms > 10200 {
+		t.Errorf("speech start at %dms", events[0].ms)
+	}
+}
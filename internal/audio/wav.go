@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// PCM 解码后的音频：交错声道 Float32（-1.0..1.0）
+type PCM struct {
+	SampleRate int
+	Channels   int
+	Samples    []float32
+}
+
+// WAV 格式码
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// DecodeWAV 解析 RIFF/WAVE，支持 PCM16 与 Float32（含 WAVE_FORMAT_EXTENSIBLE）
+func DecodeWAV(r io.Reader) (*PCM, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("读取 WAV 头失败: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, errors.New("不是 RIFF/WAVE 文件")
+	}
+
+	var (
+		format, bits int
+		pcm          *PCM
+	)
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(r, ch[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errors.New("WAV 缺少 data 块")
+			}
+			return nil, err
+		}
+		id, size := string(ch[0:4]), binary.LittleEndian.Uint32(ch[4:8])
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("fmt 块过短: %d", size)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("读取 fmt 块失败: %w", err)
+			}
+			format = int(binary.LittleEndian.Uint16(body[0:2]))
+			pcm = &PCM{
+				Channels:   int(binary.LittleEndian.Uint16(body[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(body[4:8])),
+			}
+			bits = int(binary.LittleEndian.Uint16(body[14:16]))
+			if format == wavFormatExtensible && size >= 26 {
+				// SubFormat GUID 的前两字节即实际格式码
+				format = int(binary.LittleEndian.Uint16(body[24:26]))
+			}
+		case "data":
+			if pcm == nil {
+				return nil, errors.New("WAV data 块出现在 fmt 块之前")
+			}
+			// 部分录音工具写入的 data 长度为 0 或 0xFFFFFFFF（流式写入），此时读到文件尾
+			var data []byte
+			var err error
+			if size == 0 || size == math.MaxUint32 {
+				data, err = io.ReadAll(r)
+			} else {
+				data = make([]byte, size)
+				var n int
+				n, err = io.ReadFull(r, data)
+				if err == io.ErrUnexpectedEOF {
+					data, err = data[:n], nil // 截断的文件尽量使用已有数据
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("读取 WAV 数据失败: %w", err)
+			}
+			if err := decodeWAVSamples(pcm, format, bits, data); err != nil {
+				return nil, err
+			}
+			return pcm, nil
+		default:
+			// 跳过 LIST 等其他块（块长按偶数对齐）
+			if _, err := io.CopyN(io.Discard, r, int64(size+size&1)); err != nil {
+				return nil, fmt.Errorf("跳过 %q 块失败: %w", id, err)
+			}
+		}
+	}
+}
+
+func decodeWAVSamples(pcm *PCM, format, bits int, data []byte) error {
+	if pcm.Channels <= 0 || pcm.SampleRate <= 0 {
+		return fmt.Errorf("无效的 WAV 参数: %dHz %d 声道", pcm.SampleRate, pcm.Channels)
+	}
+	switch {
+	case format == wavFormatPCM && bits == 16:
+		n := len(data) / 2
+		pcm.Samples = make([]float32, n)
+		for i := 0; i < n; i++ {
+			pcm.Samples[i] = float32(int16(binary.LittleEndian.Uint16(data[2*i:]))) / 32768
+		}
+	case format == wavFormatFloat && bits == 32:
+		n := len(data) / 4
+		pcm.Samples = make([]float32, n)
+		for i := 0; i < n; i++ {
+			pcm.Samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+	default:
+		return fmt.Errorf("不支持的 WAV 编码: format=%d bits=%d（仅支持 PCM16 / Float32）", format, bits)
+	}
+	// 丢弃末尾不完整的采样帧
+	pcm.Samples = pcm.Samples[:len(pcm.Samples)/pcm.Channels*pcm.Channels]
+	return nil
+}
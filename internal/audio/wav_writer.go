@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// WAVWriter 以 PCM16 写 WAV 文件；RIFF/data 长度在 Close 时回填
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	channels   int
+	dataBytes  uint32
+	closed     bool
+}
+
+const wavHeaderSize = 44
+
+// NewWAVWriter 写入占位文件头
+func NewWAVWriter(w io.WriteSeeker, sampleRate, channels int) (*WAVWriter, error) {
+	if sampleRate <= 0 || channels <= 0 {
+		return nil, errors.New("无效的 WAV 参数")
+	}
+	ww := &WAVWriter{w: w, sampleRate: sampleRate, channels: channels}
+	if _, err := w.Write(ww.header()); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+// WriteFloat32 写入交错声道 Float32 PCM（超出 ±1 的样本被截断）
+func (ww *WAVWriter) WriteFloat32(pcm []float32) error {
+	if ww.closed {
+		return errors.New("wav writer 已关闭")
+	}
+	buf := make([]byte, 2*len(pcm))
+	for i, v := range floatToInt16(pcm) {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(v))
+	}
+	n, err := ww.w.Write(buf)
+	ww.dataBytes += uint32(n)
+	return err
+}
+
+// Frames 已写入的采样帧数
+func (ww *WAVWriter) Frames() int { return int(ww.dataBytes) / 2 / ww.channels }
+
+// SampleRate 采样率
+func (ww *WAVWriter) SampleRate() int { return ww.sampleRate }
+
+// Close 回填文件头中的长度；不关闭底层 Writer
+func (ww *WAVWriter) Close() error {
+	if ww.closed {
+		return nil
+	}
+	ww.closed = true
+	if _, err := ww.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ww.w.Write(ww.header()); err != nil {
+		return err
+	}
+	_, err := ww.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (ww *WAVWriter) header() []byte {
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 36+ww.dataBytes)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], wavFormatPCM)
+	binary.LittleEndian.PutUint16(h[22:], uint16(ww.channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(ww.sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(ww.sampleRate*ww.channels*2))
+	binary.LittleEndian.PutUint16(h[32:], uint16(ww.channels*2))
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], ww.dataBytes)
+	return h
+}
@@ -0,0 +1,290 @@
+package chaosproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mockserver/mocktest"
+)
+
+// udpEcho 回显服务，记录最近一个来源地址
+func udpEcho(t *testing.T) (*net.UDPConn, *atomic.Value) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last atomic.Value
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			last.Store(from.String())
+			_, _ = conn.WriteToUDP(buf[:n], from)
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return conn, &last
+}
+
+func dialProxy(t *testing.T, p *UDPProxy, target string) *net.UDPConn {
+	t.Helper()
+	a, err := p.Listen("127.0.0.1:0", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.DialUDP("udp", nil, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// readAll 读取回显直到 quiet 时间内没有新包
+func readAll(c *net.UDPConn, quiet time.Duration) []uint32 {
+	var seqs []uint32
+	buf := make([]byte, 2048)
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(quiet))
+		n, err := c.Read(buf)
+		if err != nil {
+			return seqs
+		}
+		if n >= 4 {
+			seqs = append(seqs, binary.BigEndian.Uint32(buf))
+		}
+	}
+}
+
+func TestUDPLossDuplicateReorder(t *testing.T) {
+	echo, _ := udpEcho(t)
+	p := NewUDP(Config{Seed: 1, Up: Faults{Latency: 5 * time.Millisecond, Loss: 0.2, Duplicate: 0.1, Reorder: 0.2}})
+	defer p.Close()
+	c := dialProxy(t, p, echo.LocalAddr().String())
+
+	const sent = 200
+	for i := 0; i < sent; i++ {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(i))
+		if _, err := c.Write(b[:]); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	seqs := readAll(c, 300*time.Millisecond)
+
+	st := p.Stats()
+	if st.Dropped == 0 || st.Duplicated == 0 || st.Reordered == 0 {
+		t.Fatalf("no faults injected: %+v", st)
+	}
+	// 下行无故障：每个投递到服务端的包都原样回显
+	if want := sent - st.Dropped + st.Duplicated; len(seqs) != want {
+		t.Errorf("received %d, want %d (%+v)", len(seqs), want, st)
+	}
+	if st.Forwarded != 2*len(seqs) {
+		t.Errorf("forwarded %d, want %d", st.Forwarded, 2*len(seqs))
+	}
+	late := 0
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] < seqs[i-1] {
+			late++
+		}
+	}
+	if late == 0 {
+		t.Error("no reordering observed")
+	}
+}
+
+func TestUDPBandwidthAndLatency(t *testing.T) {
+	echo, _ := udpEcho(t)
+	// 1000 字节/秒：10 个 100 字节的包排队约 1s，往返各 50ms
+	p := NewUDP(Config{Down: Faults{Latency: 50 * time.Millisecond, Bandwidth: 1000}, Up: Faults{Latency: 50 * time.Millisecond}})
+	defer p.Close()
+	c := dialProxy(t, p, echo.LocalAddr().String())
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		b := make([]byte, 100)
+		binary.BigEndian.PutUint32(b, uint32(i))
+		_, _ = c.Write(b)
+	}
+	buf := make([]byte, 2048)
+	_ = c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if rtt := time.Since(start); rtt < 100*time.Millisecond {
+		t.Errorf("first echo after %v, want >= 100ms", rtt)
+	}
+	seqs := readAll(c, 500*time.Millisecond)
+	if elapsed := time.Since(start); len(seqs) != 9 || elapsed < time.Second {
+		t.Errorf("received %d more in %v, want 9 over >= 1s", len(seqs), elapsed)
+	}
+}
+
+func TestUDPDisconnectRebinds(t *testing.T) {
+	echo, last := udpEcho(t)
+	p := NewUDP(Config{})
+	defer p.Close()
+	c := dialProxy(t, p, echo.LocalAddr().String())
+
+	roundTrip := func() string {
+		t.Helper()
+		if _, err := c.Write([]byte{0, 0, 0, 1}); err != nil {
+			t.Fatal(err)
+		}
+		if seqs := readAll(c, 200*time.Millisecond); len(seqs) != 1 {
+			t.Fatalf("%d echoes, want 1", len(seqs))
+		}
+		return last.Load().(string)
+	}
+	first := roundTrip()
+	if n := p.Disconnect(); n != 1 {
+		t.Fatalf("disconnected %d mappings, want 1", n)
+	}
+	if second := roundTrip(); second == first {
+		t.Errorf("source address unchanged after disconnect: %s", second)
+	}
+	if st := p.Stats(); st.Disconnects != 1 {
+		t.Errorf("disconnects %d, want 1", st.Disconnects)
+	}
+}
+
+func startWS(t *testing.T, target string, cfg Config) *WSProxy {
+	t.Helper()
+	p := NewWS(target, cfg)
+	if err := p.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestWSJitterKeepsOrder(t *testing.T) {
+	s := mocktest.StartServer(t, mockserver.Config{Token: "secret"})
+	p := startWS(t, s.URL(), Config{Seed: 1,
+		Up:   Faults{Latency: 40 * time.Millisecond},
+		Down: Faults{Latency: 40 * time.Millisecond, Jitter: 30 * time.Millisecond}})
+
+	// 上游拒绝握手时状态码原样返回
+	c := client.New(mocktest.WSConfig(p.URL()))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.OpenWebsocket(ctx); err == nil {
+		c.Close()
+		t.Fatal("open without token succeeded")
+	}
+
+	cfg := mocktest.WSConfig(p.URL())
+	cfg.AuthToken, cfg.EnableToken = "secret", true
+	c = client.New(cfg)
+	start := time.Now()
+	if err := c.OpenWebsocket(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if rtt := time.Since(start); rtt < 50*time.Millisecond {
+		t.Errorf("hello round trip %v, want >= 50ms", rtt)
+	}
+
+	turn, err := c.Ask(context.Background(), "讲个笑话")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := turn.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.STT != "讲个笑话" || len(res.Sentences) != 1 || res.AudioFrames != 5 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if st := p.Stats(); st.Dropped != 0 || st.Duplicated != 0 || st.Forwarded == 0 {
+		t.Errorf("stats %+v", st)
+	}
+	if h := s.Conns()[0].Headers; h.Get("Authorization") != "Bearer secret" || h.Get("Device-Id") != mocktest.DeviceID {
+		t.Errorf("forwarded headers: %v", h)
+	}
+}
+
+func TestWSDisconnectAfterTriggersReconnect(t *testing.T) {
+	s := mocktest.StartServer(t, mockserver.Config{})
+	p := startWS(t, s.URL(), Config{DisconnectAfter: 300 * time.Millisecond})
+
+	cfg := mocktest.WSConfig(p.URL())
+	cfg.Reconnect = client.ReconnectPolicy{Enabled: true, InitialDelay: 50 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Multiplier: 1}
+	c := client.New(cfg)
+	reconnected := make(chan struct{}, 1)
+	c.OnReconnect = func(ev client.ReconnectEvent) {
+		if ev.State == client.ReconnectStateReconnected {
+			select {
+			case reconnected <- struct{}{}:
+			default:
+			}
+		}
+	}
+	if err := c.OpenWebsocket(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case <-reconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("client did not reconnect after forced disconnect")
+	}
+	if st := p.Stats(); st.Disconnects < 1 {
+		t.Errorf("disconnects %d, want >= 1", st.Disconnects)
+	}
+	if n := len(s.Conns()); n < 2 {
+		t.Errorf("%d upstream conns, want >= 2", n)
+	}
+}
+
+func TestMQTTUDPThroughProxy(t *testing.T) {
+	b, r := mocktest.StartMQTT(t, mockserver.MQTTConfig{Config: mockserver.Config{Echo: true}})
+	p := NewUDP(Config{Seed: 3, Down: Faults{Latency: 10 * time.Millisecond, Jitter: 10 * time.Millisecond, Reorder: 0.5}})
+	// 晚于客户端关闭
+	t.Cleanup(func() { p.Close() })
+
+	var echoed int32
+	c := mocktest.Dial(t, mocktest.MQTTConfig(b, "chaos-dev"), func(c *client.Client) {
+		c.RedirectUDP = p.Redirect
+		c.OnBinary = func(ctx context.Context, data []byte) { atomic.AddInt32(&echoed, 1) }
+	})
+
+	frames := make([][]byte, 10)
+	for i := range frames {
+		frames[i] = []byte{0x18, byte(i)}
+	}
+	turn, err := c.SpeakOpus(context.Background(), frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := turn.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 乱序由抖动缓冲重排，回声帧全部送达
+	for deadline := time.Now().Add(3 * time.Second); atomic.LoadInt32(&echoed) < 10 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&echoed); n != 10 {
+		t.Errorf("echoed %d frames, want 10", n)
+	}
+	if st := p.Stats(); st.Reordered == 0 {
+		t.Errorf("no packets reordered: %+v", st)
+	}
+	if s := r.Sessions(); len(s) != 1 || s[0].UpstreamFrames != 10 {
+		t.Errorf("sessions: %+v", s)
+	}
+}
@@ -0,0 +1,271 @@
+// Package chaosproxy 本地网络故障注入代理：架在 client.Client 与服务端之间，
+// 对 WebSocket 消息与 UDP 音频数据报注入延迟、抖动、丢包、重复、乱序、限速与强制断线，
+// 用于验证重连与抖动缓冲。可在测试中直接使用，也可通过 cmd/chaosproxy 独立运行。
+package chaosproxy
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Faults 单个方向上的故障参数，零值为直通
+type Faults struct {
+	Latency time.Duration // 固定单向延迟
+	Jitter  time.Duration // 每个包的延迟在 Latency±Jitter 内均匀浮动（不小于 0）
+
+	// 以下三项只作用于 UDP；WebSocket 基于 TCP，消息不丢、不重、不乱序，抖动也不会让后发的消息超车
+	Loss         float64       // 丢弃概率 0..1
+	Duplicate    float64       // 重复发送概率
+	Reorder      float64       // 乱序概率：选中的包额外延迟 ReorderDelay，被后续包超越
+	ReorderDelay time.Duration // 默认 40ms
+
+	// Bandwidth 带宽上限（字节/秒），0 不限。超出时 WebSocket 读端被阻塞（同 TCP 背压），
+	// UDP 积压超过 maxBacklog 的包被丢弃
+	Bandwidth int
+}
+
+// Config 代理参数
+type Config struct {
+	Up   Faults // 上行：客户端 → 服务端
+	Down Faults // 下行：服务端 → 客户端
+
+	// DisconnectAfter 非 0 时每个 WebSocket 连接（不发关闭帧，模拟断网）或 UDP 映射
+	// （之后的包换用新的源端口，模拟 NAT 重绑定）建立该时长后被强制切断
+	DisconnectAfter time.Duration
+	Seed            int64 // 随机种子，0 时按当前时间生成
+}
+
+// Stats 累计统计
+type Stats struct {
+	Forwarded   int // 已投递的消息/数据报（含重复）
+	Dropped     int // 因 Loss 或限速积压丢弃
+	Duplicated  int
+	Reordered   int
+	Disconnects int // 强制切断的 WebSocket 连接 / UDP 映射
+}
+
+// maxBacklog 限速时允许的最大排队时长
+const maxBacklog = time.Second
+
+const defaultReorderDelay = 40 * time.Millisecond
+
+// shaper 一个代理上全部连接共享的故障参数、随机源与统计
+type shaper struct {
+	mu    sync.Mutex
+	cfg   Config
+	rng   *rand.Rand
+	stats Stats
+}
+
+func newShaper(cfg Config) *shaper {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &shaper{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (s *shaper) setFaults(up, down Faults) {
+	s.mu.Lock()
+	s.cfg.Up, s.cfg.Down = up, down
+	s.mu.Unlock()
+}
+
+func (s *shaper) disconnectAfter() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.DisconnectAfter
+}
+
+func (s *shaper) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *shaper) count(f func(st *Stats)) {
+	s.mu.Lock()
+	f(&s.stats)
+	s.mu.Unlock()
+}
+
+// 以下在 s.mu 内调用
+func (s *shaper) roll(p float64) bool { return p > 0 && s.rng.Float64() < p }
+
+func (s *shaper) delay(f Faults) time.Duration {
+	d := f.Latency
+	if f.Jitter > 0 {
+		d += time.Duration(s.rng.Int63n(int64(2*f.Jitter)+1)) - f.Jitter
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// pipe 单个方向的调度队列：按限速与延迟计算投递时间，由独立 goroutine 依次投递
+type pipe struct {
+	sh      *shaper
+	up      bool
+	ordered bool // WebSocket：投递时间单调不减，不丢不重
+	deliver func(kind int, data []byte)
+
+	mu     sync.Mutex
+	q      packetQueue
+	n      uint64
+	last   time.Time // ordered 时上一个包的投递时间
+	txFree time.Time // 限速：链路空闲的时刻
+
+	wake      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipe(sh *shaper, up, ordered bool, deliver func(kind int, data []byte)) *pipe {
+	p := &pipe{sh: sh, up: up, ordered: ordered, deliver: deliver,
+		wake: make(chan struct{}, 1), closed: make(chan struct{})}
+	go p.loop()
+	return p
+}
+
+// push 按当前故障参数排入一个包，返回限速造成的排队时长
+func (p *pipe) push(kind int, data []byte) time.Duration {
+	sh := p.sh
+	sh.mu.Lock()
+	f := sh.cfg.Down
+	if p.up {
+		f = sh.cfg.Up
+	}
+	copies, reorder := 1, false
+	if !p.ordered {
+		if sh.roll(f.Loss) {
+			sh.stats.Dropped++
+			sh.mu.Unlock()
+			return 0
+		}
+		if sh.roll(f.Duplicate) {
+			copies = 2
+			sh.stats.Duplicated++
+		}
+		if reorder = sh.roll(f.Reorder); reorder {
+			sh.stats.Reordered++
+		}
+	}
+	delays := [2]time.Duration{sh.delay(f), sh.delay(f)}
+	sh.mu.Unlock()
+
+	p.mu.Lock()
+	now := time.Now()
+	dropped := 0
+	for i := 0; i < copies; i++ {
+		tx := now
+		if p.txFree.After(tx) {
+			tx = p.txFree
+		}
+		if f.Bandwidth > 0 {
+			tx = tx.Add(time.Duration(len(data)) * time.Second / time.Duration(f.Bandwidth))
+		}
+		if !p.ordered && tx.Sub(now) > maxBacklog {
+			dropped++
+			continue
+		}
+		p.txFree = tx
+		at := tx.Add(delays[i])
+		if reorder && i == 0 {
+			rd := f.ReorderDelay
+			if rd <= 0 {
+				rd = defaultReorderDelay
+			}
+			at = at.Add(rd)
+		}
+		if p.ordered {
+			if at.Before(p.last) {
+				at = p.last
+			}
+			p.last = at
+		}
+		p.n++
+		heap.Push(&p.q, packet{at: at, n: p.n, kind: kind, data: data})
+	}
+	backlog := p.txFree.Sub(now)
+	p.mu.Unlock()
+	if dropped > 0 {
+		sh.count(func(st *Stats) { st.Dropped += dropped })
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return backlog
+}
+
+func (p *pipe) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		var due []packet
+		for len(p.q) > 0 && !p.q[0].at.After(now) {
+			due = append(due, heap.Pop(&p.q).(packet))
+		}
+		wait := time.Duration(-1)
+		if len(p.q) > 0 {
+			wait = p.q[0].at.Sub(now)
+		}
+		p.mu.Unlock()
+
+		for _, pk := range due {
+			select {
+			case <-p.closed:
+				return
+			default:
+			}
+			p.deliver(pk.kind, pk.data)
+		}
+		if len(due) > 0 {
+			p.sh.count(func(st *Stats) { st.Forwarded += len(due) })
+		}
+		if wait >= 0 {
+			timer.Reset(wait)
+		} else {
+			timer.Stop()
+		}
+		select {
+		case <-p.wake:
+		case <-timer.C:
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// close 停止投递并丢弃队列中剩余的包；可在 deliver 中调用，不等待投递 goroutine 退出
+func (p *pipe) close() { p.closeOnce.Do(func() { close(p.closed) }) }
+
+type packet struct {
+	at   time.Time
+	n    uint64 // 同一投递时间按入队顺序
+	kind int
+	data []byte
+}
+
+type packetQueue []packet
+
+func (q packetQueue) Len() int { return len(q) }
+func (q packetQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].n < q[j].n
+}
+func (q packetQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *packetQueue) Push(x any)   { *q = append(*q, x.(packet)) }
+func (q *packetQueue) Pop() any {
+	old := *q
+	pk := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return pk
+}
@@ -0,0 +1,227 @@
+package chaosproxy
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"myproject/internal/logging"
+)
+
+// UDPProxy UDP 代理：每个转发端口对应一个目标地址，按客户端源地址建立映射
+// （各用一个新的上游 socket，类似 NAT），对每个数据报施加全部故障
+type UDPProxy struct {
+	sh *shaper
+
+	mu       sync.Mutex
+	relays   []*udpRelay
+	byTarget map[string]*udpRelay
+}
+
+// NewUDP 创建 UDP 代理，转发端口由 Listen 或 Redirect 建立
+func NewUDP(cfg Config) *UDPProxy {
+	return &UDPProxy{sh: newShaper(cfg), byTarget: make(map[string]*udpRelay)}
+}
+
+// Listen 在 addr（如 127.0.0.1:0）上监听并转发到 target，返回实际监听地址
+func (p *UDPProxy) Listen(addr, target string) (*net.UDPAddr, error) {
+	ta, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return nil, err
+	}
+	la, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", la)
+	if err != nil {
+		return nil, err
+	}
+	r := &udpRelay{p: p, conn: conn, target: ta, maps: make(map[string]*udpMapping), done: make(chan struct{})}
+	p.mu.Lock()
+	p.relays = append(p.relays, r)
+	p.byTarget[target] = r
+	p.mu.Unlock()
+	go r.readLoop()
+	return conn.LocalAddr().(*net.UDPAddr), nil
+}
+
+// Redirect 可直接用作 client.Client.RedirectUDP：为服务端 hello 下发的地址在 127.0.0.1
+// 上建立（或复用）转发端口并返回该端口；失败时返回原地址
+func (p *UDPProxy) Redirect(server string, port int) (string, int) {
+	target := net.JoinHostPort(server, strconv.Itoa(port))
+	p.mu.Lock()
+	r := p.byTarget[target]
+	p.mu.Unlock()
+	if r != nil {
+		a := r.conn.LocalAddr().(*net.UDPAddr)
+		return a.IP.String(), a.Port
+	}
+	a, err := p.Listen("127.0.0.1:0", target)
+	if err != nil {
+		logging.L().With("module", "chaosproxy").Warn("建立 UDP 转发失败", "target", target, "err", err)
+		return server, port
+	}
+	return a.IP.String(), a.Port
+}
+
+// SetFaults 修改两个方向的故障参数，立即生效
+func (p *UDPProxy) SetFaults(up, down Faults) { p.sh.setFaults(up, down) }
+
+// Stats 返回累计统计
+func (p *UDPProxy) Stats() Stats { return p.sh.snapshot() }
+
+// Disconnect 丢弃全部映射：此后客户端的包从新的源端口发往服务端，
+// 服务端在收到新端口的包之前下发的数据报丢失；返回丢弃的映射数
+func (p *UDPProxy) Disconnect() int {
+	p.mu.Lock()
+	relays := append([]*udpRelay(nil), p.relays...)
+	p.mu.Unlock()
+	n := 0
+	for _, r := range relays {
+		for _, m := range r.mappings() {
+			if m.abort() {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Close 关闭全部转发端口
+func (p *UDPProxy) Close() error {
+	p.mu.Lock()
+	relays := p.relays
+	p.relays = nil
+	p.byTarget = make(map[string]*udpRelay)
+	p.mu.Unlock()
+	for _, r := range relays {
+		r.close()
+	}
+	return nil
+}
+
+// udpRelay 一个转发端口
+type udpRelay struct {
+	p      *UDPProxy
+	conn   *net.UDPConn
+	target *net.UDPAddr
+
+	mu   sync.Mutex
+	maps map[string]*udpMapping // 客户端地址 → 映射
+	done chan struct{}
+}
+
+func (r *udpRelay) readLoop() {
+	defer close(r.done)
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		m, err := r.mapping(from)
+		if err != nil {
+			logging.L().With("module", "chaosproxy").Warn("建立 UDP 映射失败", "target", r.target.String(), "err", err)
+			continue
+		}
+		m.up.push(0, append([]byte(nil), buf[:n]...))
+	}
+}
+
+func (r *udpRelay) mapping(from *net.UDPAddr) (*udpMapping, error) {
+	key := from.String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m := r.maps[key]; m != nil {
+		return m, nil
+	}
+	conn, err := net.DialUDP("udp", nil, r.target)
+	if err != nil {
+		return nil, err
+	}
+	m := &udpMapping{r: r, key: key, client: from, conn: conn}
+	m.up = newPipe(r.p.sh, true, false, func(_ int, data []byte) { _, _ = conn.Write(data) })
+	m.down = newPipe(r.p.sh, false, false, func(_ int, data []byte) { _, _ = r.conn.WriteToUDP(data, from) })
+	if d := r.p.sh.disconnectAfter(); d > 0 {
+		m.timer = time.AfterFunc(d, func() { m.abort() })
+	}
+	r.maps[key] = m
+	go m.readLoop()
+	return m, nil
+}
+
+func (r *udpRelay) mappings() []*udpMapping {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*udpMapping, 0, len(r.maps))
+	for _, m := range r.maps {
+		out = append(out, m)
+	}
+	return out
+}
+
+func (r *udpRelay) close() {
+	_ = r.conn.Close()
+	<-r.done
+	for _, m := range r.mappings() {
+		m.close()
+	}
+}
+
+// udpMapping 一个客户端源地址与其上游 socket
+type udpMapping struct {
+	r        *udpRelay
+	key      string
+	client   *net.UDPAddr
+	conn     *net.UDPConn
+	up, down *pipe
+	timer    *time.Timer // DisconnectAfter，由 r.mu 保护
+	once     sync.Once
+}
+
+func (m *udpMapping) readLoop() {
+	buf := make([]byte, 65535)
+	for {
+		n, err := m.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// 目标暂不可达（ICMP 端口不可达）时已连接的 UDP socket 会读出错，继续读
+			continue
+		}
+		m.down.push(0, append([]byte(nil), buf[:n]...))
+	}
+}
+
+// abort 模拟 NAT 重绑定；映射已关闭时返回 false
+func (m *udpMapping) abort() bool {
+	aborted := false
+	m.once.Do(func() {
+		aborted = true
+		m.r.p.sh.count(func(st *Stats) { st.Disconnects++ })
+		logging.L().With("module", "chaosproxy").Debug("丢弃 UDP 映射", "client", m.client.String(), "target", m.r.target.String())
+		m.shutdown()
+	})
+	return aborted
+}
+
+func (m *udpMapping) close() { m.once.Do(m.shutdown) }
+
+func (m *udpMapping) shutdown() {
+	m.up.close()
+	m.down.close()
+	_ = m.conn.Close()
+	m.r.mu.Lock()
+	if m.r.maps[m.key] == m {
+		delete(m.r.maps, m.key)
+	}
+	timer := m.timer
+	m.r.mu.Unlock()
+	if timer != nil {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,232 @@
+package chaosproxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"myproject/internal/logging"
+)
+
+// WSProxy WebSocket 代理：每个客户端连接对应一条到 Target 的上游连接，
+// 按消息转发并对每条消息单独施加延迟、抖动与限速
+type WSProxy struct {
+	Target string // 上游地址（ws:// 或 wss://）；客户端请求的路径被忽略，查询参数合并到 Target 上
+
+	sh       *shaper
+	ln       net.Listener
+	srv      *http.Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	links map[*wsLink]struct{}
+}
+
+// NewWS 创建 WebSocket 代理（尚未监听）
+func NewWS(target string, cfg Config) *WSProxy {
+	return &WSProxy{
+		Target:   target,
+		sh:       newShaper(cfg),
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		links:    make(map[*wsLink]struct{}),
+	}
+}
+
+// Start 监听 addr（如 127.0.0.1:0）并开始转发
+func (p *WSProxy) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p.ln = ln
+	p.srv = &http.Server{Handler: p}
+	go p.srv.Serve(ln)
+	return nil
+}
+
+// URL 返回客户端应连接的地址
+func (p *WSProxy) URL() string { return "ws://" + p.ln.Addr().String() + "/xiaozhi/v1/" }
+
+// SetFaults 修改两个方向的故障参数，对已建立的连接立即生效
+func (p *WSProxy) SetFaults(up, down Faults) { p.sh.setFaults(up, down) }
+
+// Stats 返回累计统计
+func (p *WSProxy) Stats() Stats { return p.sh.snapshot() }
+
+// Disconnect 立即切断全部连接（不发关闭帧），返回切断的连接数
+func (p *WSProxy) Disconnect() int {
+	p.mu.Lock()
+	links := make([]*wsLink, 0, len(p.links))
+	for l := range p.links {
+		links = append(links, l)
+	}
+	p.mu.Unlock()
+	for _, l := range links {
+		l.abort()
+	}
+	return len(links)
+}
+
+// Close 关闭监听与全部连接
+func (p *WSProxy) Close() error {
+	p.mu.Lock()
+	links := p.links
+	p.links = make(map[*wsLink]struct{})
+	p.mu.Unlock()
+	for l := range links {
+		l.close()
+	}
+	if p.srv == nil {
+		return nil
+	}
+	return p.srv.Close()
+}
+
+// hopHeaders 不转发的握手头：由 Dialer 重新生成或只对单跳有效
+var hopHeaders = map[string]bool{
+	"Host": true, "Connection": true, "Upgrade": true, "Keep-Alive": true, "Te": true, "Trailer": true,
+	"Transfer-Encoding": true, "Proxy-Authorization": true, "Proxy-Connection": true,
+	"Sec-Websocket-Key": true, "Sec-Websocket-Version": true, "Sec-Websocket-Extensions": true, "Sec-Websocket-Protocol": true,
+}
+
+func (p *WSProxy) targetURL(query url.Values) (string, error) {
+	u, err := url.Parse(p.Target)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for k, vs := range query {
+		q[k] = vs
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ServeHTTP 先连上游，成功后再升级客户端连接；上游握手失败时把其状态码原样返回给客户端
+func (p *WSProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logging.L().With("module", "chaosproxy")
+	target, err := p.targetURL(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hdr := http.Header{}
+	for k, vs := range r.Header {
+		if !hopHeaders[http.CanonicalHeaderKey(k)] {
+			hdr[k] = vs
+		}
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second, Subprotocols: websocket.Subprotocols(r)}
+	server, resp, err := dialer.DialContext(r.Context(), target, hdr)
+	if err != nil {
+		log.Warn("连接上游失败", "target", target, "err", err)
+		if resp != nil {
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, resp.Body)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var respHdr http.Header
+	if sp := server.Subprotocol(); sp != "" {
+		respHdr = http.Header{"Sec-Websocket-Protocol": {sp}}
+	}
+	client, err := p.upgrader.Upgrade(w, r, respHdr)
+	if err != nil {
+		_ = server.Close()
+		return
+	}
+
+	l := &wsLink{p: p, client: client, server: server, done: make(chan struct{})}
+	l.up = newPipe(p.sh, true, true, func(kind int, data []byte) { l.write(server, kind, data) })
+	l.down = newPipe(p.sh, false, true, func(kind int, data []byte) { l.write(client, kind, data) })
+	p.mu.Lock()
+	p.links[l] = struct{}{}
+	if d := p.sh.disconnectAfter(); d > 0 {
+		l.timer = time.AfterFunc(d, l.abort)
+	}
+	p.mu.Unlock()
+	go l.relay(server, l.down)
+	l.relay(client, l.up)
+}
+
+// wsLink 一对客户端/上游连接
+type wsLink struct {
+	p              *WSProxy
+	client, server *websocket.Conn
+	up, down       *pipe
+	timer          *time.Timer // DisconnectAfter，由 p.mu 保护
+	once           sync.Once
+	done           chan struct{}
+}
+
+// relay 读取一侧的消息排入另一侧的 pipe；对端正常关闭时关闭帧也按序转发
+func (l *wsLink) relay(from *websocket.Conn, to *pipe) {
+	for {
+		kind, data, err := from.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				to.push(websocket.CloseMessage, websocket.FormatCloseMessage(ce.Code, ce.Text))
+			} else {
+				l.close()
+			}
+			return
+		}
+		if backlog := to.push(kind, data); backlog > maxBacklog {
+			select {
+			case <-time.After(backlog - maxBacklog):
+			case <-l.done:
+				return
+			}
+		}
+	}
+}
+
+// write 只在对应 pipe 的投递 goroutine 中调用，每个连接只有一个写者
+func (l *wsLink) write(to *websocket.Conn, kind int, data []byte) {
+	if kind == websocket.CloseMessage {
+		_ = to.WriteControl(websocket.CloseMessage, data, time.Now().Add(time.Second))
+		l.close()
+		return
+	}
+	if err := to.WriteMessage(kind, data); err != nil {
+		l.close()
+	}
+}
+
+// abort 模拟断网：不发关闭帧直接断开两侧
+func (l *wsLink) abort() {
+	select {
+	case <-l.done:
+		return
+	default:
+	}
+	l.p.sh.count(func(st *Stats) { st.Disconnects++ })
+	logging.L().With("module", "chaosproxy").Debug("强制断开 WebSocket 连接", "remote", l.client.RemoteAddr().String())
+	l.close()
+}
+
+func (l *wsLink) close() {
+	l.once.Do(func() {
+		close(l.done)
+		l.up.close()
+		l.down.close()
+		_ = l.client.Close()
+		_ = l.server.Close()
+		l.p.mu.Lock()
+		delete(l.p.links, l)
+		timer := l.timer
+		l.p.mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mockserver/mocktest"
+)
+
+// 自动模式下 tts stop 后客户端应自行重新发送 listen start 并回到 listening；
+// MQTT 发布需等待 PUBACK，在下行回调中同步发送会拖住消息分发直到发布超时
+func TestAutoModeRelistensAfterTTS(t *testing.T) {
+	for _, protocol := range []string{"ws", "mqtt"} {
+		t.Run(protocol, func(t *testing.T) {
+			var (
+				c        *client.Client
+				messages func() []map[string]any
+			)
+			changes := make(chan [2]client.DeviceState, 16)
+			setup := func(c *client.Client) {
+				c.OnStateChanged = func(from, to client.DeviceState) { changes <- [2]client.DeviceState{from, to} }
+			}
+			if protocol == "ws" {
+				s := mocktest.StartServer(t, mockserver.Config{})
+				c = mocktest.Dial(t, mocktest.WSConfig(s.URL()), setup)
+				messages = func() []map[string]any { return s.Conns()[0].Messages }
+			} else {
+				b, r := mocktest.StartMQTT(t, mockserver.MQTTConfig{})
+				c = mocktest.Dial(t, mocktest.MQTTConfig(b, mocktest.ClientID), setup)
+				messages = func() []map[string]any { return r.Sessions()[0].Messages }
+			}
+
+			// 丢弃建连期间的 connecting 切换
+			for len(changes) > 0 {
+				<-changes
+			}
+
+			ctx := context.Background()
+			if err := c.SendListenStart(ctx, "auto"); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.SendDetectText(ctx, "你好"); err != nil {
+				t.Fatal(err)
+			}
+			want := [][2]client.DeviceState{
+				{client.StateIdle, client.StateListening},
+				{client.StateListening, client.StateSpeaking},
+				{client.StateSpeaking, client.StateListening},
+			}
+			timeout := time.After(2 * time.Second)
+			for i := 0; i < len(want); i++ {
+				select {
+				case got := <-changes:
+					if got != want[i] {
+						t.Fatalf("transition %d: %s -> %s, want %s -> %s", i, got[0], got[1], want[i][0], want[i][1])
+					}
+				case <-timeout:
+					t.Fatalf("timed out after %d transitions, state %s", i, c.State())
+				}
+			}
+
+			// 服务端处理消息可能晚于客户端发送返回
+			var starts int
+			for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+				starts = 0
+				for _, m := range messages() {
+					if m["type"] == "listen" && m["state"] == "start" {
+						if m["mode"] != "auto" {
+							t.Fatalf("listen start mode %v, want auto", m["mode"])
+						}
+						starts++
+					}
+				}
+				if starts >= 2 || time.Now().After(deadline) {
+					break
+				}
+			}
+			if starts != 2 {
+				t.Errorf("server saw %d listen start, want 2", starts)
+			}
+		})
+	}
+}
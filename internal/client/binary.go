@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myproject/internal/logging"
+	"myproject/internal/transport"
+)
+
+// mediaClock 单调毫秒时钟，会话建立时归零；v2 帧时间戳取自该时钟，供服务端 AEC 对齐
+type mediaClock struct {
+	mu    sync.Mutex
+	start time.Time
+}
+
+func (m *mediaClock) Reset() {
+	m.mu.Lock()
+	m.start = time.Now()
+	m.mu.Unlock()
+}
+
+// Millis 返回自会话开始的毫秒数（基于单调时钟，不受系统时间调整影响）
+func (m *mediaClock) Millis() uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		m.start = time.Now()
+	}
+	return uint32(time.Since(m.start).Milliseconds())
+}
+
+// 下行 v2 帧时间戳间隔超出该帧数视为流中断/重置，不按丢包处理
+const maxWSGapFrames = 5
+
+// 连续这么多个下行帧无法按协商版本解析时，认为服务端实际发送裸 Opus，回退到版本1
+const maxBinaryDecodeErrors = 10
+
+// downstreamGap 记录 v2 下行时间戳，跳变为整数倍帧长时返回丢失的帧数
+type downstreamGap struct {
+	mu      sync.Mutex
+	frameMs uint32
+	lastTs  uint32
+	seen    bool
+}
+
+// Reset 新会话开始时调用；frameMs 为服务端下行帧长
+func (g *downstreamGap) Reset(frameMs int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if frameMs <= 0 {
+		frameMs = 60
+	}
+	g.frameMs, g.seen = uint32(frameMs), false
+}
+
+func (g *downstreamGap) Observe(ts uint32) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	last, seen := g.lastTs, g.seen
+	g.lastTs, g.seen = ts, true
+	if !seen || g.frameMs == 0 || int32(ts-last) <= 0 {
+		return 0
+	}
+	lost := int((ts-last+g.frameMs/2)/g.frameMs) - 1
+	if lost < 1 || lost > maxWSGapFrames {
+		return 0
+	}
+	return lost
+}
+
+// supportedBinaryVersion 将配置的协议版本映射为已实现的二进制帧版本
+func supportedBinaryVersion(v int) int {
+	switch v {
+	case 2, 3:
+		return v
+	default:
+		return 1
+	}
+}
+
+// BinaryProtocolVersion 返回 WebSocket 当前使用的二进制帧协议版本
+func (c *Client) BinaryProtocolVersion() int { return int(atomic.LoadInt32(&c.binVersion)) }
+
+func (c *Client) setBinaryVersion(v int) {
+	atomic.StoreInt32(&c.binVersion, int32(v))
+	atomic.StoreInt32(&c.binErrs, 0)
+}
+
+// negotiateBinaryVersion 服务端 hello 带 version 且低于本端时降级（仅支持版本1的服务端会回 version=1）
+func (c *Client) negotiateBinaryVersion(msg map[string]any) {
+	sv, ok := msg["version"].(float64)
+	if !ok || sv <= 0 {
+		return
+	}
+	if cur := c.BinaryProtocolVersion(); int(sv) < cur {
+		logging.L().With("module", "ws").Info("服务端协议版本较低，二进制帧降级", "from", cur, "to", int(sv))
+		c.setBinaryVersion(supportedBinaryVersion(int(sv)))
+	}
+}
+
+// resetDownstreamGap 按服务端 hello 下发的帧长重置下行丢帧检测
+func (c *Client) resetDownstreamGap(msg map[string]any) {
+	frameMs := c.cfg.Audio.FrameDuration
+	if ap, ok := msg["audio_params"].(map[string]any); ok {
+		if fd, ok := ap["frame_duration"].(float64); ok && fd > 0 {
+			frameMs = int(fd)
+		}
+	}
+	c.downGap.Reset(frameMs)
+}
+
+// encodeWSAudio 按当前协议版本封装上行 Opus 帧
+func (c *Client) encodeWSAudio(opus []byte) ([]byte, error) {
+	switch c.BinaryProtocolVersion() {
+	case 2:
+		return transport.EncodeBinaryProtocol2(transport.BinaryTypeOpus, c.clock.Millis(), opus), nil
+	case 3:
+		return transport.EncodeBinaryProtocol3(transport.BinaryTypeOpus, opus)
+	default:
+		return opus, nil
+	}
+}
+
+// handleWSBinary 解析下行二进制帧：音频交给 deliverAudio，JSON 负载交给文本处理
+func (c *Client) handleWSBinary(ctx context.Context, data []byte, onText func(context.Context, []byte)) {
+	var (
+		frame transport.BinaryFrame
+		err   error
+	)
+	switch c.BinaryProtocolVersion() {
+	case 2:
+		frame, err = transport.DecodeBinaryProtocol2(data)
+	case 3:
+		frame, err = transport.DecodeBinaryProtocol3(data)
+	default:
+		frame = transport.BinaryFrame{Version: 1, Type: transport.BinaryTypeOpus, Payload: data}
+	}
+	if err != nil {
+		// 单个坏帧直接丢弃；持续失败说明服务端仍按版本1发送裸 Opus，回退到版本1保证双向兼容
+		log := logging.L().With("module", "ws")
+		if n := atomic.AddInt32(&c.binErrs, 1); n < maxBinaryDecodeErrors {
+			log.Debug("二进制帧解析失败，丢弃", "err", err, "len", len(data))
+			return
+		}
+		log.Warn("二进制帧持续解析失败，回退协议版本1", "err", err, "count", maxBinaryDecodeErrors)
+		c.setBinaryVersion(1)
+		frame = transport.BinaryFrame{Version: 1, Type: transport.BinaryTypeOpus, Payload: data}
+	} else {
+		atomic.StoreInt32(&c.binErrs, 0)
+	}
+	// 非音频类型（如 JSON）走文本处理，避免送入 Opus 解码器
+	if frame.Type != transport.BinaryTypeOpus {
+		if onText != nil {
+			onText(ctx, frame.Payload)
+		}
+		return
+	}
+	// v2 帧带时间戳，可据此发现丢帧
+	if frame.Version == 2 {
+		if lost := c.downGap.Observe(frame.Timestamp); lost > 0 && c.OnAudioGap != nil {
+			c.OnAudioGap(ctx, lost)
+		}
+	}
+	c.deliverAudio(ctx, frame.Payload)
+}
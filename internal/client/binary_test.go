@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"myproject/internal/transport"
+)
+
+func TestHandleWSBinaryDropsBadFrame(t *testing.T) {
+	c := New(DefaultConfig())
+	c.setBinaryVersion(3)
+	var got [][]byte
+	c.OnBinary = func(_ context.Context, data []byte) { got = append(got, data) }
+
+	good, _ := transport.EncodeBinaryProtocol3(transport.BinaryTypeOpus, []byte("opus"))
+	c.handleWSBinary(context.Background(), []byte{0x00, 0x00, 0x00, 0x09, 0x01}, nil)
+	c.handleWSBinary(context.Background(), good, nil)
+	if v := c.BinaryProtocolVersion(); v != 3 {
+		t.Fatalf("version %d after one bad frame, want 3", v)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], []byte("opus")) {
+		t.Fatalf("delivered %q, want only the good frame", got)
+	}
+	// 上行仍按 v3 封装
+	if up, _ := c.encodeWSAudio([]byte("up")); len(up) != 6 || up[0] != transport.BinaryTypeOpus {
+		t.Errorf("upstream frame %x, want v3", up)
+	}
+}
+
+func TestHandleWSBinaryFallsBackAfterRepeatedErrors(t *testing.T) {
+	c := New(DefaultConfig())
+	c.setBinaryVersion(2)
+	var got [][]byte
+	c.OnBinary = func(_ context.Context, data []byte) { got = append(got, data) }
+
+	// 间隔出现的坏帧不累计
+	good := transport.EncodeBinaryProtocol2(transport.BinaryTypeOpus, 0, []byte("ok"))
+	for i := 0; i <= 3*(maxBinaryDecodeErrors-1); i++ {
+		if i%(maxBinaryDecodeErrors-1) == 0 {
+			c.handleWSBinary(context.Background(), good, nil)
+		} else {
+			c.handleWSBinary(context.Background(), []byte("raw"), nil)
+		}
+	}
+	if v := c.BinaryProtocolVersion(); v != 2 {
+		t.Fatalf("version %d with interleaved good frames, want 2", v)
+	}
+
+	// 服务端实际发送裸 Opus：连续失败后回退到版本1
+	got = nil
+	for i := 0; i < maxBinaryDecodeErrors; i++ {
+		c.handleWSBinary(context.Background(), []byte("raw"), nil)
+	}
+	if v := c.BinaryProtocolVersion(); v != 1 {
+		t.Fatalf("version %d after %d bad frames, want 1", v, maxBinaryDecodeErrors)
+	}
+	c.handleWSBinary(context.Background(), []byte("raw"), nil)
+	if len(got) != 2 {
+		t.Errorf("delivered %d raw frames after fallback, want 2", len(got))
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,16 +26,70 @@ type Client struct {
 
 	SessionID string
 
+	// OnJSON 接收全部服务端 JSON 消息（原始结构）
 	OnJSON    func(ctx context.Context, msg map[string]any)
 	OnBinary  func(ctx context.Context, data []byte)
+	// OnAudioGap 下行丢失 lost 帧（UDP 抖动缓冲或 WebSocket v2 时间戳判定），可据此做丢包隐藏
+	OnAudioGap func(ctx context.Context, lost int)
 	OnError   func(ctx context.Context, err error)
 	OnClosed  func()
 
-	mu      sync.RWMutex
-	helloCh chan struct{}
+	// 按消息类型的回调（见 types.go），未识别的 type 交给 OnUnknown
+	OnHello   func(ctx context.Context, msg HelloResponse)
+	OnSTT     func(ctx context.Context, msg STTMessage)
+	OnLLM     func(ctx context.Context, msg LLMMessage)
+	OnTTS     func(ctx context.Context, msg TTSMessage)
+	OnMCP     func(ctx context.Context, msg MCPMessage)
+	OnSystem  func(ctx context.Context, msg SystemMessage)
+	OnCustom  func(ctx context.Context, msg CustomMessage)
+	OnGoodbye func(ctx context.Context, msg GoodbyeMessage)
+	OnUnknown func(ctx context.Context, msg map[string]any)
+
+	// 自动重连（Config.Reconnect）状态事件；重连后 session_id 变化时触发 OnSessionChanged
+	OnReconnect      func(ev ReconnectEvent)
+	OnSessionChanged func(oldID, newID string)
+
+	// 会话状态切换（见 state.go）
+	OnStateChanged func(from, to DeviceState)
+
+	// OnWire 线级旁路：收发的每个文本/二进制帧（含 hello、UDP 音频），用于会话录制（见 internal/wirelog）；
+	// 在收发 goroutine 中同步调用，不可阻塞
+	OnWire func(f transport.WireFrame)
+
+	// RedirectUDP 非 nil 时改写服务端 hello 下发的 UDP 地址，返回实际连接的地址（如 internal/chaosproxy 的本地转发端口）
+	RedirectUDP func(server string, port int) (string, int)
+
+	// MCP 设备端服务，hello 中声明 features.mcp=true，工具通过 MCP.AddTool 注册
+	MCP *McpServer
+
+	// Encoder Speak 使用的 PCM 编码器（如 audio.PCM16Encoder），未设置时 Speak 返回错误
+	Encoder PCMEncoder
+
+	mu        sync.RWMutex
+	helloCh   chan struct{}
+	helloResp *HelloResponse
+
+	// WebSocket 二进制帧协议版本（1/2/3），由 ProtocolVersion 与服务端 hello 协商
+	binVersion int32
+	binErrs    int32 // 连续解析失败的下行二进制帧数
+	clock      mediaClock
+	downGap    downstreamGap
+
+	stateMu    sync.Mutex
+	state      DeviceState
+	listenMode string
+
+	turnMu sync.Mutex
+	turn   *Turn
+
+	closing      int32 // 用户主动关闭，不触发重连
+	reconnecting int32
+	reconnCancel context.CancelFunc
 }
 
-func New(cfg Config) *Client { return &Client{cfg: cfg} }
+func New(cfg Config) *Client {
+	return &Client{cfg: cfg, MCP: NewMcpServer("xiaozhi-client-go", "1.0.0")}
+}
 
 // Open 根据协议打开连接："ws" 或 "mqtt"（对应 MQTT+UDP）
 func (c *Client) Open(ctx context.Context, protocol string) error {
@@ -53,11 +109,20 @@ func (c *Client) SwitchProtocol(ctx context.Context, protocol string) error {
 	return c.Open(ctx, protocol)
 }
 
-func (c *Client) OpenWebsocket(ctx context.Context) error {
+func (c *Client) OpenWebsocket(ctx context.Context) (err error) {
 	if c.cfg.WebsocketURL == "" {
 		return errors.New("websocket url required")
 	}
+	c.setState(StateConnecting)
+	defer func() {
+		if err != nil {
+			c.setState(StateIdle)
+		}
+	}()
 	baseURL := c.cfg.WebsocketURL
+	if !c.IsReconnecting() {
+		atomic.StoreInt32(&c.closing, 0)
+	}
 
 	// 构造鉴权配置
 	type attempt struct {
@@ -70,7 +135,7 @@ func (c *Client) OpenWebsocket(ctx context.Context) error {
 
 	// 公共头
 	commonHeaders := map[string]string{
-		"Protocol-Version": "1",
+		"Protocol-Version": strconv.Itoa(c.cfg.ProtocolVersion),
 	}
 	if c.cfg.DeviceID != "" {
 		commonHeaders["Device-Id"] = c.cfg.DeviceID
@@ -179,8 +244,8 @@ func (c *Client) OpenWebsocket(ctx context.Context) error {
 		return out
 	}
 
-	helloSent := false
-	helloRecv := false
+	// 读取 goroutine 与本函数都会访问，用原子操作
+	var helloSent, helloRecv int32
 
 	log := logging.L().With("module", "ws")
 	// 在尝试前输出请求内容（已脱敏）
@@ -188,52 +253,70 @@ func (c *Client) OpenWebsocket(ctx context.Context) error {
 
 	report := func(phase string, base error) error {
 		diag := fmt.Errorf("ws %s: %v", phase, base)
-		log.Warn("ws error", "phase", phase, "err", base, "url", sanitizeURL(att.url), "helloSent", helloSent, "serverHello", helloRecv, "token", att.tokenPlacement)
+		log.Warn("ws error", "phase", phase, "err", base, "url", sanitizeURL(att.url), "helloSent", atomic.LoadInt32(&helloSent) == 1, "serverHello", atomic.LoadInt32(&helloRecv) == 1, "token", att.tokenPlacement)
 		if c.OnError != nil {
 			c.OnError(ctx, diag)
 		}
 		return diag
 	}
 
-	w := transport.NewWebsocketTransport(att.url, transport.Handlers{
-		OnText: func(ctx2 context.Context, text []byte) {
-			var msg map[string]any
-			if err := json.Unmarshal(text, &msg); err == nil {
-				if t, ok := msg["type"].(string); ok && t == "hello" {
-					if sid, ok := msg["session_id"].(string); ok {
-						c.SessionID = sid
-					}
-					helloRecv = true
-					c.mu.Lock()
-					ch := c.helloCh
-					c.helloCh = nil
-					c.mu.Unlock()
-					if ch != nil {
-						close(ch)
-					}
-				}
-				if c.OnJSON != nil {
-					c.OnJSON(ctx2, msg)
+	c.setBinaryVersion(supportedBinaryVersion(c.cfg.ProtocolVersion))
+
+	onText := func(ctx2 context.Context, text []byte) {
+		var msg map[string]any
+		if err := json.Unmarshal(text, &msg); err == nil {
+			if t, ok := msg["type"].(string); ok && t == "hello" {
+				if sid, ok := msg["session_id"].(string); ok {
+					c.setSessionID(sid)
 				}
+				c.negotiateBinaryVersion(msg)
+				c.resetDownstreamGap(msg)
+				c.clock.Reset()
+				_ = c.transition(StateIdle, StateConnecting)
+				atomic.StoreInt32(&helloRecv, 1)
+				c.completeHello(nil)
 			}
-		},
+			c.dispatchJSON(ctx2, text, msg)
+		}
+	}
+
+	var w *transport.WebsocketTransport
+	w = transport.NewWebsocketTransport(att.url, transport.Handlers{
+		OnText: onText,
 		OnBinary: func(ctx2 context.Context, data []byte) {
-			if c.OnBinary != nil {
-				c.OnBinary(ctx2, data)
-			}
+			c.handleWSBinary(ctx2, data, onText)
 		},
 		OnError: func(ctx2 context.Context, err error) {
 			_ = report("error", err)
 		},
 		OnClosed: func() {
+			c.mu.RLock()
+			current := c.ws == w
+			c.mu.RUnlock()
+			established := atomic.LoadInt32(&helloRecv) == 1
+			if current && established {
+				c.endTurn(nil, ErrTurnClosed)
+				c.setState(StateIdle)
+			}
+			// 已完成握手的当前连接意外断开：交给重连流程，OnClosed 延后到放弃重连时触发
+			if current && established && c.shouldReconnect() {
+				c.startReconnect("ws")
+				return
+			}
+			// 重连过程中的失败尝试不重复上报关闭
+			if c.IsReconnecting() {
+				return
+			}
 			if c.OnClosed != nil {
 				c.OnClosed()
 			}
 		},
-	})
+	}.Tap("ws", c.tapWire))
 
+	c.mu.Lock()
 	c.ws = w
-	if err := c.ws.Open(ctx, att.headers); err != nil {
+	c.mu.Unlock()
+	if err := w.Open(ctx, att.headers); err != nil {
 		return report("handshake", err)
 	}
 
@@ -250,150 +333,392 @@ func (c *Client) OpenWebsocket(ctx context.Context) error {
 	// 在发送前输出 hello payload
 	log.Debug("ws hello", "payload", string(b))
 
-	if err := c.ws.SendText(ctx, b); err != nil {
-		_ = c.ws.Close()
+	// 发送前登记等待通道，避免服务端应答过快而丢失
+	ch := c.armHello()
+	c.tapUp("ws", false, b)
+	if err := w.SendText(ctx, b); err != nil {
+		_ = w.Close()
 		return report("send-hello", err)
 	}
-	helloSent = true
+	atomic.StoreInt32(&helloSent, 1)
 
 	// 等待服务端 hello
-	c.mu.Lock()
-	c.helloCh = make(chan struct{})
-	ch := c.helloCh
-	c.mu.Unlock()
-
 	select {
 	case <-ch:
 		return nil
 	case <-time.After(c.cfg.HelloTimeout):
-		_ = c.ws.Close()
+		_ = w.Close()
 		return report("hello-timeout", errors.New("hello timeout"))
 	case <-ctx.Done():
-		_ = c.ws.Close()
+		_ = w.Close()
 		return report("ctx-cancel", ctx.Err())
 	}
 }
 
+// OpenMQTT 建立 MQTT 控制通道并完成 hello 握手：等待服务端应答（HelloTimeout），
+// 校验 transport 与 udp 参数后打开 UDP 音频通道才返回
 func (c *Client) OpenMQTT(ctx context.Context) error {
 	if c.cfg.MQTTBroker == "" { return errors.New("mqtt broker required") }
+	if !c.IsReconnecting() { atomic.StoreInt32(&c.closing, 0) }
 	clientID := c.cfg.ClientID; if clientID == "" { clientID = uuid.NewString() }
-	c.mqtt = transport.NewMQTTControl(c.cfg.MQTTBroker, clientID, c.cfg.MQTTUsername, c.cfg.MQTTPassword, c.cfg.MQTTPublishTopic, c.cfg.MQTTSubscribeTopic, c.cfg.MQTTKeepAliveSec, transport.Handlers{
+	c.setState(StateConnecting)
+	defer c.setState(StateIdle)
+
+	log := logging.L().With("module", "mqtt")
+	report := func(phase string, base error) error {
+		diag := fmt.Errorf("mqtt %s: %w", phase, base)
+		log.Warn("mqtt error", "phase", phase, "err", base, "broker", c.cfg.MQTTBroker)
+		if c.OnError != nil { c.OnError(ctx, diag) }
+		return diag
+	}
+
+	m := transport.NewMQTTControl(c.cfg.MQTTBroker, clientID, c.cfg.MQTTUsername, c.cfg.MQTTPassword, c.cfg.MQTTPublishTopic, c.cfg.MQTTSubscribeTopic, c.cfg.MQTTKeepAliveSec, transport.Handlers{
 		OnText:   func(ctx context.Context, text []byte) { c.onMQTTMessage(ctx, text) },
 		OnError:  func(ctx context.Context, err error) { if c.OnError != nil { c.OnError(ctx, err) } },
-		OnClosed: func() { if c.OnClosed != nil { c.OnClosed() } },
-	})
-	if err := c.mqtt.Open(ctx, nil); err != nil { if c.OnError != nil { c.OnError(ctx, err) }; return err }
+		OnClosed: func() { c.endTurn(nil, ErrTurnClosed); c.setState(StateIdle); if c.OnClosed != nil { c.OnClosed() } },
+	}.Tap("mqtt", c.tapWire))
+	c.mu.Lock()
+	c.mqtt = m
+	c.mu.Unlock()
+	fail := func(phase string, base error) error {
+		_ = m.Close()
+		c.mu.Lock()
+		if c.mqtt == m { c.mqtt = nil }
+		c.mu.Unlock()
+		return report(phase, base)
+	}
+	m.OnReconnected = func() { c.renewMQTTSession(m) }
+	if err := m.Open(ctx, nil); err != nil { return fail("connect", err) }
+	if phase, err := c.mqttHandshake(ctx, m); err != nil { return fail(phase, err) }
+	return nil
+}
+
+// mqttHandshake 发送 hello 并等待服务端应答，随后按下发的 udp 参数打开 UDP 音频通道；
+// 失败时返回所处阶段
+func (c *Client) mqttHandshake(ctx context.Context, m *transport.MQTTControl) (string, error) {
 	hello := HelloMessage{Type: "hello", Version: c.cfg.ProtocolVersion, Transport: "udp", AudioParams: c.cfg.Audio, Features: map[string]any{"mcp": true}}
 	b, _ := json.Marshal(hello)
 	logging.L().With("module", "mqtt").Debug("mqtt hello", "payload", string(b))
-	return c.mqtt.SendText(ctx, b)
+	ch := c.armHello()
+	c.tapUp("mqtt", false, b)
+	if err := m.SendText(ctx, b); err != nil { return "send-hello", err }
+
+	select {
+	case <-ch:
+	case <-time.After(c.cfg.HelloTimeout):
+		return "hello-timeout", errors.New("hello timeout")
+	case <-ctx.Done():
+		return "ctx-cancel", ctx.Err()
+	}
+	resp := c.takeHelloResponse()
+	if resp == nil || resp.Transport != "udp" {
+		transportName := ""
+		if resp != nil { transportName = resp.Transport }
+		return "hello", fmt.Errorf("unexpected transport %q, want \"udp\"", transportName)
+	}
+	if err := c.openUDP(ctx, resp); err != nil { return "udp", err }
+	return "", nil
+}
+
+// renewMQTTSession 在 broker 自动重连后重新握手：旧的 UDP 会话（key/nonce）已失效，
+// 需用新 hello 下发的参数重建，并通过 OnReconnect（及 setSessionID 触发的 OnSessionChanged）通知上层
+func (c *Client) renewMQTTSession(m *transport.MQTTControl) {
+	c.mu.RLock()
+	current := c.mqtt == m
+	c.mu.RUnlock()
+	if !current || atomic.LoadInt32(&c.closing) == 1 {
+		return
+	}
+	log := logging.L().With("module", "mqtt")
+	c.emitReconnect(ReconnectEvent{State: ReconnectStateAttempting, Protocol: "mqtt"})
+	c.setState(StateConnecting)
+	defer c.setState(StateIdle)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.HelloTimeout+5*time.Second)
+	defer cancel()
+	if phase, err := c.mqttHandshake(ctx, m); err != nil {
+		diag := fmt.Errorf("mqtt renew %s: %w", phase, err)
+		log.Warn("会话重建失败", "phase", phase, "err", err)
+		if c.OnError != nil { c.OnError(ctx, diag) }
+		c.emitReconnect(ReconnectEvent{State: ReconnectStateFailed, Protocol: "mqtt", Err: diag})
+		return
+	}
+	log.Info("会话已重建", "session_id", c.GetSessionID())
+	c.emitReconnect(ReconnectEvent{State: ReconnectStateRenewed, Protocol: "mqtt"})
+}
+
+// openUDP 按服务端 hello 中的 udp 参数（重新）建立 UDP 音频通道
+func (c *Client) openUDP(ctx context.Context, resp *HelloResponse) error {
+	if resp.UDP == nil { return errors.New("hello missing udp block") }
+	if err := resp.UDP.Validate(); err != nil { return err }
+	host, port := resp.UDP.Server, resp.UDP.Port
+	if c.RedirectUDP != nil { host, port = c.RedirectUDP(host, port) }
+	var u *transport.UDPAudio
+	u = transport.NewUDPAudio(host, port, resp.UDP.KeyHex, resp.UDP.NonceHex, transport.UDPAudioHandlers{
+		OnAudioFrame: func(ctx context.Context, opus []byte) { c.deliverAudio(ctx, opus) },
+		OnPacket: func(ctx context.Context, seq uint32, opus []byte) { c.tapWire(transport.WireFrame{Dir: transport.WireDown, Channel: "udp", Binary: true, Data: opus}) },
+		OnAudioGap: func(ctx context.Context, lost int) { if c.OnAudioGap != nil { c.OnAudioGap(ctx, lost) } },
+		OnError: func(ctx context.Context, err error) { if c.OnError != nil { c.OnError(ctx, err) } },
+		OnClosed: func() {
+			// 重新握手后被替换的旧 UDP 通道关闭不代表连接断开
+			c.mu.RLock()
+			current := c.udp == u
+			c.mu.RUnlock()
+			if current && c.OnClosed != nil { c.OnClosed() }
+		},
+	})
+	// 下行帧长以服务端 hello 为准
+	frameMs := c.cfg.Audio.FrameDuration
+	if resp.AudioParams != nil && resp.AudioParams.FrameDuration > 0 { frameMs = resp.AudioParams.FrameDuration }
+	u.JitterWindow = c.cfg.UDPJitterWindow
+	u.JitterPrebuffer = c.cfg.UDPJitterPrebuffer
+	u.FrameDuration = time.Duration(frameMs) * time.Millisecond
+	if err := u.Open(); err != nil { return fmt.Errorf("open udp %s:%d: %w", host, port, err) }
+	c.mu.Lock()
+	// 若已存在 UDP 连接，先关闭，避免泄漏
+	old := c.udp
+	c.udp = u
+	c.mu.Unlock()
+	if old != nil { _ = old.Close() }
+	if resp.SessionID != "" { c.setSessionID(resp.SessionID) }
+	return nil
 }
 
 func (c *Client) onMQTTMessage(ctx context.Context, text []byte) {
-	var resp MqttHelloResponse
-	if err := json.Unmarshal(text, &resp); err == nil {
-		if resp.Type == "hello" && resp.Transport == "udp" && resp.UDP != nil {
-			c.SessionID = resp.SessionID
-			// 若已存在 UDP 连接，先关闭，避免泄漏
-			if c.udp != nil {
-				_ = c.udp.Close()
-				c.udp = nil
-			}
-			u := transport.NewUDPAudio(resp.UDP.Server, resp.UDP.Port, resp.UDP.KeyHex, resp.UDP.NonceHex, transport.UDPAudioHandlers{
-				OnAudioFrame: func(ctx context.Context, opus []byte) { if c.OnBinary != nil { c.OnBinary(ctx, opus) } },
-				OnError: func(ctx context.Context, err error) { if c.OnError != nil { c.OnError(ctx, err) } },
-				OnClosed: func() { if c.OnClosed != nil { c.OnClosed() } },
-			})
-			if err := u.Open(); err == nil { c.udp = u } else { if c.OnError != nil { c.OnError(ctx, err) } }
-		}
+	var resp HelloResponse
+	if err := json.Unmarshal(text, &resp); err == nil && resp.Type == MsgTypeHello {
+		c.completeHello(&resp)
 	}
 	var msg map[string]any
-	if err := json.Unmarshal(text, &msg); err == nil { if c.OnJSON != nil { c.OnJSON(ctx, msg) } }
+	if err := json.Unmarshal(text, &msg); err == nil { c.dispatchJSON(ctx, text, msg) }
 }
 
-func (c *Client) SendListenStart(ctx context.Context, mode string) error {
-	if c.SessionID == "" { return errors.New("no session") }
-	msg := map[string]any{"session_id": c.SessionID, "type": "listen", "state": "start", "mode": mode}
-	b, _ := json.Marshal(msg)
-	if c.ws != nil { return c.ws.SendText(ctx, b) }
-	if c.mqtt != nil { return c.mqtt.SendText(ctx, b) }
+// armHello 在发送 hello 前登记等待通道
+func (c *Client) armHello() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.helloCh = make(chan struct{})
+	c.helloResp = nil
+	return c.helloCh
+}
+
+// completeHello 记录服务端 hello 应答并唤醒等待方；无等待方时返回 false
+func (c *Client) completeHello(resp *HelloResponse) bool {
+	c.mu.Lock()
+	ch := c.helloCh
+	c.helloCh = nil
+	if ch != nil {
+		c.helloResp = resp
+	}
+	c.mu.Unlock()
+	if ch == nil {
+		return false
+	}
+	close(ch)
+	return true
+}
+
+func (c *Client) takeHelloResponse() *HelloResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := c.helloResp
+	c.helloResp = nil
+	return resp
+}
+
+// dispatchJSON 处理协议内部消息（如 mcp）后交给 OnJSON 及按类型的回调
+func (c *Client) dispatchJSON(ctx context.Context, text []byte, msg map[string]any) {
+	t, _ := msg["type"].(string)
+	c.onStateMessage(ctx, t, text)
+	if t == MsgTypeMCP {
+		var env struct {
+			Payload json.RawMessage `json:"payload"`
+		}
+		// 工具调用可能耗时，避免阻塞读循环
+		if err := json.Unmarshal(text, &env); err == nil {
+			go c.handleMCP(ctx, env.Payload)
+		}
+	}
+	if c.OnJSON != nil {
+		c.OnJSON(ctx, msg)
+	}
+	c.dispatchTyped(ctx, t, text, msg)
+	c.feedTurn(t, msg)
+}
+
+// deliverAudio 下行 Opus 帧（WebSocket 或 UDP）交给进行中的轮次与 OnBinary
+func (c *Client) deliverAudio(ctx context.Context, opus []byte) {
+	c.feedTurnAudio(opus)
+	if c.OnBinary != nil {
+		c.OnBinary(ctx, opus)
+	}
+}
+
+// sendText 通过当前控制通道发送文本消息
+func (c *Client) sendText(ctx context.Context, b []byte) error {
+	c.mu.RLock()
+	ws, mq := c.ws, c.mqtt
+	c.mu.RUnlock()
+	if ws != nil { c.tapUp("ws", false, b); return ws.SendText(ctx, b) }
+	if mq != nil { c.tapUp("mqtt", false, b); return mq.SendText(ctx, b) }
 	return errors.New("no transport")
 }
 
+func (c *Client) tapWire(f transport.WireFrame) {
+	if c.OnWire != nil { c.OnWire(f) }
+}
+
+// tapUp 在发送前记录上行帧：应答可能在发送调用返回前到达（如 MQTT 等待 PUBACK），
+// 先记录才能保持请求与应答的先后顺序；发送失败的帧同样会被记录
+func (c *Client) tapUp(channel string, binary bool, data []byte) {
+	c.tapWire(transport.WireFrame{Dir: transport.WireUp, Channel: channel, Binary: binary, Data: data})
+}
+
+func (c *Client) sendListen(ctx context.Context, state, mode string) error {
+	sid := c.GetSessionID()
+	if sid == "" { return errors.New("no session") }
+	msg := map[string]any{"session_id": sid, "type": "listen", "state": state, "mode": mode}
+	b, _ := json.Marshal(msg)
+	// listen stop 须排在已入队的上行音频之后，否则服务端会在收齐音频前结束识别
+	if state == "stop" {
+		c.mu.RLock()
+		ws := c.ws
+		c.mu.RUnlock()
+		if ws != nil { c.tapUp("ws", false, b); return ws.SendTextAfterAudio(ctx, b) }
+	}
+	return c.sendText(ctx, b)
+}
+
+// SendListenStart 开始监听：idle/listening → listening；说话中需先 SendAbort
+func (c *Client) SendListenStart(ctx context.Context, mode string) error {
+	if err := c.checkState("listen start", StateIdle, StateListening); err != nil { return err }
+	if err := c.sendListen(ctx, "start", mode); err != nil { return err }
+	c.stateMu.Lock()
+	c.listenMode = mode
+	c.stateMu.Unlock()
+	c.setState(StateListening)
+	return nil
+}
+
 func (c *Client) SendDetectText(ctx context.Context, text string) error {
-	if c.SessionID == "" { return errors.New("no session") }
-	msg := map[string]any{"session_id": c.SessionID, "type": "listen", "state": "detect", "text": text, "source": "text"}
+	sid := c.GetSessionID()
+	if sid == "" { return errors.New("no session") }
+	if err := c.checkState("detect", StateIdle, StateListening); err != nil { return err }
+	msg := map[string]any{"session_id": sid, "type": "listen", "state": "detect", "text": text, "source": "text"}
 	b, _ := json.Marshal(msg)
-	if c.ws != nil { return c.ws.SendText(ctx, b) }
-	if c.mqtt != nil { return c.mqtt.SendText(ctx, b) }
-	return errors.New("no transport")
+	return c.sendText(ctx, b)
 }
 
+// SendListenStop 停止监听：listening → idle
 func (c *Client) SendListenStop(ctx context.Context, mode string) error {
-	if c.SessionID == "" { return errors.New("no session") }
-	msg := map[string]any{"session_id": c.SessionID, "type": "listen", "state": "stop", "mode": mode}
-	b, _ := json.Marshal(msg)
-	if c.ws != nil { return c.ws.SendText(ctx, b) }
-	if c.mqtt != nil { return c.mqtt.SendText(ctx, b) }
-	return errors.New("no transport")
+	if err := c.checkState("listen stop", StateListening); err != nil { return err }
+	if err := c.sendListen(ctx, "stop", mode); err != nil { return err }
+	c.setState(StateIdle)
+	return nil
 }
 
+// SendAbort 中断当前说话或监听：speaking/listening → idle
 func (c *Client) SendAbort(ctx context.Context, reason string) error {
-	if c.SessionID == "" { return nil }
-	msg := map[string]any{"session_id": c.SessionID, "type": "abort", "reason": reason}
+	sid := c.GetSessionID()
+	if sid == "" { return nil }
+	if err := c.checkState("abort", StateSpeaking, StateListening); err != nil { return err }
+	msg := map[string]any{"session_id": sid, "type": "abort", "reason": reason}
 	b, _ := json.Marshal(msg)
-	if c.ws != nil { return c.ws.SendText(ctx, b) }
-	if c.mqtt != nil { return c.mqtt.SendText(ctx, b) }
-	return errors.New("no transport")
+	if err := c.sendText(ctx, b); err != nil { return err }
+	c.setState(StateIdle)
+	return nil
 }
 
 // 新增：发送 Goodbye，遵循文档 3.3.1/3.3.2 关闭流程
 func (c *Client) SendGoodbye(ctx context.Context) error {
-	if c.SessionID == "" { return nil }
-	msg := map[string]any{"session_id": c.SessionID, "type": "goodbye"}
+	sid := c.GetSessionID()
+	if sid == "" { return nil }
+	msg := map[string]any{"session_id": sid, "type": "goodbye"}
 	b, _ := json.Marshal(msg)
-	if c.ws != nil { return c.ws.SendText(ctx, b) }
-	if c.mqtt != nil { return c.mqtt.SendText(ctx, b) }
-	return errors.New("no transport")
+	return c.sendText(ctx, b)
 }
 
 func (c *Client) Close() {
+	atomic.StoreInt32(&c.closing, 1)
+
+	// 停止进行中的自动重连
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	reconnCancel := c.reconnCancel
+	c.reconnCancel = nil
+	c.mu.Unlock()
+	if reconnCancel != nil { reconnCancel() }
 
-	// 按文档先发送 Goodbye（忽略发送错误）
-	_ = c.SendGoodbye(context.Background())
+	// 按文档先发送 Goodbye（忽略发送错误），写队列阻塞时不拖住关闭流程
+	gctx, gcancel := context.WithTimeout(context.Background(), time.Second)
+	_ = c.SendGoodbye(gctx)
+	gcancel()
 
-	if c.udp != nil {
-		_ = c.udp.Close()
-		c.udp = nil
-	}
-	if c.ws != nil {
-		_ = c.ws.Close()
-		c.ws = nil
-	}
-	if c.mqtt != nil {
-		_ = c.mqtt.Close()
-		c.mqtt = nil
-	}
+	// 锁内只摘下连接；关闭连接、结束轮次与状态回调（OnClosed、OnStateChanged 等）在释放锁后进行，回调中可再调用 Client 方法
+	c.mu.Lock()
+	udp, ws, mq := c.udp, c.ws, c.mqtt
+	c.ws, c.mqtt = nil, nil
 	c.SessionID = ""
+	c.mu.Unlock()
+
+	// UDP 关闭时仍是当前通道，OnClosed 照常上报（MQTT 控制通道主动关闭不触发 OnClosed）
+	if udp != nil {
+		_ = udp.Close()
+		c.mu.Lock()
+		if c.udp == udp { c.udp = nil }
+		c.mu.Unlock()
+	}
+	if ws != nil { _ = ws.Close() }
+	if mq != nil { _ = mq.Close() }
+	c.endTurn(nil, ErrTurnClosed)
+	c.setState(StateIdle)
 }
 
 func (c *Client) SendOpusUpstream(ctx context.Context, opus []byte) error {
+	if c.State() == StateConnecting {
+		return fmt.Errorf("%w: audio not allowed in %s", ErrInvalidState, StateConnecting)
+	}
 	c.mu.RLock()
 	udp := c.udp
 	ws := c.ws
 	c.mu.RUnlock()
 
 	if udp != nil {
+		c.tapUp("udp", true, opus)
 		return udp.SendOpusFrame(opus)
 	}
 	if ws != nil {
-		return ws.SendBinary(ctx, opus)
+		frame, err := c.encodeWSAudio(opus)
+		if err != nil {
+			return err
+		}
+		c.tapUp("ws", true, frame)
+		return ws.SendBinary(ctx, frame)
 	}
 	return errors.New("no audio channel")
 }
 
+// WSStats 返回 WebSocket 发送队列统计；非 WebSocket 连接时 ok 为 false
+func (c *Client) WSStats() (stats transport.WriteStats, ok bool) {
+	c.mu.RLock()
+	ws := c.ws
+	c.mu.RUnlock()
+	if ws == nil {
+		return transport.WriteStats{}, false
+	}
+	return ws.Stats(), true
+}
+
+// UDPJitterStats 返回 UDP 下行抖动缓冲统计；无 UDP 通道时 ok 为 false
+func (c *Client) UDPJitterStats() (stats transport.JitterStats, ok bool) {
+	c.mu.RLock()
+	udp := c.udp
+	c.mu.RUnlock()
+	if udp == nil {
+		return transport.JitterStats{}, false
+	}
+	return udp.JitterStats(), true
+}
+
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -408,8 +733,21 @@ func (c *Client) IsConnected() bool {
 	return false
 }
 
+// Config 返回客户端配置副本
+func (c *Client) Config() Config { return c.cfg }
+
 func (c *Client) GetSessionID() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.SessionID
 }
+
+// setSessionID 在锁内更新 session_id；替换已有会话（重连、MQTT 会话重建）时触发 OnSessionChanged，
+// 首次建连（原为空）不触发
+func (c *Client) setSessionID(sid string) {
+	c.mu.Lock()
+	old := c.SessionID
+	c.SessionID = sid
+	c.mu.Unlock()
+	if old != "" && sid != "" && sid != old && c.OnSessionChanged != nil { c.OnSessionChanged(old, sid) }
+}
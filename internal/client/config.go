@@ -1,6 +1,10 @@
 package client
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
 	"net"
 	"strings"
 	"time"
@@ -13,6 +17,15 @@ type AudioParams struct {
 	FrameDuration int    `json:"frame_duration"`
 }
 
+// EncoderParams 上行 Opus 编码参数（帧长/采样率取 Audio）
+type EncoderParams struct {
+	Bitrate        int // bps，0 为自动
+	Complexity     int // 0-10
+	DTX            bool
+	InBandFEC      bool
+	PacketLossPerc int // 预期丢包率（%），启用 FEC 时生效
+}
+
 type HelloMessage struct {
 	Type        string                 `json:"type"`
 	Version     int                    `json:"version"`
@@ -21,14 +34,6 @@ type HelloMessage struct {
 	AudioParams AudioParams            `json:"audio_params"`
 }
 
-type MqttHelloResponse struct {
-	Type        string       `json:"type"`
-	Transport   string       `json:"transport"`
-	SessionID   string       `json:"session_id"`
-	AudioParams AudioParams  `json:"audio_params"`
-	UDP         *UDPInfo     `json:"udp"`
-}
-
 type UDPInfo struct {
 	Server  string `json:"server"`
 	Port    int    `json:"port"`
@@ -36,6 +41,26 @@ type UDPInfo struct {
 	NonceHex string `json:"nonce"`
 }
 
+// Validate 校验服务端下发的 UDP 参数：地址、端口与 128 位 AES-CTR key/nonce
+func (u *UDPInfo) Validate() error {
+	if strings.TrimSpace(u.Server) == "" {
+		return errors.New("invalid udp params: server missing")
+	}
+	if u.Port <= 0 || u.Port > 65535 {
+		return fmt.Errorf("invalid udp params: port %d out of range", u.Port)
+	}
+	for name, v := range map[string]string{"key": u.KeyHex, "nonce": u.NonceHex} {
+		b, err := hex.DecodeString(v)
+		if err != nil {
+			return fmt.Errorf("invalid udp params: %s is not hex: %v", name, err)
+		}
+		if len(b) != 16 {
+			return fmt.Errorf("invalid udp params: %s must be 16 bytes, got %d", name, len(b))
+		}
+	}
+	return nil
+}
+
 type Config struct {
 	ClientID        string
 	DeviceID        string
@@ -44,6 +69,7 @@ type Config struct {
 	TokenMethod     string // "header", "query_access_token", "query_token"
 	ProtocolVersion int
 	Audio           AudioParams
+	Encoder         EncoderParams
 	HelloTimeout    time.Duration
 
 	WebsocketURL         string
@@ -55,6 +81,49 @@ type Config struct {
 	MQTTPublishTopic   string
 	MQTTSubscribeTopic string
 	MQTTKeepAliveSec   int
+	// UDP 下行抖动缓冲重排窗口（帧数），0 使用默认值 3
+	UDPJitterWindow    int
+	// UDP 下行抖动缓冲目标延迟（帧数，不超过窗口），0 使用默认值 2
+	UDPJitterPrebuffer int
+
+	Reconnect ReconnectPolicy
+}
+
+// ReconnectPolicy 断线自动重连策略：指数退避 + 随机抖动
+type ReconnectPolicy struct {
+	Enabled      bool
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64 // 0..1，延迟上下浮动比例
+	MaxAttempts  int     // <=0 表示不限次数
+}
+
+// Backoff 返回第 attempt 次（从 1 开始）重连前的等待时间
+func (p ReconnectPolicy) Backoff(attempt int) time.Duration {
+	d := float64(p.InitialDelay)
+	if d <= 0 {
+		d = float64(time.Second)
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	for i := 1; i < attempt; i++ {
+		d *= mult
+		if p.MaxDelay > 0 && d >= float64(p.MaxDelay) {
+			d = float64(p.MaxDelay)
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d *= 1 + j*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
 }
 
 // isVirtualName 粗略判断虚拟/非物理网卡名称（跨平台常见关键字）
@@ -110,10 +179,12 @@ func DefaultConfig() Config {
 		ProtocolVersion: 3,                                  // 文档: version = 3
 		TokenMethod:     "header",
 		Audio:           AudioParams{Format: "opus", SampleRate: 16000, Channels: 1, FrameDuration: 60}, // 文档: 16k/60ms
+		Encoder:         EncoderParams{Bitrate: 24000, Complexity: 5},
 		HelloTimeout:    10 * time.Second,
 		MQTTPublishTopic:   "device-server",
 		MQTTSubscribeTopic: "null", // 可由 OTA/设置覆盖；为 "null" 时不订阅
 		MQTTKeepAliveSec:   240,
+		Reconnect:          ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 30 * time.Second, Multiplier: 2, Jitter: 0.2, MaxAttempts: 10},
 		// 默认设备ID：采用系统首选物理网卡 MAC
 		DeviceID:        getDefaultMAC(),
 	}
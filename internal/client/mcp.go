@@ -0,0 +1,367 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MCP（JSON-RPC 2.0）设备端实现：服务端通过 type:"mcp" 消息发现并调用设备工具。
+// 协议细节参考 docs/websocket.md 4.1/4.2 节的 MCP 部分。
+
+const (
+	mcpProtocolVersion = "2024-11-05"
+	mcpDefaultPageSize = 16
+)
+
+// JSON-RPC 2.0 标准错误码
+const (
+	mcpErrParse          = -32700
+	mcpErrInvalidRequest = -32600
+	mcpErrMethodNotFound = -32601
+	mcpErrInvalidParams  = -32602
+	mcpErrInternal       = -32603
+)
+
+// McpProperty 工具参数的 JSON Schema 描述
+type McpProperty struct {
+	Type        string   `json:"type"` // "string" | "integer" | "number" | "boolean" | "object" | "array"
+	Description string   `json:"description,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Enum        []any    `json:"enum,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+}
+
+// McpSchema 工具的输入定义（JSON Schema object）
+type McpSchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]McpProperty `json:"properties"`
+	Required   []string               `json:"required,omitempty"`
+}
+
+// McpToolHandler 工具执行函数；返回值会被转换为 text content
+type McpToolHandler func(ctx context.Context, args map[string]any) (any, error)
+
+// McpTool 可被服务端调用的设备工具
+type McpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema McpSchema      `json:"inputSchema"`
+	Handler     McpToolHandler `json:"-"`
+}
+
+// McpServer 设备端 MCP 服务：维护工具表并处理 initialize / tools/list / tools/call
+type McpServer struct {
+	Name     string
+	Version  string
+	PageSize int // tools/list 每页工具数，<=0 使用默认值
+
+	mu    sync.RWMutex
+	tools map[string]*McpTool
+}
+
+func NewMcpServer(name, version string) *McpServer {
+	return &McpServer{Name: name, Version: version, PageSize: mcpDefaultPageSize, tools: make(map[string]*McpTool)}
+}
+
+// AddTool 注册工具；同名工具会被覆盖
+func (s *McpServer) AddTool(tool McpTool) error {
+	if tool.Name == "" {
+		return errors.New("mcp tool name required")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("mcp tool %s: handler required", tool.Name)
+	}
+	if tool.InputSchema.Type == "" {
+		tool.InputSchema.Type = "object"
+	}
+	if tool.InputSchema.Properties == nil {
+		tool.InputSchema.Properties = map[string]McpProperty{}
+	}
+	s.mu.Lock()
+	s.tools[tool.Name] = &tool
+	s.mu.Unlock()
+	return nil
+}
+
+// RemoveTool 注销工具
+func (s *McpServer) RemoveTool(name string) {
+	s.mu.Lock()
+	delete(s.tools, name)
+	s.mu.Unlock()
+}
+
+// Tools 返回按名称排序的工具列表
+func (s *McpServer) Tools() []McpTool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]McpTool, 0, len(s.tools))
+	for _, t := range s.tools {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpCallResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError"`
+}
+
+// handlePayload 处理一条 JSON-RPC 消息，返回需要回复的 payload；通知类消息返回 nil
+func (s *McpServer) handlePayload(ctx context.Context, payload []byte) *mcpResponse {
+	var req mcpRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return &mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: mcpErrParse, Message: err.Error()}}
+	}
+	// 无 id 为通知（如 notifications/initialized），无需回复
+	if len(req.ID) == 0 || string(req.ID) == "null" {
+		return nil
+	}
+	resp := &mcpResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" {
+		resp.Error = &mcpError{Code: mcpErrInvalidRequest, Message: "invalid jsonrpc version"}
+		return resp
+	}
+	var (
+		result any
+		rpcErr *mcpError
+	)
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.Name, "version": s.Version},
+		}
+	case "tools/list":
+		result, rpcErr = s.listTools(req.Params)
+	case "tools/call":
+		result, rpcErr = s.callTool(ctx, req.Params)
+	case "ping":
+		result = map[string]any{}
+	default:
+		rpcErr = &mcpError{Code: mcpErrMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+// listTools 分页返回工具；cursor 为下一页首个工具名（与固件实现一致）
+func (s *McpServer) listTools(params json.RawMessage) (any, *mcpError) {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &mcpError{Code: mcpErrInvalidParams, Message: err.Error()}
+		}
+	}
+	tools := s.Tools()
+	start := 0
+	if p.Cursor != "" {
+		start = sort.Search(len(tools), func(i int) bool { return tools[i].Name >= p.Cursor })
+		if start >= len(tools) || tools[start].Name != p.Cursor {
+			return nil, &mcpError{Code: mcpErrInvalidParams, Message: "invalid cursor: " + p.Cursor}
+		}
+	}
+	size := s.PageSize
+	if size <= 0 {
+		size = mcpDefaultPageSize
+	}
+	end := start + size
+	if end > len(tools) {
+		end = len(tools)
+	}
+	result := map[string]any{"tools": tools[start:end]}
+	if end < len(tools) {
+		result["nextCursor"] = tools[end].Name
+	}
+	return result, nil
+}
+
+func (s *McpServer) callTool(ctx context.Context, params json.RawMessage) (any, *mcpError) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &mcpError{Code: mcpErrInvalidParams, Message: err.Error()}
+	}
+	s.mu.RLock()
+	tool, ok := s.tools[p.Name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &mcpError{Code: mcpErrInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	args, err := tool.InputSchema.bind(p.Arguments)
+	if err != nil {
+		return nil, &mcpError{Code: mcpErrInvalidParams, Message: err.Error()}
+	}
+	v, err := tool.Handler(ctx, args)
+	if err != nil {
+		return mcpCallResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	text, err := mcpText(v)
+	if err != nil {
+		return nil, &mcpError{Code: mcpErrInternal, Message: err.Error()}
+	}
+	return mcpCallResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+}
+
+// bind 按 schema 校验参数：补默认值、检查必填项、类型与范围
+func (sc McpSchema) bind(in map[string]any) (map[string]any, error) {
+	args := make(map[string]any, len(sc.Properties))
+	for k, v := range in {
+		args[k] = v
+	}
+	for _, name := range sc.Required {
+		if _, ok := args[name]; !ok {
+			if _, hasProp := sc.Properties[name]; !hasProp || sc.Properties[name].Default == nil {
+				return nil, fmt.Errorf("missing required argument: %s", name)
+			}
+		}
+	}
+	for name, prop := range sc.Properties {
+		v, ok := args[name]
+		if !ok {
+			if prop.Default != nil {
+				args[name] = prop.Default
+			}
+			continue
+		}
+		if err := prop.check(name, v); err != nil {
+			return nil, err
+		}
+		// JSON 数字统一为 float64，integer 类型转换为 int 便于处理函数使用
+		if prop.Type == "integer" {
+			args[name] = int(v.(float64))
+		}
+	}
+	return args, nil
+}
+
+func (p McpProperty) check(name string, v any) error {
+	switch p.Type {
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("argument %s: expected string", name)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("argument %s: expected boolean", name)
+		}
+	case "integer", "number":
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("argument %s: expected %s", name, p.Type)
+		}
+		if p.Type == "integer" && f != float64(int64(f)) {
+			return fmt.Errorf("argument %s: expected integer", name)
+		}
+		if p.Minimum != nil && f < *p.Minimum {
+			return fmt.Errorf("argument %s: %v < minimum %v", name, f, *p.Minimum)
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			return fmt.Errorf("argument %s: %v > maximum %v", name, f, *p.Maximum)
+		}
+	case "object":
+		if _, ok := v.(map[string]any); !ok {
+			return fmt.Errorf("argument %s: expected object", name)
+		}
+	case "array":
+		if _, ok := v.([]any); !ok {
+			return fmt.Errorf("argument %s: expected array", name)
+		}
+	}
+	if len(p.Enum) > 0 {
+		for _, e := range p.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				return nil
+			}
+		}
+		return fmt.Errorf("argument %s: value %v not in enum", name, v)
+	}
+	return nil
+}
+
+// mcpText 将工具返回值转换为文本：字符串原样、布尔与数字格式化、其它 JSON 序列化
+func mcpText(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// handleMCP 处理服务端下发的 mcp 消息并通过当前通道回复
+func (c *Client) handleMCP(ctx context.Context, payload json.RawMessage) {
+	if c.MCP == nil || len(payload) == 0 {
+		return
+	}
+	resp := c.MCP.handlePayload(ctx, payload)
+	if resp == nil {
+		return
+	}
+	if err := c.SendMCP(ctx, resp); err != nil && c.OnError != nil {
+		c.OnError(ctx, fmt.Errorf("mcp reply: %w", err))
+	}
+}
+
+// SendMCP 发送 type:"mcp" 消息，payload 为 JSON-RPC 2.0 对象
+func (c *Client) SendMCP(ctx context.Context, payload any) error {
+	msg := map[string]any{"session_id": c.GetSessionID(), "type": "mcp", "payload": payload}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.sendText(ctx, b)
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func mcpCall(t *testing.T, s *McpServer, id int, method string, params any) map[string]any {
+	t.Helper()
+	req := map[string]any{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	b, _ := json.Marshal(req)
+	resp := s.handlePayload(context.Background(), b)
+	if resp == nil {
+		t.Fatalf("%s: no response", method)
+	}
+	// 经 JSON 往返，与服务端实际收到的内容一致
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["id"] != float64(id) {
+		t.Errorf("%s: id %v, want %d", method, out["id"], id)
+	}
+	return out
+}
+
+func mcpErrCode(t *testing.T, out map[string]any) int {
+	t.Helper()
+	e, ok := out["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected error, got %v", out)
+	}
+	return int(e["code"].(float64))
+}
+
+func TestMcpInitialize(t *testing.T) {
+	s := NewMcpServer("dev", "1.2.3")
+	out := mcpCall(t, s, 1, "initialize", map[string]any{"capabilities": map[string]any{}})
+	res := out["result"].(map[string]any)
+	if res["protocolVersion"] != mcpProtocolVersion {
+		t.Errorf("protocolVersion %v", res["protocolVersion"])
+	}
+	info := res["serverInfo"].(map[string]any)
+	if info["name"] != "dev" || info["version"] != "1.2.3" {
+		t.Errorf("serverInfo %v", info)
+	}
+	if _, ok := res["capabilities"].(map[string]any)["tools"]; !ok {
+		t.Errorf("capabilities missing tools: %v", res["capabilities"])
+	}
+
+	// 通知不回复
+	if resp := s.handlePayload(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Errorf("notification got response %+v", resp)
+	}
+	if code := mcpErrCode(t, mcpCall(t, s, 2, "resources/list", nil)); code != mcpErrMethodNotFound {
+		t.Errorf("unknown method code %d", code)
+	}
+}
+
+func TestMcpToolsListPaging(t *testing.T) {
+	s := NewMcpServer("dev", "1")
+	s.PageSize = 2
+	noop := func(context.Context, map[string]any) (any, error) { return nil, nil }
+	for _, name := range []string{"e", "c", "a", "d", "b"} {
+		if err := s.AddTool(McpTool{Name: "self." + name, Description: name, Handler: noop}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatal("paging did not terminate")
+		}
+		var params any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		res := mcpCall(t, s, page+1, "tools/list", params)["result"].(map[string]any)
+		tools := res["tools"].([]any)
+		if len(tools) > 2 {
+			t.Fatalf("page %d has %d tools", page, len(tools))
+		}
+		for _, tl := range tools {
+			m := tl.(map[string]any)
+			names = append(names, m["name"].(string))
+			if m["inputSchema"].(map[string]any)["type"] != "object" {
+				t.Errorf("tool %v schema type %v", m["name"], m["inputSchema"])
+			}
+		}
+		next, ok := res["nextCursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+	if got := fmt.Sprint(names); got != "[self.a self.b self.c self.d self.e]" {
+		t.Errorf("listed %s", got)
+	}
+
+	if code := mcpErrCode(t, mcpCall(t, s, 9, "tools/list", map[string]any{"cursor": "self.zz"})); code != mcpErrInvalidParams {
+		t.Errorf("bad cursor code %d", code)
+	}
+}
+
+func TestMcpToolsCall(t *testing.T) {
+	s := NewMcpServer("dev", "1")
+	lo, hi := 0.0, 100.0
+	var gotArgs map[string]any
+	err := s.AddTool(McpTool{
+		Name: "self.audio_speaker.set_volume",
+		InputSchema: McpSchema{
+			Properties: map[string]McpProperty{
+				"volume": {Type: "integer", Minimum: &lo, Maximum: &hi},
+				"fade":   {Type: "boolean", Default: false},
+			},
+			Required: []string{"volume"},
+		},
+		Handler: func(_ context.Context, args map[string]any) (any, error) {
+			gotArgs = args
+			return true, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.AddTool(McpTool{Name: "self.fail", Handler: func(context.Context, map[string]any) (any, error) {
+		return nil, errors.New("device busy")
+	}})
+
+	out := mcpCall(t, s, 1, "tools/call", map[string]any{"name": "self.audio_speaker.set_volume", "arguments": map[string]any{"volume": 40}})
+	res := out["result"].(map[string]any)
+	content := res["content"].([]any)[0].(map[string]any)
+	if content["type"] != "text" || content["text"] != "true" || res["isError"] != false {
+		t.Errorf("result %v", res)
+	}
+	if gotArgs["volume"] != 40 || gotArgs["fade"] != false {
+		t.Errorf("handler args %v", gotArgs)
+	}
+
+	// 工具执行失败以 isError 结果返回，而非 JSON-RPC 错误
+	res = mcpCall(t, s, 2, "tools/call", map[string]any{"name": "self.fail"})["result"].(map[string]any)
+	if res["isError"] != true || res["content"].([]any)[0].(map[string]any)["text"] != "device busy" {
+		t.Errorf("failing tool result %v", res)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args map[string]any
+	}{
+		{"self.audio_speaker.set_volume", map[string]any{}},
+		{"self.audio_speaker.set_volume", map[string]any{"volume": 101}},
+		{"self.audio_speaker.set_volume", map[string]any{"volume": 1.5}},
+		{"self.audio_speaker.set_volume", map[string]any{"volume": "loud"}},
+		{"self.unknown", nil},
+	} {
+		out := mcpCall(t, s, 3, "tools/call", map[string]any{"name": tc.name, "arguments": tc.args})
+		if code := mcpErrCode(t, out); code != mcpErrInvalidParams {
+			t.Errorf("%s %v: code %d, want %d", tc.name, tc.args, code, mcpErrInvalidParams)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"myproject/internal/logging"
+)
+
+// ReconnectState 重连状态
+type ReconnectState string
+
+const (
+	ReconnectStateWaiting     ReconnectState = "waiting"     // 等待退避时间
+	ReconnectStateAttempting  ReconnectState = "attempting"  // 正在重连（含 hello 握手）
+	ReconnectStateFailed      ReconnectState = "failed"      // 本次尝试失败
+	ReconnectStateReconnected ReconnectState = "reconnected" // 重连成功
+	ReconnectStateGaveUp      ReconnectState = "gave_up"     // 超过最大次数，放弃
+	ReconnectStateRenewed     ReconnectState = "renewed"     // MQTT broker 自动重连后已重新握手并重建 UDP 会话
+)
+
+// ReconnectEvent 重连状态事件
+type ReconnectEvent struct {
+	State    ReconnectState
+	Protocol string // ws/mqtt
+	Attempt  int
+	Delay    time.Duration
+	Err      error
+}
+
+func (c *Client) emitReconnect(ev ReconnectEvent) {
+	if c.OnReconnect != nil {
+		c.OnReconnect(ev)
+	}
+}
+
+// IsReconnecting 是否处于自动重连过程中
+func (c *Client) IsReconnecting() bool { return atomic.LoadInt32(&c.reconnecting) == 1 }
+
+// shouldReconnect 非用户主动关闭且启用了重连策略
+func (c *Client) shouldReconnect() bool {
+	return c.cfg.Reconnect.Enabled && atomic.LoadInt32(&c.closing) == 0
+}
+
+// startReconnect 启动后台重连；同一时间仅允许一个重连过程
+func (c *Client) startReconnect(protocol string) {
+	if !atomic.CompareAndSwapInt32(&c.reconnecting, 0, 1) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	c.reconnCancel = cancel
+	c.mu.Unlock()
+	go c.reconnectLoop(ctx, protocol)
+}
+
+func (c *Client) reconnectLoop(ctx context.Context, protocol string) {
+	log := logging.L().With("module", "reconnect")
+	p := c.cfg.Reconnect
+	defer func() {
+		c.mu.Lock()
+		c.reconnCancel = nil
+		c.mu.Unlock()
+		atomic.StoreInt32(&c.reconnecting, 0)
+	}()
+
+	for attempt := 1; p.MaxAttempts <= 0 || attempt <= p.MaxAttempts; attempt++ {
+		delay := p.Backoff(attempt)
+		log.Info("等待重连", "attempt", attempt, "delay", delay)
+		c.emitReconnect(ReconnectEvent{State: ReconnectStateWaiting, Protocol: protocol, Attempt: attempt, Delay: delay})
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		c.emitReconnect(ReconnectEvent{State: ReconnectStateAttempting, Protocol: protocol, Attempt: attempt})
+		err := c.Open(ctx, protocol)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn("重连失败", "attempt", attempt, "err", err)
+			c.emitReconnect(ReconnectEvent{State: ReconnectStateFailed, Protocol: protocol, Attempt: attempt, Err: err})
+			continue
+		}
+
+		log.Info("重连成功", "attempt", attempt)
+		c.emitReconnect(ReconnectEvent{State: ReconnectStateReconnected, Protocol: protocol, Attempt: attempt})
+		return
+	}
+
+	log.Warn("重连次数耗尽，放弃", "max_attempts", p.MaxAttempts)
+	c.emitReconnect(ReconnectEvent{State: ReconnectStateGaveUp, Protocol: protocol, Attempt: p.MaxAttempts})
+	if c.OnClosed != nil {
+		c.OnClosed()
+	}
+}
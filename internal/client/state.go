@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"myproject/internal/logging"
+)
+
+// DeviceState 会话状态，对应固件的 idle → connecting → listening → speaking（docs/websocket.md 第 6 节）
+type DeviceState string
+
+const (
+	StateIdle       DeviceState = "idle"
+	StateConnecting DeviceState = "connecting"
+	StateListening  DeviceState = "listening"
+	StateSpeaking   DeviceState = "speaking"
+)
+
+// ErrInvalidState 当前状态下不允许的操作
+var ErrInvalidState = errors.New("invalid state")
+
+// State 返回当前会话状态
+func (c *Client) State() DeviceState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.state == "" {
+		return StateIdle
+	}
+	return c.state
+}
+
+// ListenMode 返回最近一次 listen start 的模式（auto/manual/realtime）
+func (c *Client) ListenMode() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.listenMode
+}
+
+// setState 无条件切换状态并发布事件
+func (c *Client) setState(to DeviceState) {
+	c.stateMu.Lock()
+	from := c.state
+	if from == "" {
+		from = StateIdle
+	}
+	c.state = to
+	c.stateMu.Unlock()
+	c.publishState(from, to)
+}
+
+// transition 仅当当前状态属于 allowed 时切换，否则返回 ErrInvalidState
+func (c *Client) transition(to DeviceState, allowed ...DeviceState) error {
+	c.stateMu.Lock()
+	from := c.state
+	if from == "" {
+		from = StateIdle
+	}
+	ok := false
+	for _, s := range allowed {
+		if s == from {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		c.stateMu.Unlock()
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidState, from, to)
+	}
+	c.state = to
+	c.stateMu.Unlock()
+	c.publishState(from, to)
+	return nil
+}
+
+// checkState 校验当前状态是否允许某操作（不切换状态）
+func (c *Client) checkState(op string, allowed ...DeviceState) error {
+	cur := c.State()
+	for _, s := range allowed {
+		if s == cur {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s not allowed in %s", ErrInvalidState, op, cur)
+}
+
+func (c *Client) publishState(from, to DeviceState) {
+	if from == to {
+		return
+	}
+	logging.L().With("module", "state").Debug("状态切换", "from", from, "to", to)
+	if c.OnStateChanged != nil {
+		c.OnStateChanged(from, to)
+	}
+}
+
+// onStateMessage 根据服务端 tts/goodbye 消息推进状态机
+func (c *Client) onStateMessage(ctx context.Context, typ string, text []byte) {
+	switch typ {
+	case MsgTypeTTS:
+		var m TTSMessage
+		if json.Unmarshal(text, &m) != nil {
+			return
+		}
+		switch m.State {
+		case TTSStateStart:
+			_ = c.transition(StateSpeaking, StateIdle, StateListening)
+		case TTSStateStop:
+			if c.State() != StateSpeaking {
+				return
+			}
+			// 自动/实时模式：播放结束后重新进入监听
+			mode := c.ListenMode()
+			if mode == "auto" || mode == "realtime" {
+				// 本函数在下行回调中执行；MQTT 发布需等待 PUBACK，同步发送会阻塞 paho 的消息分发
+				go c.relisten(ctx, mode)
+				return
+			}
+			c.setState(StateIdle)
+		}
+	case MsgTypeGoodbye:
+		c.setState(StateIdle)
+	}
+}
+
+// relisten 自动模式下重新发送 listen start；期间若已被 goodbye/abort 等切走则不再改变状态
+func (c *Client) relisten(ctx context.Context, mode string) {
+	if err := c.sendListen(ctx, "start", mode); err != nil {
+		if c.OnError != nil {
+			c.OnError(ctx, fmt.Errorf("auto relisten: %w", err))
+		}
+		_ = c.transition(StateIdle, StateSpeaking)
+		return
+	}
+	_ = c.transition(StateListening, StateSpeaking)
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newStateClient(state DeviceState) *Client {
+	c := New(DefaultConfig())
+	c.SessionID = "s"
+	c.state = state
+	return c
+}
+
+func TestTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to DeviceState
+		allowed  []DeviceState
+		ok       bool
+	}{
+		{StateIdle, StateConnecting, []DeviceState{StateIdle}, true},
+		{StateIdle, StateSpeaking, []DeviceState{StateIdle, StateListening}, true},
+		{StateListening, StateSpeaking, []DeviceState{StateIdle, StateListening}, true},
+		{StateConnecting, StateSpeaking, []DeviceState{StateIdle, StateListening}, false},
+		{StateSpeaking, StateListening, []DeviceState{StateSpeaking}, true},
+		{StateIdle, StateListening, []DeviceState{StateSpeaking}, false},
+	} {
+		c := newStateClient(tc.from)
+		err := c.transition(tc.to, tc.allowed...)
+		want := tc.from
+		if tc.ok {
+			want = tc.to
+		}
+		if (err == nil) != tc.ok || (err != nil && !errors.Is(err, ErrInvalidState)) {
+			t.Errorf("%s -> %s: err %v, ok %v", tc.from, tc.to, err, tc.ok)
+		}
+		if got := c.State(); got != want {
+			t.Errorf("%s -> %s: state %s, want %s", tc.from, tc.to, got, want)
+		}
+	}
+}
+
+func TestStateMessages(t *testing.T) {
+	for _, tc := range []struct {
+		from DeviceState
+		typ  string
+		msg  string
+		want DeviceState
+	}{
+		{StateIdle, MsgTypeTTS, `{"type":"tts","state":"start"}`, StateSpeaking},
+		{StateListening, MsgTypeTTS, `{"type":"tts","state":"start"}`, StateSpeaking},
+		{StateConnecting, MsgTypeTTS, `{"type":"tts","state":"start"}`, StateConnecting},
+		{StateSpeaking, MsgTypeTTS, `{"type":"tts","state":"stop"}`, StateIdle},
+		{StateListening, MsgTypeTTS, `{"type":"tts","state":"stop"}`, StateListening},
+		{StateSpeaking, MsgTypeTTS, `{"type":"tts","state":"sentence_start"}`, StateSpeaking},
+		{StateSpeaking, MsgTypeGoodbye, `{"type":"goodbye"}`, StateIdle},
+		{StateListening, MsgTypeGoodbye, `{"type":"goodbye"}`, StateIdle},
+	} {
+		c := newStateClient(tc.from)
+		c.onStateMessage(context.Background(), tc.typ, []byte(tc.msg))
+		if got := c.State(); got != tc.want {
+			t.Errorf("%s + %s: state %s, want %s", tc.from, tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestOperationsCheckState(t *testing.T) {
+	ops := map[string]struct {
+		call    func(c *Client) error
+		allowed []DeviceState
+	}{
+		"listen start": {func(c *Client) error { return c.SendListenStart(context.Background(), "manual") }, []DeviceState{StateIdle, StateListening}},
+		"listen stop":  {func(c *Client) error { return c.SendListenStop(context.Background(), "manual") }, []DeviceState{StateListening}},
+		"detect":       {func(c *Client) error { return c.SendDetectText(context.Background(), "hi") }, []DeviceState{StateIdle, StateListening}},
+		"abort":        {func(c *Client) error { return c.SendAbort(context.Background(), "") }, []DeviceState{StateSpeaking, StateListening}},
+	}
+	for name, op := range ops {
+		for _, from := range []DeviceState{StateIdle, StateConnecting, StateListening, StateSpeaking} {
+			allowed := false
+			for _, s := range op.allowed {
+				allowed = allowed || s == from
+			}
+			// 未连接：允许的操作在发送时失败，但不应是 ErrInvalidState
+			c := newStateClient(from)
+			err := op.call(c)
+			if rejected := errors.Is(err, ErrInvalidState); rejected == allowed {
+				t.Errorf("%s in %s: err %v, allowed %v", name, from, err, allowed)
+			}
+			if got := c.State(); got != from {
+				t.Errorf("%s in %s: state changed to %s", name, from, got)
+			}
+		}
+	}
+}
+
+func TestAutoRelistenFailureGoesIdle(t *testing.T) {
+	c := newStateClient(StateSpeaking)
+	c.listenMode = "auto"
+	errs := make(chan error, 1)
+	c.OnError = func(_ context.Context, err error) { errs <- err }
+	c.onStateMessage(context.Background(), MsgTypeTTS, []byte(`{"type":"tts","state":"stop"}`))
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("nil error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no relisten error without a transport")
+	}
+	for deadline := time.Now().Add(time.Second); c.State() != StateIdle; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("state %s, want idle", c.State())
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"myproject/internal/transport"
+)
+
+// 一轮对话：发送文本或语音后，收集 stt/llm/tts 消息与下行音频，收到 tts stop 时结束
+
+var (
+	ErrTurnInProgress = errors.New("turn already in progress")
+	ErrTurnClosed     = errors.New("connection closed during turn")
+)
+
+// TurnEventKind 对话轮次事件类型
+type TurnEventKind string
+
+const (
+	TurnEventSTT           TurnEventKind = "stt"
+	TurnEventLLM           TurnEventKind = "llm"
+	TurnEventTTSStart      TurnEventKind = "tts_start"
+	TurnEventSentenceStart TurnEventKind = "sentence_start"
+	TurnEventSentenceEnd   TurnEventKind = "sentence_end"
+	TurnEventAudio         TurnEventKind = "audio"
+)
+
+// TurnEvent 对话轮次中的单个事件；Audio 为下行 Opus 帧
+type TurnEvent struct {
+	Kind    TurnEventKind
+	Text    string
+	Emotion string
+	Audio   []byte
+	At      time.Duration // 相对轮次开始的时间
+}
+
+// TurnResult 轮次结束后的汇总
+type TurnResult struct {
+	STT         string
+	LLMText     string
+	Emotion     string
+	Sentences   []string
+	AudioFrames int
+	AudioBytes  int
+	FirstEvent  time.Duration // 首个响应事件的延迟
+	Duration    time.Duration
+	Dropped     int // 事件通道已满时丢弃的事件数（汇总结果不受影响）
+}
+
+// Turn 一轮对话的结果流
+type Turn struct {
+	start  time.Time
+	events chan TurnEvent
+	done   chan struct{}
+
+	mu     sync.Mutex
+	result TurnResult
+	err    error
+	closed bool
+}
+
+const turnEventBuffer = 1024
+
+func newTurn() *Turn {
+	return &Turn{start: time.Now(), events: make(chan TurnEvent, turnEventBuffer), done: make(chan struct{})}
+}
+
+// Events 返回事件流，轮次结束后关闭
+func (t *Turn) Events() <-chan TurnEvent { return t.events }
+
+// Done 轮次结束时关闭
+func (t *Turn) Done() <-chan struct{} { return t.done }
+
+// Err 返回轮次结束原因；正常以 tts stop 结束时为 nil
+func (t *Turn) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Result 返回当前汇总（轮次进行中调用得到的是部分结果）
+func (t *Turn) Result() TurnResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.result
+	r.Sentences = append([]string(nil), t.result.Sentences...)
+	return r
+}
+
+// Wait 阻塞至轮次结束或 ctx 取消
+func (t *Turn) Wait(ctx context.Context) (TurnResult, error) {
+	select {
+	case <-t.done:
+		return t.Result(), t.Err()
+	case <-ctx.Done():
+		return t.Result(), ctx.Err()
+	}
+}
+
+func (t *Turn) emit(ev TurnEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	ev.At = time.Since(t.start)
+	if t.result.FirstEvent == 0 {
+		t.result.FirstEvent = ev.At
+	}
+	switch ev.Kind {
+	case TurnEventSTT:
+		t.result.STT = ev.Text
+	case TurnEventLLM:
+		t.result.LLMText += ev.Text
+		if ev.Emotion != "" {
+			t.result.Emotion = ev.Emotion
+		}
+	case TurnEventSentenceStart:
+		t.result.Sentences = append(t.result.Sentences, ev.Text)
+	case TurnEventAudio:
+		t.result.AudioFrames++
+		t.result.AudioBytes += len(ev.Audio)
+	}
+	select {
+	case t.events <- ev:
+	default:
+		t.result.Dropped++
+	}
+}
+
+func (t *Turn) finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.err = err
+	t.result.Duration = time.Since(t.start)
+	close(t.events)
+	close(t.done)
+}
+
+// beginTurn 登记新的进行中轮次；ctx 取消时以 ctx.Err() 结束
+func (c *Client) beginTurn(ctx context.Context) (*Turn, error) {
+	c.turnMu.Lock()
+	if c.turn != nil {
+		c.turnMu.Unlock()
+		return nil, ErrTurnInProgress
+	}
+	t := newTurn()
+	c.turn = t
+	c.turnMu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.endTurn(t, ctx.Err())
+		case <-t.done:
+		}
+	}()
+	return t, nil
+}
+
+// endTurn 结束指定轮次；t 为 nil 时结束当前轮次
+func (c *Client) endTurn(t *Turn, err error) {
+	c.turnMu.Lock()
+	if t == nil {
+		t = c.turn
+	}
+	if t != nil && c.turn == t {
+		c.turn = nil
+	}
+	c.turnMu.Unlock()
+	if t != nil {
+		t.finish(err)
+	}
+}
+
+func (c *Client) currentTurn() *Turn {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	return c.turn
+}
+
+// feedTurn 将服务端消息转换为轮次事件
+func (c *Client) feedTurn(typ string, msg map[string]any) {
+	t := c.currentTurn()
+	if t == nil {
+		return
+	}
+	text, _ := msg["text"].(string)
+	switch typ {
+	case MsgTypeSTT:
+		t.emit(TurnEvent{Kind: TurnEventSTT, Text: text})
+	case MsgTypeLLM:
+		emotion, _ := msg["emotion"].(string)
+		t.emit(TurnEvent{Kind: TurnEventLLM, Text: text, Emotion: emotion})
+	case MsgTypeTTS:
+		switch state, _ := msg["state"].(string); state {
+		case TTSStateStart:
+			t.emit(TurnEvent{Kind: TurnEventTTSStart})
+		case TTSStateSentenceStart:
+			t.emit(TurnEvent{Kind: TurnEventSentenceStart, Text: text})
+		case TTSStateSentenceEnd:
+			t.emit(TurnEvent{Kind: TurnEventSentenceEnd, Text: text})
+		case TTSStateStop:
+			c.endTurn(t, nil)
+		}
+	case MsgTypeGoodbye:
+		c.endTurn(t, ErrTurnClosed)
+	}
+}
+
+func (c *Client) feedTurnAudio(opus []byte) {
+	if t := c.currentTurn(); t != nil {
+		t.emit(TurnEvent{Kind: TurnEventAudio, Audio: append([]byte(nil), opus...)})
+	}
+}
+
+// Ask 发送文本（listen detect）并返回本轮对话的结果流，收到 tts stop 时结束
+func (c *Client) Ask(ctx context.Context, text string) (*Turn, error) {
+	t, err := c.beginTurn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SendDetectText(ctx, text); err != nil {
+		c.endTurn(t, err)
+		return nil, err
+	}
+	return t, nil
+}
+
+// SpeakOptions 上行语音的发送方式
+type SpeakOptions struct {
+	Mode string // listen 模式，默认 manual
+	Fast bool   // 不按帧时长限速，尽快发送（压力测试用）
+}
+
+// UpstreamSource 上行音频来源（如录音文件），按协商参数产出编码好的 Opus 帧；
+// 实现见 audio.FileSource，client 包本身不依赖编解码库
+type UpstreamSource interface {
+	OpusFrames(sampleRate, channels, frameDurationMs int) ([][]byte, error)
+}
+
+// PCMEncoder 将交错 PCM16 编码为 sampleRate/channels/frameDurationMs 的 Opus 帧；
+// 实现见 audio.PCM16Encoder，client 包本身不依赖编解码库
+type PCMEncoder interface {
+	EncodePCM16(pcm []int16, sampleRate, channels, frameDurationMs int) ([][]byte, error)
+}
+
+// Speak 以手动监听模式上行一段 PCM16 语音（采样率、声道为 Config.Audio 协商值）并返回本轮对话的结果流；
+// 由 c.Encoder 编码后按帧时长实时发送
+func (c *Client) Speak(ctx context.Context, pcm []int16) (*Turn, error) {
+	if c.Encoder == nil {
+		return nil, errors.New("speak: no Encoder set")
+	}
+	if len(pcm) == 0 {
+		return nil, errors.New("speak: empty pcm")
+	}
+	frames, err := c.Encoder.EncodePCM16(pcm, c.cfg.Audio.SampleRate, c.cfg.Audio.Channels, c.cfg.Audio.FrameDuration)
+	if err != nil {
+		return nil, err
+	}
+	return c.SpeakWithOptions(ctx, frames, SpeakOptions{})
+}
+
+// SpeakOpus 同 Speak，frames 为调用方按协商参数（Config.Audio）编码好的 Opus 帧，按帧时长实时发送
+func (c *Client) SpeakOpus(ctx context.Context, frames [][]byte) (*Turn, error) {
+	return c.SpeakWithOptions(ctx, frames, SpeakOptions{})
+}
+
+// SpeakSource 从 src 读取音频（按 Config.Audio 重采样/编码）后上行，见 SpeakWithOptions
+func (c *Client) SpeakSource(ctx context.Context, src UpstreamSource, opts SpeakOptions) (*Turn, error) {
+	frames, err := src.OpusFrames(c.cfg.Audio.SampleRate, c.cfg.Audio.Channels, c.cfg.Audio.FrameDuration)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("upstream source has no audio")
+	}
+	return c.SpeakWithOptions(ctx, frames, opts)
+}
+
+// SpeakWithOptions 在 listen start/stop 之间上行 frames 并返回本轮对话的结果流
+func (c *Client) SpeakWithOptions(ctx context.Context, frames [][]byte, opts SpeakOptions) (*Turn, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = "manual"
+	}
+	t, err := c.beginTurn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SendListenStart(ctx, mode); err != nil {
+		c.endTurn(t, err)
+		return nil, err
+	}
+	frameDur := time.Duration(c.cfg.Audio.FrameDuration) * time.Millisecond
+	if frameDur <= 0 {
+		frameDur = 60 * time.Millisecond
+	}
+	go func() {
+		var tick <-chan time.Time
+		if !opts.Fast {
+			ticker := time.NewTicker(frameDur)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for _, f := range frames {
+			// 发送队列满时单帧被丢弃不影响本轮
+			if err := c.SendOpusUpstream(ctx, f); err != nil && !errors.Is(err, transport.ErrAudioDropped) {
+				c.endTurn(t, err)
+				return
+			}
+			if tick == nil {
+				continue
+			}
+			select {
+			case <-tick:
+			case <-t.done:
+				return
+			}
+		}
+		// 服务端可能已开始播报（speaking），此时无需再停止监听
+		if err := c.SendListenStop(ctx, mode); err != nil && !errors.Is(err, ErrInvalidState) {
+			c.endTurn(t, err)
+		}
+	}()
+	return t, nil
+}
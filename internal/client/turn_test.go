@@ -0,0 +1,222 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mockserver/mocktest"
+)
+
+func dialMock(t *testing.T, cfg mockserver.Config) *client.Client {
+	t.Helper()
+	return mocktest.Dial(t, mocktest.WSConfig(mocktest.StartServer(t, cfg).URL()))
+}
+
+func TestAskEventsAndResult(t *testing.T) {
+	c := dialMock(t, mockserver.Config{Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Emotion: "happy", LLMText: "😀", Sentences: []string{"一", "二"}, FramesPerSentence: 3}
+	}})
+	turn, err := c.Ask(context.Background(), "你好")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []client.TurnEventKind
+	var last time.Duration
+	for ev := range turn.Events() {
+		if ev.At < last {
+			t.Errorf("event %s at %v before previous %v", ev.Kind, ev.At, last)
+		}
+		last = ev.At
+		if n := len(kinds); n == 0 || kinds[n-1] != ev.Kind {
+			kinds = append(kinds, ev.Kind)
+		}
+	}
+	want := []client.TurnEventKind{
+		client.TurnEventSTT, client.TurnEventLLM, client.TurnEventTTSStart,
+		client.TurnEventSentenceStart, client.TurnEventAudio, client.TurnEventSentenceEnd,
+		client.TurnEventSentenceStart, client.TurnEventAudio, client.TurnEventSentenceEnd,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("events %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events %v, want %v", kinds, want)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := turn.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.STT != "你好" || res.LLMText != "😀" || res.Emotion != "happy" || len(res.Sentences) != 2 || res.AudioFrames != 6 {
+		t.Errorf("result %+v", res)
+	}
+	if res.FirstEvent <= 0 || res.Duration < res.FirstEvent || res.Dropped != 0 {
+		t.Errorf("timing %+v", res)
+	}
+	if c.State() != client.StateIdle {
+		t.Errorf("state %s after turn, want idle", c.State())
+	}
+}
+
+func TestAskWhileTurnInProgress(t *testing.T) {
+	c := dialMock(t, mockserver.Config{Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Sentences: []string{"x"}, Delay: 200 * time.Millisecond}
+	}})
+	turn, err := c.Ask(context.Background(), "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Ask(context.Background(), "two"); !errors.Is(err, client.ErrTurnInProgress) {
+		t.Fatalf("second ask: %v, want ErrTurnInProgress", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if res, err := turn.Wait(ctx); err != nil || res.STT != "one" {
+		t.Fatalf("first turn: %+v %v", res, err)
+	}
+	// 上一轮结束后可以开始新的一轮
+	turn, err = c.Ask(context.Background(), "three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := turn.Wait(ctx); err != nil || res.STT != "three" {
+		t.Fatalf("next turn: %+v %v", res, err)
+	}
+}
+
+// frameEncoder 测试用编码器：每帧输出一个字节的帧号，记录协商参数
+type frameEncoder struct {
+	rate, channels, frameMs int
+}
+
+func (e *frameEncoder) EncodePCM16(pcm []int16, sampleRate, channels, frameDurationMs int) ([][]byte, error) {
+	e.rate, e.channels, e.frameMs = sampleRate, channels, frameDurationMs
+	size := sampleRate * frameDurationMs / 1000 * channels
+	var frames [][]byte
+	for i := 0; i < len(pcm); i += size {
+		frames = append(frames, []byte{byte(len(frames))})
+	}
+	return frames, nil
+}
+
+func TestSpeakEncodesPCM(t *testing.T) {
+	c := dialMock(t, mockserver.Config{})
+	if _, err := c.Speak(context.Background(), make([]int16, 100)); err == nil {
+		t.Fatal("speak without encoder succeeded")
+	}
+
+	enc := &frameEncoder{}
+	c.Encoder = enc
+	a := c.Config().Audio
+	// 5 帧（最后一帧不足一帧）
+	pcm := make([]int16, a.SampleRate*a.FrameDuration/1000*a.Channels*9/2)
+	turn, err := c.Speak(context.Background(), pcm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := mocktest.WaitTurn(t, turn); res.STT != "mock stt (5 frames)" {
+		t.Errorf("result %+v", res)
+	}
+	if enc.rate != a.SampleRate || enc.channels != a.Channels || enc.frameMs != a.FrameDuration {
+		t.Errorf("encoder got %d/%d/%dms, want %d/%d/%dms", enc.rate, enc.channels, enc.frameMs, a.SampleRate, a.Channels, a.FrameDuration)
+	}
+}
+
+func TestWaitAndAskContext(t *testing.T) {
+	c := dialMock(t, mockserver.Config{Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Sentences: []string{"x"}, Delay: time.Second}
+	}})
+
+	// Wait 的 ctx 到期只影响等待，不结束轮次
+	turn, err := c.Ask(context.Background(), "slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := turn.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait: %v, want deadline exceeded", err)
+	}
+	select {
+	case <-turn.Done():
+		t.Fatal("turn ended with wait ctx")
+	default:
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel2()
+	if res, err := turn.Wait(ctx2); err != nil || res.STT != "slow" {
+		t.Fatalf("turn after wait timeout: %+v %v", res, err)
+	}
+
+	// Ask 的 ctx 取消时轮次以 ctx.Err() 结束
+	askCtx, askCancel := context.WithCancel(context.Background())
+	turn, err = c.Ask(askCtx, "cancel me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	askCancel()
+	select {
+	case <-turn.Done():
+	case <-time.After(time.Second):
+		t.Fatal("turn not ended after ask ctx cancel")
+	}
+	if !errors.Is(turn.Err(), context.Canceled) {
+		t.Errorf("turn err %v, want context.Canceled", turn.Err())
+	}
+}
+
+func TestTurnEndsOnClose(t *testing.T) {
+	c := dialMock(t, mockserver.Config{Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Sentences: []string{"x"}, Delay: time.Second}
+	}})
+	turn, err := c.Ask(context.Background(), "bye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := turn.Wait(ctx); !errors.Is(err, client.ErrTurnClosed) {
+		t.Fatalf("wait: %v, want ErrTurnClosed", err)
+	}
+}
+
+func TestCloseCallbacksMayCallClient(t *testing.T) {
+	c := dialMock(t, mockserver.Config{Realtime: true, Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Sentences: []string{"x"}, FramesPerSentence: 100}
+	}})
+	// 回调中调用加锁的方法：Close 持锁回调时会死锁
+	closed := make(chan string, 1)
+	c.OnStateChanged = func(from, to client.DeviceState) {
+		if to == client.StateIdle {
+			closed <- c.GetSessionID()
+		}
+	}
+	if _, err := c.Ask(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); c.State() != client.StateSpeaking; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("state %s, want speaking", c.State())
+		}
+	}
+	done := make(chan struct{})
+	go func() { c.Close(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked while running callbacks")
+	}
+	if sid := <-closed; sid != "" {
+		t.Errorf("session id %q after close", sid)
+	}
+}
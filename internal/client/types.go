@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// 服务端→设备端消息类型（docs/websocket.md 4.2 节）
+const (
+	MsgTypeHello   = "hello"
+	MsgTypeSTT     = "stt"
+	MsgTypeLLM     = "llm"
+	MsgTypeTTS     = "tts"
+	MsgTypeMCP     = "mcp"
+	MsgTypeSystem  = "system"
+	MsgTypeCustom  = "custom"
+	MsgTypeGoodbye = "goodbye"
+)
+
+// TTS 状态
+const (
+	TTSStateStart         = "start"
+	TTSStateStop          = "stop"
+	TTSStateSentenceStart = "sentence_start"
+	TTSStateSentenceEnd   = "sentence_end"
+)
+
+// abort 的 reason（docs/websocket.md）
+const (
+	AbortReasonWakeWord      = "wake_word_detected"
+	AbortReasonUserInterrupt = "user_interrupt"
+)
+
+// HelloResponse 服务端 hello 应答；UDP 仅在 MQTT+UDP 通道下发
+type HelloResponse struct {
+	Type        string       `json:"type"`
+	Version     int          `json:"version,omitempty"`
+	Transport   string       `json:"transport"`
+	SessionID   string       `json:"session_id,omitempty"`
+	AudioParams *AudioParams `json:"audio_params,omitempty"`
+	UDP         *UDPInfo     `json:"udp,omitempty"`
+}
+
+// STTMessage 语音识别结果
+type STTMessage struct {
+	SessionID string `json:"session_id"`
+	Text      string `json:"text"`
+}
+
+// LLMMessage 表情/情绪指示，text 通常为 emoji
+type LLMMessage struct {
+	SessionID string `json:"session_id"`
+	Text      string `json:"text"`
+	Emotion   string `json:"emotion"`
+}
+
+// TTSMessage 语音合成控制：start/stop/sentence_start/sentence_end
+type TTSMessage struct {
+	SessionID string `json:"session_id"`
+	State     string `json:"state"`
+	Text      string `json:"text,omitempty"`
+}
+
+// MCPMessage MCP 消息，payload 为 JSON-RPC 2.0 对象
+type MCPMessage struct {
+	SessionID string          `json:"session_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// SystemMessage 系统控制命令，如 reboot
+type SystemMessage struct {
+	SessionID string `json:"session_id"`
+	Command   string `json:"command"`
+}
+
+// CustomMessage 自定义消息
+type CustomMessage struct {
+	SessionID string          `json:"session_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// GoodbyeMessage 服务端结束会话
+type GoodbyeMessage struct {
+	SessionID string `json:"session_id"`
+}
+
+// dispatchTyped 按 type 解析为具体结构并调用对应回调；未知类型交给 OnUnknown
+func (c *Client) dispatchTyped(ctx context.Context, typ string, text []byte, msg map[string]any) {
+	switch typ {
+	case MsgTypeHello:
+		var m HelloResponse
+		if json.Unmarshal(text, &m) == nil && c.OnHello != nil {
+			c.OnHello(ctx, m)
+		}
+	case MsgTypeSTT:
+		var m STTMessage
+		if json.Unmarshal(text, &m) == nil && c.OnSTT != nil {
+			c.OnSTT(ctx, m)
+		}
+	case MsgTypeLLM:
+		var m LLMMessage
+		if json.Unmarshal(text, &m) == nil && c.OnLLM != nil {
+			c.OnLLM(ctx, m)
+		}
+	case MsgTypeTTS:
+		var m TTSMessage
+		if json.Unmarshal(text, &m) == nil && c.OnTTS != nil {
+			c.OnTTS(ctx, m)
+		}
+	case MsgTypeMCP:
+		var m MCPMessage
+		if json.Unmarshal(text, &m) == nil && c.OnMCP != nil {
+			c.OnMCP(ctx, m)
+		}
+	case MsgTypeSystem:
+		var m SystemMessage
+		if json.Unmarshal(text, &m) == nil && c.OnSystem != nil {
+			c.OnSystem(ctx, m)
+		}
+	case MsgTypeCustom:
+		var m CustomMessage
+		if json.Unmarshal(text, &m) == nil && c.OnCustom != nil {
+			c.OnCustom(ctx, m)
+		}
+	case MsgTypeGoodbye:
+		var m GoodbyeMessage
+		if json.Unmarshal(text, &m) == nil && c.OnGoodbye != nil {
+			c.OnGoodbye(ctx, m)
+		}
+	default:
+		if c.OnUnknown != nil {
+			c.OnUnknown(ctx, msg)
+		}
+	}
+}
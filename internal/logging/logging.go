@@ -11,10 +11,11 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 var (
-	logger   *slog.Logger
+	logger   atomic.Pointer[slog.Logger] // 各 goroutine 可并发调用 L
 	levelVar slog.LevelVar
 )
 
@@ -149,15 +150,16 @@ func Init(level string) {
 
 	debugH := newLineHandler(os.Stdout, &levelVar, true)
 	infoH := newLineHandler(os.Stdout, &levelVar, false)
-	logger = slog.New(&splitHandler{debug: debugH, info: infoH})
+	logger.Store(slog.New(&splitHandler{debug: debugH, info: infoH}))
 }
 
 // L returns the global logger, initializing it if needed.
 func L() *slog.Logger {
-	if logger == nil {
-		Init("")
+	if l := logger.Load(); l != nil {
+		return l
 	}
-	return logger
+	Init("")
+	return logger.Load()
 }
 
 // SetLevel updates the runtime logging level.
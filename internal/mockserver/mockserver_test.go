@@ -0,0 +1,250 @@
+package mockserver_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mockserver/mocktest"
+	"myproject/internal/transport"
+)
+
+func TestAskAllBinaryVersions(t *testing.T) {
+	for _, v := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			s := mocktest.StartServer(t, mockserver.Config{SessionID: "sess-1"})
+			cfg := mocktest.WSConfig(s.URL())
+			cfg.ProtocolVersion = v
+			c := mocktest.Dial(t, cfg)
+			if got := c.GetSessionID(); got != "sess-1" {
+				t.Errorf("session id %q, want sess-1", got)
+			}
+			if got := c.BinaryProtocolVersion(); got != v {
+				t.Errorf("binary version %d, want %d", got, v)
+			}
+			turn, err := c.Ask(context.Background(), "今天天气")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := mocktest.WaitTurn(t, turn)
+			if res.STT != "今天天气" || res.Emotion != "happy" || len(res.Sentences) != 1 {
+				t.Errorf("unexpected result: %+v", res)
+			}
+			if res.AudioFrames != 5 {
+				t.Errorf("audio frames %d, want 5", res.AudioFrames)
+			}
+
+			conns := s.Conns()
+			if len(conns) != 1 {
+				t.Fatalf("%d conns, want 1", len(conns))
+			}
+			h := conns[0].Headers
+			if h.Get("Device-Id") != mocktest.DeviceID || h.Get("Client-Id") != mocktest.ClientID || h.Get("Protocol-Version") != fmt.Sprint(v) {
+				t.Errorf("handshake headers: %v", h)
+			}
+		})
+	}
+}
+
+func TestTokenRequired(t *testing.T) {
+	s := mocktest.StartServer(t, mockserver.Config{Token: "secret"})
+	cfg := mocktest.WSConfig(s.URL())
+	cfg.HelloTimeout = time.Second
+	if err := client.New(cfg).OpenWebsocket(context.Background()); err == nil {
+		t.Fatal("open without token succeeded")
+	}
+	for _, method := range []string{"header", "query_access_token", "query_token"} {
+		cfg.EnableToken, cfg.AuthToken, cfg.TokenMethod = true, "secret", method
+		c := client.New(cfg)
+		if err := c.OpenWebsocket(context.Background()); err != nil {
+			t.Errorf("%s: %v", method, err)
+		}
+		c.Close()
+	}
+}
+
+func TestSpeakEchoesUpstream(t *testing.T) {
+	s := mocktest.StartServer(t, mockserver.Config{Echo: true, Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: fmt.Sprintf("%d frames", len(in.Frames)), Sentences: []string{"echo"}}
+	}})
+	frames := [][]byte{{0x18, 1}, {0x18, 2}, {0x18, 3}}
+	var mu sync.Mutex
+	var got [][]byte
+	c := mocktest.Dial(t, mocktest.WSConfig(s.URL()), func(c *client.Client) {
+		c.OnBinary = func(ctx context.Context, data []byte) {
+			mu.Lock()
+			got = append(got, append([]byte(nil), data...))
+			mu.Unlock()
+		}
+	})
+	turn, err := c.SpeakWithOptions(context.Background(), frames, client.SpeakOptions{Fast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := mocktest.WaitTurn(t, turn)
+	if res.STT != "3 frames" {
+		t.Errorf("stt %q, want %q", res.STT, "3 frames")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != len(frames) {
+		t.Fatalf("echoed %d frames, want %d", len(got), len(frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(got[i], frames[i]) {
+			t.Errorf("frame %d: got %x, want %x", i, got[i], frames[i])
+		}
+	}
+}
+
+func TestAbortStopsTTS(t *testing.T) {
+	s := mocktest.StartServer(t, mockserver.Config{Realtime: true, Script: func(in mockserver.Input) mockserver.Reply {
+		return mockserver.Reply{STT: in.Text, Sentences: []string{"long"}, FramesPerSentence: 100}
+	}})
+	cfg := mocktest.WSConfig(s.URL())
+	cfg.ProtocolVersion = 1
+	c := mocktest.Dial(t, cfg)
+	turn, err := c.Ask(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := c.SendAbort(context.Background(), "user"); err != nil {
+		t.Fatal(err)
+	}
+	res := mocktest.WaitTurn(t, turn)
+	if res.AudioFrames >= 100 {
+		t.Errorf("abort did not stop tts: %d frames", res.AudioFrames)
+	}
+}
+
+func TestUDPEndpointRoundTrip(t *testing.T) {
+	ep, err := mockserver.NewUDPEndpoint("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+
+	down := make(chan []byte, 8)
+	u := transport.NewUDPAudio("127.0.0.1", ep.Addr().Port, ep.KeyHex, ep.NonceHex, transport.UDPAudioHandlers{
+		OnAudioFrame: func(ctx context.Context, opus []byte) { down <- opus },
+	})
+	u.FrameDuration = 20 * time.Millisecond
+	if err := u.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+
+	up := []byte("upstream-opus")
+	if err := u.SendOpusFrame(up); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(ep.Received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if r := ep.Received(); len(r) != 1 || !bytes.Equal(r[0], up) {
+		t.Fatalf("endpoint received %q", r)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := ep.Send([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case f := <-down:
+			if !bytes.Equal(f, []byte{byte(i)}) {
+				t.Errorf("frame %d: got %x", i, f)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d not delivered", i)
+		}
+	}
+}
+
+func TestMQTTUDPEndToEnd(t *testing.T) {
+	b, r := mocktest.StartMQTT(t, mockserver.MQTTConfig{Config: mockserver.Config{SessionID: "mqtt-sess", Echo: true}})
+	// 上行帧经 UDP 到达服务端，回声经 UDP 下发；下行帧经抖动缓冲按帧时长输出，
+	// 可能晚于经 MQTT 到达的 tts stop，因此单独计数（合成的静音帧只有 1 字节，不计入）
+	var echoed int32
+	c := mocktest.Dial(t, mocktest.MQTTConfig(b, "dev-1"), func(c *client.Client) {
+		c.OnBinary = func(ctx context.Context, data []byte) {
+			if len(data) == 2 {
+				atomic.AddInt32(&echoed, 1)
+			}
+		}
+	})
+	if got := c.GetSessionID(); got != "mqtt-sess" {
+		t.Errorf("session id %q, want mqtt-sess", got)
+	}
+
+	turn, err := c.Ask(context.Background(), "开灯")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := mocktest.WaitTurn(t, turn); res.STT != "开灯" || len(res.Sentences) != 1 {
+		t.Errorf("ask result: %+v", res)
+	}
+
+	frames := [][]byte{{0x18, 1}, {0x18, 2}, {0x18, 3}}
+	turn, err = c.SpeakOpus(context.Background(), frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := mocktest.WaitTurn(t, turn); res.STT != "mock stt (3 frames)" {
+		t.Errorf("speak result: %+v", res)
+	}
+	for deadline := time.Now().Add(2 * time.Second); atomic.LoadInt32(&echoed) < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&echoed); n != 3 {
+		t.Errorf("echoed %d frames over udp, want 3", n)
+	}
+	s := r.Sessions()
+	if len(s) != 1 || s[0].ClientID != "dev-1" || s[0].UpstreamFrames != 3 {
+		t.Errorf("sessions: %+v", s)
+	}
+}
+
+func TestMQTTBrokerDropRenewsSession(t *testing.T) {
+	b, r := mocktest.StartMQTT(t, mockserver.MQTTConfig{})
+	renewed := make(chan string, 1)
+	var closed int32
+	c := mocktest.Dial(t, mocktest.MQTTConfig(b, "dev-2"), func(c *client.Client) {
+		c.OnSessionChanged = func(oldID, newID string) { renewed <- newID }
+		c.OnClosed = func() { atomic.AddInt32(&closed, 1) }
+	})
+	first := c.GetSessionID()
+
+	if !b.Disconnect("dev-2") {
+		t.Fatal("client not connected to broker")
+	}
+	select {
+	case sid := <-renewed:
+		if sid == first {
+			t.Errorf("session id unchanged after renew: %s", sid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not renewed after broker drop")
+	}
+	if n := len(r.Sessions()); n != 2 {
+		t.Errorf("%d sessions, want 2", n)
+	}
+	turn, err := c.Ask(context.Background(), "还在吗")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mocktest.WaitTurn(t, turn)
+	// broker 断开上报一次；重新握手后被替换的旧 UDP 通道关闭不再上报
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Errorf("OnClosed called %d times, want 1", n)
+	}
+}
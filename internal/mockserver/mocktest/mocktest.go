@@ -0,0 +1,111 @@
+// Package mocktest 测试夹具：在进程内启动模拟服务端与 MQTT broker，并以 client.Client 连接，
+// 资源均随测试结束（t.Cleanup）释放。供 mockserver、mqttbroker、wirelog、chaosproxy、client 等包的测试共用
+package mocktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mqttbroker"
+)
+
+// 测试客户端的固定身份与 StartMQTT 启动的 broker 凭据
+const (
+	DeviceID       = "aa:bb:cc:dd:ee:ff"
+	ClientID       = "mocktest-client"
+	BrokerUser     = "dev"
+	BrokerPassword = "pw"
+)
+
+// TurnTimeout WaitTurn 等待一轮结束的上限
+const TurnTimeout = 5 * time.Second
+
+// StartServer 在本机随机端口启动模拟服务端
+func StartServer(t testing.TB, cfg mockserver.Config) *mockserver.Server {
+	t.Helper()
+	s := mockserver.New(cfg)
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// StartBroker 在本机随机端口启动 MQTT broker
+func StartBroker(t testing.TB, cfg mqttbroker.Config) *mqttbroker.Broker {
+	t.Helper()
+	b := mqttbroker.New(cfg)
+	if err := b.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// StartMQTT 启动要求 BrokerUser/BrokerPassword 凭据的 broker，并挂上 MQTT+UDP 模拟服务端
+func StartMQTT(t testing.TB, cfg mockserver.MQTTConfig) (*mqttbroker.Broker, *mockserver.MQTTResponder) {
+	t.Helper()
+	b := StartBroker(t, mqttbroker.Config{Username: BrokerUser, Password: BrokerPassword})
+	r := mockserver.NewMQTTResponder(b, cfg)
+	// 先于 broker 关闭（Cleanup 后进先出）
+	t.Cleanup(r.Close)
+	return b, r
+}
+
+// WSConfig 连接 url 的 WebSocket 客户端配置
+func WSConfig(url string) client.Config {
+	cfg := client.DefaultConfig()
+	cfg.WebsocketURL = url
+	cfg.DeviceID = DeviceID
+	cfg.ClientID = ClientID
+	cfg.HelloTimeout = 3 * time.Second
+	return cfg
+}
+
+// MQTTConfig 经 broker 连接 MQTT+UDP 模拟服务端的客户端配置；clientID 同时决定下行主题
+func MQTTConfig(b *mqttbroker.Broker, clientID string) client.Config {
+	cfg := client.DefaultConfig()
+	cfg.MQTTBroker = b.URL()
+	cfg.MQTTUsername, cfg.MQTTPassword = BrokerUser, BrokerPassword
+	cfg.DeviceID = DeviceID
+	cfg.ClientID = clientID
+	cfg.MQTTSubscribeTopic = mockserver.DefaultReplyTopic(clientID)
+	cfg.HelloTimeout = 3 * time.Second
+	return cfg
+}
+
+// Dial 按 cfg 建立连接并完成 hello（设置了 MQTTBroker 时走 MQTT，否则 WebSocket）；
+// setup 在连接前执行，用于设置回调，避免与收发 goroutine 竞争
+func Dial(t testing.TB, cfg client.Config, setup ...func(*client.Client)) *client.Client {
+	t.Helper()
+	protocol := "ws"
+	if cfg.MQTTBroker != "" {
+		protocol = "mqtt"
+	}
+	c := client.New(cfg)
+	for _, f := range setup {
+		f(c)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HelloTimeout+time.Second)
+	defer cancel()
+	if err := c.Open(ctx, protocol); err != nil {
+		t.Fatalf("open %s: %v", protocol, err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// WaitTurn 等待一轮结束，超时或出错时终止测试
+func WaitTurn(t testing.TB, turn *client.Turn) client.TurnResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), TurnTimeout)
+	defer cancel()
+	res, err := turn.Wait(ctx)
+	if err != nil {
+		t.Fatalf("turn: %v", err)
+	}
+	return res
+}
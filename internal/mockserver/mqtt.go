@@ -0,0 +1,179 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	"myproject/internal/logging"
+	"myproject/internal/mqttbroker"
+)
+
+// MQTTConfig MQTT+UDP 应答器参数
+type MQTTConfig struct {
+	Config // 会话与脚本参数，同 WebSocket（Token 不使用，鉴权由 broker 负责）
+
+	RequestTopic string                       // 设备发布的主题，默认 device-server
+	ReplyTopic   func(clientID string) string // 下发主题，默认 devices/p2p/<clientID>
+	UDPHost      string                       // UDP 监听地址，默认 127.0.0.1
+}
+
+// DefaultReplyTopic 默认的下发主题，设备需订阅该主题
+func DefaultReplyTopic(clientID string) string { return "devices/p2p/" + clientID }
+
+// MQTTResponder 挂在嵌入式 broker 上的服务端：应答设备 hello 并为每个会话分配
+// UDP 端点与 key/nonce，之后控制消息走 MQTT、音频走加密 UDP
+type MQTTResponder struct {
+	cfg    MQTTConfig
+	broker *mqttbroker.Broker
+	unsub  func()
+
+	mu       sync.Mutex
+	sessions map[string]*mqttSession // clientID → 当前会话
+	history  []*mqttSession
+}
+
+type mqttSession struct {
+	*session
+	r        *MQTTResponder
+	clientID string
+	topic    string
+	udp      *UDPEndpoint
+}
+
+// NewMQTTResponder 订阅 broker 上的设备请求主题并开始应答
+func NewMQTTResponder(b *mqttbroker.Broker, cfg MQTTConfig) *MQTTResponder {
+	if cfg.AudioParams == (AudioParams{}) {
+		cfg.AudioParams = AudioParams{Format: "opus", SampleRate: 24000, Channels: 1, FrameDuration: 60}
+	}
+	if cfg.Script == nil {
+		cfg.Script = DefaultReply
+	}
+	if cfg.RequestTopic == "" {
+		cfg.RequestTopic = "device-server"
+	}
+	if cfg.ReplyTopic == nil {
+		cfg.ReplyTopic = DefaultReplyTopic
+	}
+	if cfg.UDPHost == "" {
+		cfg.UDPHost = "127.0.0.1"
+	}
+	r := &MQTTResponder{cfg: cfg, broker: b, sessions: make(map[string]*mqttSession)}
+	r.unsub = b.Subscribe(cfg.RequestTopic, r.onMessage)
+	return r
+}
+
+// Close 取消订阅并关闭全部会话的 UDP 端点
+func (r *MQTTResponder) Close() {
+	r.unsub()
+	r.mu.Lock()
+	sessions := r.sessions
+	r.sessions = make(map[string]*mqttSession)
+	r.mu.Unlock()
+	for _, s := range sessions {
+		s.close()
+	}
+}
+
+// Sessions 返回全部会话记录的快照（含已结束的）
+func (r *MQTTResponder) Sessions() []Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Conn, 0, len(r.history))
+	for _, s := range r.history {
+		info := Conn{ClientID: s.clientID, SessionID: s.id, Version: 1}
+		info.Messages, info.UpstreamFrames = s.snapshot()
+		info.Closed = r.sessions[s.clientID] != s
+		out = append(out, info)
+	}
+	return out
+}
+
+func (r *MQTTResponder) onMessage(m mqttbroker.Message) {
+	var msg map[string]any
+	if json.Unmarshal(m.Payload, &msg) != nil || m.ClientID == "" {
+		return
+	}
+	log := logging.L().With("module", "mockserver")
+	switch msg["type"] {
+	case "hello":
+		// 每次 hello（含 broker 重连后的重新握手）都开启新会话与新的 UDP 端点
+		s, err := r.newSession(m.ClientID)
+		if err != nil {
+			log.Warn("创建 UDP 端点失败", "client_id", m.ClientID, "err", err)
+			return
+		}
+		s.handleJSON(msg)
+		_ = s.publish(s.udp.MQTTHello(s.id, r.cfg.AudioParams))
+	case "goodbye":
+		r.mu.Lock()
+		s := r.sessions[m.ClientID]
+		delete(r.sessions, m.ClientID)
+		r.mu.Unlock()
+		if s != nil {
+			s.handleJSON(msg)
+			s.close()
+		}
+	default:
+		r.mu.Lock()
+		s := r.sessions[m.ClientID]
+		r.mu.Unlock()
+		if s != nil {
+			s.handleJSON(msg)
+		}
+	}
+}
+
+func (r *MQTTResponder) newSession(clientID string) (*mqttSession, error) {
+	udp, err := NewUDPEndpoint(net.JoinHostPort(r.cfg.UDPHost, "0"))
+	if err != nil {
+		return nil, err
+	}
+	sid := r.cfg.SessionID
+	if sid == "" {
+		sid = randomHex(8)
+	}
+	s := &mqttSession{r: r, clientID: clientID, topic: r.cfg.ReplyTopic(clientID), udp: udp}
+	s.session = &session{cfg: &r.cfg.Config, id: sid, out: s}
+	udp.mu.Lock()
+	udp.OnFrame = func(_ uint32, opus []byte) { s.upstreamFrame(opus) }
+	udp.mu.Unlock()
+
+	r.mu.Lock()
+	old := r.sessions[clientID]
+	r.sessions[clientID] = s
+	r.history = append(r.history, s)
+	r.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	return s, nil
+}
+
+func (s *mqttSession) publish(b []byte) error {
+	s.r.broker.Publish(s.topic, b, 1, false)
+	return nil
+}
+
+func (s *mqttSession) sendJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.publish(b)
+}
+
+// sendAudio 设备的 UDP 地址要等它发来首个包才知道，此前的下行帧被丢弃
+func (s *mqttSession) sendAudio(opus []byte) error {
+	err := s.udp.Send(opus)
+	if errors.Is(err, errNoPeer) {
+		return nil
+	}
+	return err
+}
+
+func (s *mqttSession) close() {
+	s.stop()
+	_ = s.udp.Close()
+}
@@ -0,0 +1,303 @@
+// Package mockserver 进程内的模拟小智服务端，用于测试与压测：
+// 按 docs/websocket.md 完成 WebSocket 握手与 hello，按脚本下发 stt/llm/tts 消息与 Opus 帧；
+// UDPEndpoint 按 docs/mqtt-udp.md 充当 AES-CTR 加密的 UDP 音频端点。
+package mockserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"myproject/internal/transport"
+)
+
+// AudioParams 服务端 hello 下发的音频参数
+type AudioParams struct {
+	Format        string `json:"format"`
+	SampleRate    int    `json:"sample_rate"`
+	Channels      int    `json:"channels"`
+	FrameDuration int    `json:"frame_duration"`
+}
+
+// Input 一轮对话的输入：listen stop 时为期间收到的上行帧，listen detect 时为文本
+type Input struct {
+	SessionID string
+	Mode      string
+	Text      string
+	Frames    [][]byte
+}
+
+// Reply 一轮对话的脚本化应答，依次下发 stt、llm、tts start、各句（sentence_start + 音频帧）、tts stop
+type Reply struct {
+	STT               string // 为空时不发 stt
+	Emotion           string // 为空时不发 llm
+	LLMText           string
+	Sentences         []string
+	FramesPerSentence int           // 每句合成的静音帧数，默认 5
+	Audio             [][]byte      // 非空时代替合成帧，随第一句整体下发
+	Delay             time.Duration // 下发 stt 前的延迟，模拟识别耗时
+}
+
+// Config 模拟服务端参数
+type Config struct {
+	SessionID   string               // 固定的 session_id；为空时每个连接随机生成
+	AudioParams AudioParams          // 零值为 opus/24000/1/60
+	Token       string               // 非空时校验 Authorization: Bearer 或 access_token/token 查询参数
+	Realtime    bool                 // 按帧时长节奏下发音频；默认尽快下发
+	Echo        bool                 // 将本轮上行帧原样作为 TTS 音频回放
+	Script      func(in Input) Reply // 为 nil 时使用 DefaultReply
+}
+
+// DefaultReply 默认应答：识别结果取检测文本或上行帧数，回复一句话
+func DefaultReply(in Input) Reply {
+	stt := in.Text
+	if stt == "" {
+		stt = fmt.Sprintf("mock stt (%d frames)", len(in.Frames))
+	}
+	return Reply{STT: stt, Emotion: "happy", LLMText: "😀", Sentences: []string{"你好，我是模拟服务端。"}}
+}
+
+// Conn 一个已建立连接的记录
+type Conn struct {
+	Headers        http.Header // 仅 WebSocket
+	Query          string
+	ClientID       string // 仅 MQTT
+	SessionID      string
+	Version        int
+	Messages       []map[string]any // 收到的 JSON 消息
+	UpstreamFrames int
+	Closed         bool
+}
+
+// Server 模拟服务端
+type Server struct {
+	cfg      Config
+	ln       net.Listener
+	srv      *http.Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns []*wsConn
+}
+
+// New 创建模拟服务端（尚未监听）
+func New(cfg Config) *Server {
+	if cfg.AudioParams == (AudioParams{}) {
+		cfg.AudioParams = AudioParams{Format: "opus", SampleRate: 24000, Channels: 1, FrameDuration: 60}
+	}
+	if cfg.Script == nil {
+		cfg.Script = DefaultReply
+	}
+	return &Server{cfg: cfg}
+}
+
+// Start 监听 addr（如 127.0.0.1:0）并开始服务
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.srv = &http.Server{Handler: s}
+	go s.srv.Serve(ln)
+	return nil
+}
+
+// URL 返回 WebSocket 地址
+func (s *Server) URL() string { return "ws://" + s.ln.Addr().String() + "/xiaozhi/v1/" }
+
+// Close 关闭监听与全部连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	conns := append([]*wsConn(nil), s.conns...)
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+// Conns 返回全部连接记录的快照
+func (s *Server) Conns() []Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Conn, 0, len(s.conns))
+	for _, c := range s.conns {
+		info := c.info
+		info.Messages, info.UpstreamFrames = c.snapshot()
+		info.Closed = atomic.LoadInt32(&c.closed) == 1
+		out = append(out, info)
+	}
+	return out
+}
+
+// ServeHTTP 处理 WebSocket 握手，可直接挂到其他 http.Server 上
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Token != "" && !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	version, _ := strconv.Atoi(r.Header.Get("Protocol-Version"))
+	if version != 2 && version != 3 {
+		version = 1
+	}
+	sid := s.cfg.SessionID
+	if sid == "" {
+		sid = randomHex(8)
+	}
+	c := &wsConn{srv: s, ws: ws, info: Conn{Headers: r.Header.Clone(), Query: r.URL.RawQuery, SessionID: sid, Version: version}}
+	c.session = &session{cfg: &s.cfg, id: sid, out: c}
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+	c.readLoop()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if r.Header.Get("Authorization") == "Bearer "+s.cfg.Token {
+		return true
+	}
+	q := r.URL.Query()
+	return q.Get("access_token") == s.cfg.Token || q.Get("token") == s.cfg.Token
+}
+
+type wsConn struct {
+	*session
+	srv     *Server
+	ws      *websocket.Conn
+	info    Conn       // Messages/UpstreamFrames 取自 session
+	wmu     sync.Mutex // gorilla/websocket 不允许并发写
+	audioTs uint32     // v2 帧时间戳（毫秒），由 wmu 保护
+
+	closed    int32
+	closeOnce sync.Once
+}
+
+func (c *wsConn) readLoop() {
+	defer c.close()
+	for {
+		typ, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case websocket.TextMessage:
+			c.handleText(data)
+		case websocket.BinaryMessage:
+			c.handleBinary(data)
+		}
+	}
+}
+
+func (c *wsConn) handleText(data []byte) {
+	var msg map[string]any
+	if json.Unmarshal(data, &msg) != nil {
+		return
+	}
+	c.handleJSON(msg)
+	switch msg["type"] {
+	case "hello":
+		_ = c.sendJSON(map[string]any{
+			"type": "hello", "transport": "websocket", "version": c.info.Version,
+			"session_id": c.id, "audio_params": c.cfg.AudioParams,
+		})
+	case "goodbye":
+		c.close()
+	}
+}
+
+func (c *wsConn) handleBinary(data []byte) {
+	var (
+		frame transport.BinaryFrame
+		err   error
+	)
+	switch c.info.Version {
+	case 2:
+		frame, err = transport.DecodeBinaryProtocol2(data)
+	case 3:
+		frame, err = transport.DecodeBinaryProtocol3(data)
+	default:
+		frame = transport.BinaryFrame{Version: 1, Type: transport.BinaryTypeOpus, Payload: data}
+	}
+	if err != nil || frame.Type != transport.BinaryTypeOpus {
+		return
+	}
+	c.upstreamFrame(frame.Payload)
+}
+
+func (c *wsConn) sendJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, b)
+}
+
+func (c *wsConn) sendAudio(opus []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	data := opus
+	switch c.info.Version {
+	case 2:
+		data = transport.EncodeBinaryProtocol2(transport.BinaryTypeOpus, c.audioTs, opus)
+		c.audioTs += uint32(c.cfg.AudioParams.FrameDuration)
+	case 3:
+		var err error
+		if data, err = transport.EncodeBinaryProtocol3(transport.BinaryTypeOpus, opus); err != nil {
+			return err
+		}
+	}
+	return c.ws.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		atomic.StoreInt32(&c.closed, 1)
+		c.stop()
+		_ = c.ws.Close()
+	})
+}
+
+// SilenceFrames 合成 n 个静音 Opus 帧：只含 TOC 字节（SILK 单声道，帧内容长度为 0），
+// 解码器按丢包隐藏输出静音，无需 libopus 编码
+func SilenceFrames(frameDurationMs, n int) [][]byte {
+	cfg := byte(3) // 60ms
+	switch frameDurationMs {
+	case 10:
+		cfg = 0
+	case 20:
+		cfg = 1
+	case 40:
+		cfg = 2
+	}
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = []byte{cfg << 3}
+	}
+	return out
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return strings.ToLower(hex.EncodeToString(b))
+}
@@ -0,0 +1,174 @@
+package mockserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sink 会话的下行通道：WebSocket 连接，或 MQTT 控制消息 + UDP 音频
+type sink interface {
+	sendJSON(v any) error
+	sendAudio(opus []byte) error
+}
+
+// session 一个会话的对话状态（监听、上行帧、进行中的应答），WebSocket 与 MQTT+UDP 共用
+type session struct {
+	cfg *Config
+	id  string
+	out sink
+
+	mu        sync.Mutex
+	messages  []map[string]any
+	upstream  int
+	listening bool
+	frames    [][]byte
+	cancel    context.CancelFunc // 正在进行的一轮应答
+	done      chan struct{}      // 上一轮 play 返回（已发出 tts stop）后关闭
+}
+
+// handleJSON 处理 listen/abort 消息；hello、goodbye 由具体通道处理
+func (s *session) handleJSON(msg map[string]any) {
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	state, _ := msg["state"].(string)
+	mode, _ := msg["mode"].(string)
+	switch msg["type"] {
+	case "listen":
+		switch state {
+		case "start":
+			s.mu.Lock()
+			s.listening, s.frames = true, nil
+			s.mu.Unlock()
+		case "stop":
+			s.mu.Lock()
+			frames := s.frames
+			s.listening, s.frames = false, nil
+			s.mu.Unlock()
+			s.respond(Input{SessionID: s.id, Mode: mode, Frames: frames})
+		case "detect":
+			text, _ := msg["text"].(string)
+			s.respond(Input{SessionID: s.id, Mode: mode, Text: text})
+		}
+	case "abort":
+		s.stop()
+	}
+}
+
+// upstreamFrame 记录上行 Opus 帧，监听期间的帧作为本轮输入
+func (s *session) upstreamFrame(opus []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upstream++
+	if s.listening {
+		s.frames = append(s.frames, append([]byte(nil), opus...))
+	}
+}
+
+// stop 中断进行中的应答
+func (s *session) stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *session) snapshot() (messages []map[string]any, upstream int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.messages...), s.upstream
+}
+
+// respond 取消上一轮并在新 goroutine 中按脚本下发应答；
+// 先等上一轮 play 返回，避免其延迟发出的 tts stop 落在新一轮中
+func (s *session) respond(in Input) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.mu.Lock()
+	prevCancel, prevDone := s.cancel, s.done
+	s.cancel, s.done = cancel, done
+	s.mu.Unlock()
+	if prevCancel != nil {
+		prevCancel()
+	}
+	if prevDone != nil {
+		<-prevDone
+	}
+
+	reply := s.cfg.Script(in)
+	if s.cfg.Echo && len(reply.Audio) == 0 {
+		reply.Audio = in.Frames
+	}
+	go func() {
+		defer close(done)
+		s.play(ctx, reply)
+	}()
+}
+
+// play 依次下发 stt、llm、tts start、各句（sentence_start + 音频帧 + sentence_end）、tts stop
+func (s *session) play(ctx context.Context, r Reply) {
+	sid := s.id
+	ap := s.cfg.AudioParams
+	frameDur := time.Duration(ap.FrameDuration) * time.Millisecond
+	wait := func(d time.Duration) bool {
+		if d <= 0 {
+			return ctx.Err() == nil
+		}
+		select {
+		case <-time.After(d):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	sendAudio := func(frames [][]byte) bool {
+		for _, f := range frames {
+			if ctx.Err() != nil || s.out.sendAudio(f) != nil {
+				return false
+			}
+			if s.cfg.Realtime && !wait(frameDur) {
+				return false
+			}
+		}
+		return true
+	}
+	// 被 abort 时也要结束本轮
+	defer func() { _ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "tts", "state": "stop"}) }()
+
+	if !wait(r.Delay) {
+		return
+	}
+	if r.STT != "" {
+		_ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "stt", "text": r.STT})
+	}
+	if r.Emotion != "" {
+		_ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "llm", "emotion": r.Emotion, "text": r.LLMText})
+	}
+	_ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "tts", "state": "start"})
+	n := r.FramesPerSentence
+	if n <= 0 {
+		n = 5
+	}
+	for i, text := range r.Sentences {
+		_ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "tts", "state": "sentence_start", "text": text})
+		frames := SilenceFrames(ap.FrameDuration, n)
+		if len(r.Audio) > 0 {
+			frames = nil
+			if i == 0 {
+				frames = r.Audio
+			}
+		}
+		if !sendAudio(frames) {
+			return
+		}
+		_ = s.out.sendJSON(map[string]any{"session_id": sid, "type": "tts", "state": "sentence_end", "text": text})
+	}
+	if len(r.Sentences) == 0 {
+		sendAudio(r.Audio)
+	}
+}
@@ -0,0 +1,62 @@
+package mockserver
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink 按顺序记录下发的 JSON 消息类型/状态
+type recordSink struct {
+	mu  sync.Mutex
+	out []string
+}
+
+func (r *recordSink) sendJSON(v any) error {
+	m := v.(map[string]any)
+	s := fmt.Sprint(m["type"])
+	if st, ok := m["state"]; ok {
+		s += ":" + fmt.Sprint(st)
+	}
+	if txt, ok := m["text"]; ok && m["type"] == "stt" {
+		s += ":" + fmt.Sprint(txt)
+	}
+	r.mu.Lock()
+	r.out = append(r.out, s)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recordSink) sendAudio([]byte) error { return nil }
+
+func TestRespondWaitsForPreviousTurn(t *testing.T) {
+	out := &recordSink{}
+	cfg := New(Config{Script: func(in Input) Reply {
+		return Reply{STT: in.Text, Sentences: []string{"x"}, FramesPerSentence: 1, Delay: 50 * time.Millisecond}
+	}}).cfg
+	s := &session{cfg: &cfg, id: "s", out: out}
+	for i := 0; i < 20; i++ {
+		s.handleJSON(map[string]any{"type": "listen", "state": "detect", "text": fmt.Sprint(i)})
+	}
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	<-done
+
+	// 被取消的各轮只发出 tts stop，且都在最后一轮开始之前
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	want := []string{"stt:19", "tts:start", "tts:sentence_start", "tts:sentence_end", "tts:stop"}
+	if len(out.out) != 19+len(want) {
+		t.Fatalf("messages %v", out.out)
+	}
+	for i, m := range out.out[:19] {
+		if m != "tts:stop" {
+			t.Fatalf("message %d %q, want tts:stop (%v)", i, m, out.out)
+		}
+	}
+	if fmt.Sprint(out.out[19:]) != fmt.Sprint(want) {
+		t.Errorf("last turn %v, want %v", out.out[19:], want)
+	}
+}
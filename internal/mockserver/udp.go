@@ -0,0 +1,142 @@
+package mockserver
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"myproject/internal/transport"
+)
+
+// UDPEndpoint AES-CTR 加密的 UDP 音频端点（docs/mqtt-udp.md 第 4 节）：
+// 解密上行帧，并向最近一个发来数据的地址下发加密帧
+type UDPEndpoint struct {
+	KeyHex   string
+	NonceHex string
+
+	// OnFrame 收到上行帧时回调（在读取 goroutine 中执行）；监听开始后修改须持有 mu
+	OnFrame func(seq uint32, opus []byte)
+
+	conn  *net.UDPConn
+	key   []byte
+	nonce []byte
+
+	mu       sync.Mutex
+	peer     *net.UDPAddr
+	ssrc     uint32
+	seq      uint32
+	received [][]byte
+	done     chan struct{}
+}
+
+var errNoPeer = errors.New("mockserver: udp peer unknown")
+
+// NewUDPEndpoint 在 addr（如 127.0.0.1:0）上监听，随机生成 key/nonce
+func NewUDPEndpoint(addr string) (*UDPEndpoint, error) {
+	la, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", la)
+	if err != nil {
+		return nil, err
+	}
+	u := &UDPEndpoint{conn: conn, KeyHex: randomHex(16), NonceHex: randomHex(16), ssrc: 0x6d6f636b, done: make(chan struct{})}
+	u.key, _ = hex.DecodeString(u.KeyHex)
+	u.nonce, _ = hex.DecodeString(u.NonceHex)
+	go u.readLoop()
+	return u, nil
+}
+
+// Addr 返回监听地址
+func (u *UDPEndpoint) Addr() *net.UDPAddr { return u.conn.LocalAddr().(*net.UDPAddr) }
+
+// Info 返回服务端 hello 中 udp 字段的内容
+func (u *UDPEndpoint) Info() map[string]any {
+	a := u.Addr()
+	return map[string]any{"server": a.IP.String(), "port": a.Port, "key": u.KeyHex, "nonce": u.NonceHex}
+}
+
+// MQTTHello 构造 MQTT 通道上的服务端 hello 应答（transport=udp）
+func (u *UDPEndpoint) MQTTHello(sessionID string, ap AudioParams) []byte {
+	b, _ := json.Marshal(map[string]any{"type": "hello", "transport": "udp", "session_id": sessionID, "audio_params": ap, "udp": u.Info()})
+	return b
+}
+
+// Received 返回已解密的上行帧
+func (u *UDPEndpoint) Received() [][]byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([][]byte(nil), u.received...)
+}
+
+// Send 以递增序号向对端下发一帧；对端尚未发来数据时返回错误
+func (u *UDPEndpoint) Send(opus []byte) error {
+	u.mu.Lock()
+	u.seq++
+	seq := u.seq
+	u.mu.Unlock()
+	return u.SendSeq(seq, opus)
+}
+
+// SendSeq 以指定序号下发一帧，用于构造乱序/丢包场景
+func (u *UDPEndpoint) SendSeq(seq uint32, opus []byte) error {
+	u.mu.Lock()
+	peer := u.peer
+	u.mu.Unlock()
+	if peer == nil {
+		return errNoPeer
+	}
+	h := transport.UDPAudioHeader{Type: 0x01, PayloadLen: uint16(len(opus)), SSRC: u.ssrc, Timestamp: uint32(time.Now().UnixNano() / 1e6), Seq: seq}
+	// AES-CTR 加密与解密为同一运算
+	enc, err := transport.DecryptUDPAudio(u.key, u.nonce, h, opus)
+	if err != nil {
+		return err
+	}
+	pkt := make([]byte, transport.UDPAudioHeaderSize, transport.UDPAudioHeaderSize+len(enc))
+	pkt[0] = h.Type
+	binary.BigEndian.PutUint16(pkt[2:4], h.PayloadLen)
+	binary.BigEndian.PutUint32(pkt[4:8], h.SSRC)
+	binary.BigEndian.PutUint32(pkt[8:12], h.Timestamp)
+	binary.BigEndian.PutUint32(pkt[12:16], h.Seq)
+	_, err = u.conn.WriteToUDP(append(pkt, enc...), peer)
+	return err
+}
+
+// Close 停止监听
+func (u *UDPEndpoint) Close() error {
+	err := u.conn.Close()
+	<-u.done
+	return err
+}
+
+func (u *UDPEndpoint) readLoop() {
+	defer close(u.done)
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := u.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		h, payload, err := transport.ParseUDPAudioPacket(buf[:n])
+		if err != nil || h.Type != 0x01 {
+			continue
+		}
+		plain, err := transport.DecryptUDPAudio(u.key, u.nonce, h, payload)
+		if err != nil {
+			continue
+		}
+		u.mu.Lock()
+		u.peer = from
+		u.received = append(u.received, plain)
+		onFrame := u.OnFrame
+		u.mu.Unlock()
+		if onFrame != nil {
+			onFrame(h.Seq, plain)
+		}
+	}
+}
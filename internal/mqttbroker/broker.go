@@ -0,0 +1,537 @@
+// Package mqttbroker 轻量的嵌入式 MQTT 3.1.1 broker，用于在本机或 CI 中跑通 MQTT+UDP 流程：
+// 支持 connect 鉴权、subscribe/unsubscribe、QoS 0/1 发布、保留消息、遗嘱与 keepalive。
+// 不支持 QoS 2（收到即断开连接），不持久化会话（clean session=0 也按新会话处理）。
+package mqttbroker
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"myproject/internal/logging"
+)
+
+// Message 一条应用消息
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retain   bool
+	ClientID string // 发布者，进程内发布时为空
+}
+
+// Config broker 参数
+type Config struct {
+	// Username/Password 非空时要求客户端凭据一致
+	Username string
+	Password string
+	// Auth 自定义鉴权，设置后忽略 Username/Password
+	Auth func(clientID, username, password string) bool
+}
+
+// Broker 嵌入式 MQTT broker
+type Broker struct {
+	cfg Config
+	ln  net.Listener
+
+	mu       sync.RWMutex
+	clients  map[string]*conn
+	hooks    map[int]hook
+	hookSeq  int
+	retained map[string]Message
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type hook struct {
+	filter string
+	fn     func(Message)
+}
+
+// New 创建 broker（尚未监听）
+func New(cfg Config) *Broker {
+	return &Broker{cfg: cfg, clients: make(map[string]*conn), hooks: make(map[int]hook), retained: make(map[string]Message)}
+}
+
+// Start 监听 addr（如 127.0.0.1:1883 或 127.0.0.1:0）并开始服务
+func (b *Broker) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	b.ln = ln
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.Serve(ln)
+	}()
+	return nil
+}
+
+// Serve 在 ln 上接受连接，直到 ln 关闭
+func (b *Broker) Serve(ln net.Listener) {
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handle(nc)
+		}()
+	}
+}
+
+// Addr 返回监听地址
+func (b *Broker) Addr() net.Addr { return b.ln.Addr() }
+
+// URL 返回 paho 可用的 broker 地址
+func (b *Broker) URL() string { return "tcp://" + b.ln.Addr().String() }
+
+// Close 关闭监听与全部连接
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	conns := make([]*conn, 0, len(b.clients))
+	for _, c := range b.clients {
+		conns = append(conns, c)
+	}
+	b.mu.Unlock()
+	var err error
+	if b.ln != nil {
+		err = b.ln.Close()
+	}
+	for _, c := range conns {
+		c.close()
+	}
+	b.wg.Wait()
+	return err
+}
+
+// Clients 返回当前在线的客户端 ID
+func (b *Broker) Clients() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]string, 0, len(b.clients))
+	for id := range b.clients {
+		out = append(out, id)
+	}
+	return out
+}
+
+// Disconnect 断开指定客户端（不发送 DISCONNECT，模拟网络中断，会触发遗嘱），用于测试自动重连
+func (b *Broker) Disconnect(clientID string) bool {
+	b.mu.RLock()
+	c := b.clients[clientID]
+	b.mu.RUnlock()
+	if c == nil {
+		return false
+	}
+	c.close()
+	return true
+}
+
+// Subscribe 进程内订阅（如服务端 hello 应答器），返回取消订阅函数；fn 在发布者 goroutine 中同步调用
+func (b *Broker) Subscribe(filter string, fn func(Message)) (unsubscribe func()) {
+	b.mu.Lock()
+	b.hookSeq++
+	id := b.hookSeq
+	b.hooks[id] = hook{filter: filter, fn: fn}
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.hooks, id)
+		b.mu.Unlock()
+	}
+}
+
+// Publish 进程内发布，QoS 取 0 或 1
+func (b *Broker) Publish(topic string, payload []byte, qos byte, retain bool) {
+	if qos > 1 {
+		qos = 1
+	}
+	b.route(Message{Topic: topic, Payload: payload, QoS: qos, Retain: retain})
+}
+
+// route 投递给匹配的客户端与进程内订阅；同一客户端多个订阅匹配时只投递一次，取最高 QoS
+func (b *Broker) route(m Message) {
+	b.mu.Lock()
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	type target struct {
+		c   *conn
+		qos byte
+	}
+	var targets []target
+	for _, c := range b.clients {
+		if qos, ok := c.matchQoS(m.Topic); ok {
+			targets = append(targets, target{c, min(qos, m.QoS)})
+		}
+	}
+	var fns []func(Message)
+	for _, h := range b.hooks {
+		if matchTopic(h.filter, m.Topic) {
+			fns = append(fns, h.fn)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, t := range targets {
+		// 转发时不保留 retain 标志（仅在订阅时下发的保留消息带该标志）
+		t.c.publish(m.Topic, m.Payload, t.qos, false)
+	}
+	for _, fn := range fns {
+		fn(m)
+	}
+}
+
+func (b *Broker) authorize(clientID, username, password string) bool {
+	if b.cfg.Auth != nil {
+		return b.cfg.Auth(clientID, username, password)
+	}
+	if b.cfg.Username == "" && b.cfg.Password == "" {
+		return true
+	}
+	return username == b.cfg.Username && password == b.cfg.Password
+}
+
+// register 登记新连接；同 ID 的旧连接被踢下线（MQTT-3.1.4-2）
+func (b *Broker) register(c *conn) bool {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return false
+	}
+	old := b.clients[c.id]
+	b.clients[c.id] = c
+	b.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	return true
+}
+
+func (b *Broker) unregister(c *conn) {
+	b.mu.Lock()
+	if b.clients[c.id] == c {
+		delete(b.clients, c.id)
+	}
+	b.mu.Unlock()
+}
+
+func (b *Broker) retainedFor(filter string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var out []Message
+	for topic, m := range b.retained {
+		if matchTopic(filter, topic) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// conn 一个客户端连接
+type conn struct {
+	b         *Broker
+	nc        net.Conn
+	id        string
+	keepAlive time.Duration
+	will      *Message
+
+	wmu    sync.Mutex
+	w      *bufio.Writer
+	nextID uint16
+
+	subs      map[string]byte // 订阅过滤器 → QoS，由 Broker.mu 保护
+	closeOnce sync.Once
+}
+
+func (b *Broker) handle(nc net.Conn) {
+	log := logging.L().With("module", "mqttbroker")
+	c := &conn{b: b, nc: nc, w: bufio.NewWriter(nc), subs: make(map[string]byte)}
+	defer c.close()
+	r := bufio.NewReader(nc)
+
+	// 首个报文必须是 CONNECT
+	_ = nc.SetReadDeadline(time.Now().Add(10 * time.Second))
+	h, body, err := readPacket(r)
+	if err != nil || h>>4 != pktConnect {
+		return
+	}
+	if rc := c.connect(body); rc != connAccepted {
+		_ = c.write(encodePacket(pktConnack<<4, []byte{0, rc}))
+		log.Debug("拒绝连接", "remote", nc.RemoteAddr().String(), "rc", rc)
+		return
+	}
+	if !b.register(c) {
+		return
+	}
+	defer b.unregister(c)
+	if err := c.write(encodePacket(pktConnack<<4, []byte{0, connAccepted})); err != nil {
+		return
+	}
+	log.Debug("客户端已连接", "client_id", c.id, "keepalive", c.keepAlive)
+
+	for {
+		// keepalive 的 1.5 倍内未收到任何报文视为断线（MQTT-3.1.2-24）
+		if c.keepAlive > 0 {
+			_ = nc.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		} else {
+			_ = nc.SetReadDeadline(time.Time{})
+		}
+		h, body, err := readPacket(r)
+		if err != nil {
+			break
+		}
+		if h>>4 == pktDisconnect {
+			c.will = nil // 正常断开不发布遗嘱
+			break
+		}
+		if err := c.dispatch(h, body); err != nil {
+			log.Debug("断开客户端", "client_id", c.id, "err", err)
+			break
+		}
+	}
+	if c.will != nil {
+		b.route(*c.will)
+	}
+}
+
+// connect 解析 CONNECT 并鉴权，返回 CONNACK 返回码
+func (c *conn) connect(body []byte) byte {
+	rd := &reader{b: body}
+	proto := rd.string()
+	level := rd.byte()
+	flags := rd.byte()
+	keepAlive := rd.uint16()
+	if rd.err != nil {
+		return connBadProtocol
+	}
+	if proto != "MQTT" || level != 4 {
+		return connBadProtocol
+	}
+	c.id = rd.string()
+	if flags&0x04 != 0 {
+		topic := rd.string()
+		payload := append([]byte(nil), rd.bytes()...)
+		c.will = &Message{Topic: topic, Payload: payload, QoS: min((flags>>3)&0x03, 1), Retain: flags&0x20 != 0, ClientID: c.id}
+	}
+	var username, password string
+	if flags&0x80 != 0 {
+		username = rd.string()
+	}
+	if flags&0x40 != 0 {
+		password = rd.string()
+	}
+	if rd.err != nil {
+		return connBadProtocol
+	}
+	if c.id == "" {
+		if flags&0x02 == 0 {
+			return connIdentifierInvalid
+		}
+		c.id = "auto-" + randomID()
+	}
+	if !c.b.authorize(c.id, username, password) {
+		return connBadCredentials
+	}
+	c.keepAlive = time.Duration(keepAlive) * time.Second
+	return connAccepted
+}
+
+func (c *conn) dispatch(h byte, body []byte) error {
+	switch h >> 4 {
+	case pktPublish:
+		return c.onPublish(h, body)
+	case pktPuback:
+		// 出站 QoS 1 的确认；不重发，无需跟踪
+		return nil
+	case pktSubscribe:
+		return c.onSubscribe(body)
+	case pktUnsubscribe:
+		return c.onUnsubscribe(body)
+	case pktPingreq:
+		return c.write(encodePacket(pktPingresp<<4, nil))
+	case pktPubrec, pktPubrel, pktPubcomp:
+		return errors.New("QoS 2 not supported")
+	}
+	return fmt.Errorf("unexpected packet type %d", h>>4)
+}
+
+func (c *conn) onPublish(h byte, body []byte) error {
+	qos := (h >> 1) & 0x03
+	if qos > 1 {
+		return errors.New("QoS 2 not supported")
+	}
+	rd := &reader{b: body}
+	topic := rd.string()
+	var id uint16
+	if qos > 0 {
+		id = rd.uint16()
+	}
+	if rd.err != nil || topic == "" || strings.ContainsAny(topic, "+#") {
+		return errMalformed
+	}
+	// 先投递再确认：发布者收到 PUBACK 时进程内订阅已处理完该消息
+	c.b.route(Message{Topic: topic, Payload: append([]byte(nil), rd.b...), QoS: qos, Retain: h&0x01 != 0, ClientID: c.id})
+	if qos == 1 {
+		return c.write(encodePacket(pktPuback<<4, binary.BigEndian.AppendUint16(nil, id)))
+	}
+	return nil
+}
+
+func (c *conn) onSubscribe(body []byte) error {
+	rd := &reader{b: body}
+	id := rd.uint16()
+	var filters []string
+	resp := binary.BigEndian.AppendUint16(nil, id)
+	for rd.err == nil && len(rd.b) > 0 {
+		filter := rd.string()
+		qos := rd.byte()
+		if rd.err != nil {
+			break
+		}
+		if !validFilter(filter) || qos > 2 {
+			resp = append(resp, 0x80)
+			continue
+		}
+		granted := min(qos, 1)
+		c.b.mu.Lock()
+		c.subs[filter] = granted
+		c.b.mu.Unlock()
+		filters = append(filters, filter)
+		resp = append(resp, granted)
+	}
+	if rd.err != nil || len(resp) == 2 {
+		return errMalformed
+	}
+	if err := c.write(encodePacket(pktSuback<<4, resp)); err != nil {
+		return err
+	}
+	for _, f := range filters {
+		for _, m := range c.b.retainedFor(f) {
+			c.publish(m.Topic, m.Payload, min(m.QoS, c.subs[f]), true)
+		}
+	}
+	return nil
+}
+
+func (c *conn) onUnsubscribe(body []byte) error {
+	rd := &reader{b: body}
+	id := rd.uint16()
+	for rd.err == nil && len(rd.b) > 0 {
+		filter := rd.string()
+		c.b.mu.Lock()
+		delete(c.subs, filter)
+		c.b.mu.Unlock()
+	}
+	if rd.err != nil {
+		return rd.err
+	}
+	return c.write(encodePacket(pktUnsuback<<4, binary.BigEndian.AppendUint16(nil, id)))
+}
+
+// matchQoS 调用方持有 Broker.mu
+func (c *conn) matchQoS(topic string) (byte, bool) {
+	var best byte
+	found := false
+	for f, q := range c.subs {
+		if matchTopic(f, topic) {
+			found = true
+			best = max(best, q)
+		}
+	}
+	return best, found
+}
+
+func (c *conn) publish(topic string, payload []byte, qos byte, retain bool) {
+	h := byte(pktPublish<<4) | qos<<1
+	if retain {
+		h |= 0x01
+	}
+	body := appendString(nil, topic)
+	c.wmu.Lock()
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		body = binary.BigEndian.AppendUint16(body, c.nextID)
+	}
+	body = append(body, payload...)
+	err := c.writeLocked(encodePacket(h, body))
+	c.wmu.Unlock()
+	if err != nil {
+		c.close()
+	}
+}
+
+func (c *conn) write(p []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeLocked(p)
+}
+
+func (c *conn) writeLocked(p []byte) error {
+	_ = c.nc.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.w.Write(p); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) close() { c.closeOnce.Do(func() { _ = c.nc.Close() }) }
+
+// matchTopic 按 MQTT 规则匹配过滤器（+ 单层、# 多层）；$ 开头的主题不匹配首层通配符
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, p := range f {
+		if p == "#" {
+			return true
+		}
+		if i >= len(t) || (p != "+" && p != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func validFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, l := range levels {
+		if strings.Contains(l, "#") && (l != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(l, "+") && l != "+" {
+			return false
+		}
+	}
+	return true
+}
+
+func randomID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
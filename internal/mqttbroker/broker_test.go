@@ -0,0 +1,132 @@
+package mqttbroker_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"myproject/internal/mockserver/mocktest"
+	"myproject/internal/mqttbroker"
+)
+
+func connect(t *testing.T, b *mqttbroker.Broker, id, user, pass string) (mqtt.Client, error) {
+	t.Helper()
+	opts := mqtt.NewClientOptions().AddBroker(b.URL()).SetClientID(id).SetUsername(user).SetPassword(pass)
+	opts.SetAutoReconnect(false).SetConnectRetry(false).SetProtocolVersion(4)
+	c := mqtt.NewClient(opts)
+	tok := c.Connect()
+	if !tok.WaitTimeout(2 * time.Second) {
+		t.Fatal("connect timeout")
+	}
+	if tok.Error() == nil {
+		t.Cleanup(func() { c.Disconnect(0) })
+	}
+	return c, tok.Error()
+}
+
+func TestPublishSubscribeQoS1(t *testing.T) {
+	b := mocktest.StartBroker(t, mqttbroker.Config{})
+	sub, err := connect(t, b, "sub", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan mqtt.Message, 4)
+	if tok := sub.Subscribe("devices/+/p2p", 1, func(_ mqtt.Client, m mqtt.Message) { got <- m }); !tok.WaitTimeout(time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+	pub, err := connect(t, b, "pub", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok := pub.Publish("devices/a1/p2p", 1, false, "hello"); !tok.WaitTimeout(time.Second) || tok.Error() != nil {
+		t.Fatalf("publish: %v", tok.Error())
+	}
+	pub.Publish("devices/a1/other", 1, false, "skip").WaitTimeout(time.Second)
+	select {
+	case m := <-got:
+		if m.Topic() != "devices/a1/p2p" || string(m.Payload()) != "hello" || m.Qos() != 1 {
+			t.Errorf("got %s %q qos=%d", m.Topic(), m.Payload(), m.Qos())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+	select {
+	case m := <-got:
+		t.Errorf("unexpected message on %s", m.Topic())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAuth(t *testing.T) {
+	b := mocktest.StartBroker(t, mqttbroker.Config{Username: "u", Password: "p"})
+	if _, err := connect(t, b, "bad", "u", "wrong"); err == nil {
+		t.Error("connect with wrong password succeeded")
+	}
+	if _, err := connect(t, b, "good", "u", "p"); err != nil {
+		t.Errorf("connect with valid credentials: %v", err)
+	}
+}
+
+func TestInProcessHookAndRetained(t *testing.T) {
+	b := mocktest.StartBroker(t, mqttbroker.Config{})
+	hooked := make(chan mqttbroker.Message, 1)
+	unsub := b.Subscribe("device-server", func(m mqttbroker.Message) { hooked <- m })
+	defer unsub()
+
+	c, err := connect(t, b, "dev-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Publish("device-server", 1, false, `{"type":"hello"}`).WaitTimeout(time.Second)
+	select {
+	case m := <-hooked:
+		if m.ClientID != "dev-1" || string(m.Payload) != `{"type":"hello"}` {
+			t.Errorf("hook got %+v", m)
+		}
+	default:
+		// 先投递后确认：PUBACK 返回时钩子已执行
+		t.Fatal("hook not called before PUBACK")
+	}
+
+	b.Publish("status/dev-1", []byte("online"), 1, true)
+	got := make(chan string, 1)
+	c.Subscribe("status/#", 1, func(_ mqtt.Client, m mqtt.Message) { got <- string(m.Payload()) }).WaitTimeout(time.Second)
+	select {
+	case v := <-got:
+		if v != "online" {
+			t.Errorf("retained payload %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retained message not delivered on subscribe")
+	}
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+	b := mocktest.StartBroker(t, mqttbroker.Config{})
+	nc, err := net.Dial("tcp", b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	// CONNECT（MQTT 3.1.1，clean session）：keepalive 1 秒，之后不再发送任何报文
+	pkt := []byte("\x10\x10\x00\x04MQTT\x04\x02\x00\x01\x00\x04idle")
+	if _, err := nc.Write(pkt); err != nil {
+		t.Fatal(err)
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(nc, ack); err != nil || !bytes.Equal(ack, []byte{0x20, 0x02, 0x00, 0x00}) {
+		t.Fatalf("connack: %x %v", ack, err)
+	}
+	_ = nc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	start := time.Now()
+	if _, err := nc.Read(ack); err == nil {
+		t.Fatal("expected broker to close idle connection")
+	}
+	if d := time.Since(start); d < time.Second || d > 2500*time.Millisecond {
+		t.Errorf("closed after %v, want ~1.5s", d)
+	}
+}
@@ -0,0 +1,25 @@
+package mqttbroker
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"+/b", "a/b", true},
+		{"#", "$SYS/x", false},
+		{"a/b", "a/c", false},
+	}
+	for _, c := range cases {
+		if got := matchTopic(c.filter, c.topic); got != c.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", c.filter, c.topic, got, c.want)
+		}
+	}
+}
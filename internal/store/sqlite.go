@@ -25,6 +25,8 @@ func (d *DB) migrate() error {
 	stmts := []string{
 		`CREATE TABLE IF NOT EXISTS sessions( id TEXT PRIMARY KEY, transport TEXT, created_at INTEGER );`,
 		`CREATE TABLE IF NOT EXISTS messages( id INTEGER PRIMARY KEY AUTOINCREMENT, session_id TEXT, direction TEXT, type TEXT, payload TEXT, created_at INTEGER, FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS recordings( id INTEGER PRIMARY KEY AUTOINCREMENT, session_id TEXT, sentence TEXT, path TEXT, format TEXT, sample_rate INTEGER, channels INTEGER, frames INTEGER, duration_ms INTEGER, created_at INTEGER );`,
+		`CREATE INDEX IF NOT EXISTS idx_recordings_session ON recordings(session_id);`,
 	}
 	for _, s := range stmts { if _, err := d.db.Exec(s); err != nil { return err } }
 	return nil
@@ -62,3 +64,32 @@ func (d *DB) ClearMessages(ctx context.Context) error {
 	_, err := d.db.ExecContext(ctx, `DELETE FROM messages`)
 	return err
 }
+
+// Recording 下行音频录音文件记录
+type Recording struct { ID int64 `json:"id"`; SessionID string `json:"session_id"`; Sentence string `json:"sentence"`; Path string `json:"path"`; Format string `json:"format"`; SampleRate int `json:"sample_rate"`; Channels int `json:"channels"`; Frames int `json:"frames"`; DurationMs int64 `json:"duration_ms"`; CreatedAt int64 `json:"created_at"` }
+
+// SaveRecording 记录一个录音文件
+func (d *DB) SaveRecording(ctx context.Context, r Recording) error {
+	_, err := d.db.ExecContext(ctx, `INSERT INTO recordings(session_id,sentence,path,format,sample_rate,channels,frames,duration_ms,created_at) VALUES(?,?,?,?,?,?,?,?,?)`,
+		r.SessionID, r.Sentence, r.Path, r.Format, r.SampleRate, r.Channels, r.Frames, r.DurationMs, r.CreatedAt)
+	return err
+}
+
+// Recordings 按时间倒序列出录音；sessionID 为空时列出全部
+func (d *DB) Recordings(ctx context.Context, sessionID string, limit int) ([]Recording, error) {
+	q := `SELECT id,session_id,sentence,path,format,sample_rate,channels,frames,duration_ms,created_at FROM recordings`
+	args := []any{}
+	if sessionID != "" { q += ` WHERE session_id = ?`; args = append(args, sessionID) }
+	q += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := d.db.QueryContext(ctx, q, args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []Recording
+	for rows.Next() {
+		var r Recording
+		if err := rows.Scan(&r.ID, &r.SessionID, &r.Sentence, &r.Path, &r.Format, &r.SampleRate, &r.Channels, &r.Frames, &r.DurationMs, &r.CreatedAt); err != nil { return nil, err }
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WebSocket 二进制帧协议（docs/websocket.md 第 3 节）
+// 版本1：裸 Opus；版本2：BinaryProtocol2（16 字节头，含时间戳）；版本3：BinaryProtocol3（4 字节头）
+
+// 二进制帧负载类型
+const (
+	BinaryTypeOpus = 0
+	BinaryTypeJSON = 1
+)
+
+const (
+	binaryProtocol2HeaderSize = 16
+	binaryProtocol3HeaderSize = 4
+)
+
+var ErrBinaryFrame = errors.New("invalid binary frame")
+
+// BinaryFrame 解析后的二进制帧
+type BinaryFrame struct {
+	Version   int
+	Type      int
+	Timestamp uint32 // 毫秒，仅版本2携带
+	Payload   []byte
+}
+
+// EncodeBinaryProtocol2 按 BinaryProtocol2 打包（网络字节序）
+//
+//	uint16 version | uint16 type | uint32 reserved | uint32 timestamp | uint32 payload_size | payload
+func EncodeBinaryProtocol2(typ int, timestamp uint32, payload []byte) []byte {
+	buf := make([]byte, binaryProtocol2HeaderSize+len(payload))
+	binary.BigEndian.PutUint16(buf[0:2], 2)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(typ))
+	binary.BigEndian.PutUint32(buf[4:8], 0)
+	binary.BigEndian.PutUint32(buf[8:12], timestamp)
+	binary.BigEndian.PutUint32(buf[12:16], uint32(len(payload)))
+	copy(buf[binaryProtocol2HeaderSize:], payload)
+	return buf
+}
+
+// DecodeBinaryProtocol2 解析 BinaryProtocol2 帧，payload 与 data 共享底层内存
+func DecodeBinaryProtocol2(data []byte) (BinaryFrame, error) {
+	if len(data) < binaryProtocol2HeaderSize {
+		return BinaryFrame{}, fmt.Errorf("%w: v2 frame too short (%d bytes)", ErrBinaryFrame, len(data))
+	}
+	if v := binary.BigEndian.Uint16(data[0:2]); v != 2 {
+		return BinaryFrame{}, fmt.Errorf("%w: v2 frame version=%d", ErrBinaryFrame, v)
+	}
+	size := binary.BigEndian.Uint32(data[12:16])
+	if int(size) != len(data)-binaryProtocol2HeaderSize {
+		return BinaryFrame{}, fmt.Errorf("%w: v2 payload_size=%d, actual=%d", ErrBinaryFrame, size, len(data)-binaryProtocol2HeaderSize)
+	}
+	return BinaryFrame{
+		Version:   2,
+		Type:      int(binary.BigEndian.Uint16(data[2:4])),
+		Timestamp: binary.BigEndian.Uint32(data[8:12]),
+		Payload:   data[binaryProtocol2HeaderSize:],
+	}, nil
+}
+
+// EncodeBinaryProtocol3 按 BinaryProtocol3 打包（网络字节序）
+//
+//	uint8 type | uint8 reserved | uint16 payload_size | payload
+func EncodeBinaryProtocol3(typ int, payload []byte) ([]byte, error) {
+	if len(payload) > 0xFFFF {
+		return nil, fmt.Errorf("%w: v3 payload too large (%d bytes)", ErrBinaryFrame, len(payload))
+	}
+	buf := make([]byte, binaryProtocol3HeaderSize+len(payload))
+	buf[0] = byte(typ)
+	buf[1] = 0
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(payload)))
+	copy(buf[binaryProtocol3HeaderSize:], payload)
+	return buf, nil
+}
+
+// DecodeBinaryProtocol3 解析 BinaryProtocol3 帧，payload 与 data 共享底层内存
+func DecodeBinaryProtocol3(data []byte) (BinaryFrame, error) {
+	if len(data) < binaryProtocol3HeaderSize {
+		return BinaryFrame{}, fmt.Errorf("%w: v3 frame too short (%d bytes)", ErrBinaryFrame, len(data))
+	}
+	size := binary.BigEndian.Uint16(data[2:4])
+	if int(size) != len(data)-binaryProtocol3HeaderSize {
+		return BinaryFrame{}, fmt.Errorf("%w: v3 payload_size=%d, actual=%d", ErrBinaryFrame, size, len(data)-binaryProtocol3HeaderSize)
+	}
+	return BinaryFrame{
+		Version: 3,
+		Type:    int(data[0]),
+		Payload: data[binaryProtocol3HeaderSize:],
+	}, nil
+}
@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 下行 UDP 抖动缓冲：在 Window 帧范围内按 seq 重排，按帧间隔匀速释放；
+// 等待超过窗口仍未到达的帧视为丢失，通过 onGap 通知上层做丢包隐藏。
+// 开始时及缓冲排空后先积攒 Prebuffer 帧（目标延迟）再释放，吸收到达时间抖动
+
+const (
+	defaultJitterWindow    = 3
+	defaultJitterPrebuffer = 2
+	defaultFrameDuration   = 60 * time.Millisecond
+	// seq 向前跳变超过该值（服务端重置序号或长时间中断）时重新同步
+	jitterResyncGap = 100
+	// 连续这么多个落后超过 jitterResyncGap 的帧才视为序号回退并重新同步，
+	// 单个迟到的旧包只按 Late 丢弃
+	jitterResyncStale = 8
+)
+
+// JitterStats 抖动缓冲统计
+type JitterStats struct {
+	Received  uint64 // 收到的有效帧
+	Released  uint64 // 已按序释放的帧
+	Reordered uint64 // 乱序到达但仍在窗口内被重排的帧
+	Late      uint64 // 到达时该序号已释放或判定丢失，被丢弃
+	Duplicate uint64 // 重复帧
+	Lost      uint64 // 超过窗口仍未到达，或重新同步时被丢弃的帧
+	Resyncs   uint64 // 序号跳变导致的重新同步次数
+	Buffered  int    // 当前缓冲帧数
+}
+
+type JitterBuffer struct {
+	window    int
+	prebuffer int
+	interval  time.Duration
+	onFrame   func(opus []byte)
+	onGap     func(lost int)
+
+	mu        sync.Mutex
+	frames    map[uint32][]byte
+	started   bool
+	buffering bool   // 积攒 prebuffer 帧期间不释放
+	next      uint32 // 下一个待释放的 seq
+	highest   uint32
+	waited    int // 当前缺失帧（或预缓冲）已等待的 tick 数
+	stale     int // 连续落后超过 jitterResyncGap 的帧数
+	stats     JitterStats
+}
+
+// NewJitterBuffer window<=0、frameDuration<=0 时使用默认值（3 帧、60ms）；预缓冲默认 2 帧
+func NewJitterBuffer(window int, frameDuration time.Duration, onFrame func(opus []byte), onGap func(lost int)) *JitterBuffer {
+	if window <= 0 {
+		window = defaultJitterWindow
+	}
+	if frameDuration <= 0 {
+		frameDuration = defaultFrameDuration
+	}
+	j := &JitterBuffer{window: window, interval: frameDuration, onFrame: onFrame, onGap: onGap, frames: make(map[uint32][]byte)}
+	j.SetPrebuffer(defaultJitterPrebuffer)
+	return j
+}
+
+// SetPrebuffer 设置目标延迟（帧数），在 1..window 之间；需在 Push 之前调用
+func (j *JitterBuffer) SetPrebuffer(frames int) {
+	if frames < 1 {
+		frames = 1
+	}
+	if frames > j.window {
+		frames = j.window
+	}
+	j.mu.Lock()
+	j.prebuffer = frames
+	j.mu.Unlock()
+}
+
+// Push 放入一帧；seq 按 uint32 回绕比较
+func (j *JitterBuffer) Push(seq uint32, opus []byte) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	d := int32(seq - j.next)
+	switch {
+	case !j.started:
+		j.resync(seq)
+	case d > jitterResyncGap:
+		j.stats.Resyncs++
+		j.resync(seq)
+	case d < -jitterResyncGap:
+		if j.stale++; j.stale < jitterResyncStale {
+			j.stats.Late++
+			return
+		}
+		j.stats.Resyncs++
+		j.resync(seq)
+	}
+	j.stale = 0
+	if seqBefore(seq, j.next) {
+		j.stats.Late++
+		return
+	}
+	if _, ok := j.frames[seq]; ok {
+		j.stats.Duplicate++
+		return
+	}
+	if seqBefore(seq, j.highest) {
+		j.stats.Reordered++
+	} else {
+		j.highest = seq
+	}
+	j.stats.Received++
+	j.frames[seq] = opus
+}
+
+// resync 以 seq 为新起点，尚未释放的帧计为丢失，调用方持有 mu
+func (j *JitterBuffer) resync(seq uint32) {
+	j.stats.Lost += uint64(len(j.frames))
+	j.started, j.buffering = true, true
+	j.next, j.highest, j.waited, j.stale = seq, seq, 0, 0
+	j.frames = make(map[uint32][]byte)
+}
+
+// Run 按帧间隔释放，直到 ctx 取消
+func (j *JitterBuffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.tick()
+		}
+	}
+}
+
+func (j *JitterBuffer) tick() {
+	var out [][]byte // nil 表示丢失的帧
+	j.mu.Lock()
+	if len(j.frames) == 0 {
+		// 排空后重新预缓冲，下一段音频重新建立目标延迟
+		j.buffering, j.waited = true, 0
+	} else if j.buffering {
+		// 凑够目标延迟，或等待超过窗口（短音频不足 prebuffer 帧）后开始释放
+		if int(j.highest-j.next)+1 >= j.prebuffer || j.waited >= j.window {
+			j.buffering, j.waited = false, 0
+		} else {
+			j.waited++
+		}
+	}
+	// 积压超过两倍窗口（服务端突发下发）时一次多释放几帧，避免延迟累积
+	for budget := 1; budget > 0 && len(j.frames) > 0 && !j.buffering; budget-- {
+		if f, ok := j.frames[j.next]; ok {
+			delete(j.frames, j.next)
+			j.next++
+			j.waited = 0
+			j.stats.Released++
+			out = append(out, f)
+		} else if int(j.highest-j.next) >= j.window || j.waited >= j.window {
+			// 缺失帧超出窗口：判定丢失并跳过，本次 tick 继续释放后续帧
+			j.next++
+			j.waited = 0
+			j.stats.Lost++
+			out = append(out, nil)
+			budget++
+		} else {
+			j.waited++
+		}
+		if len(j.frames) > 2*j.window {
+			budget++
+		}
+	}
+	j.mu.Unlock()
+
+	for i := 0; i < len(out); i++ {
+		if out[i] != nil {
+			if j.onFrame != nil {
+				j.onFrame(out[i])
+			}
+			continue
+		}
+		lost := 1
+		for i+1 < len(out) && out[i+1] == nil {
+			lost++
+			i++
+		}
+		if j.onGap != nil {
+			j.onGap(lost)
+		}
+	}
+}
+
+// Stats 返回统计
+func (j *JitterBuffer) Stats() JitterStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.stats
+	s.Buffered = len(j.frames)
+	return s
+}
+
+// seqBefore a 是否在 b 之前（考虑回绕）
+func seqBefore(a, b uint32) bool { return int32(a-b) < 0 }
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// jitterProbe 记录释放顺序：帧以其 seq 文本为内容，丢失记为 "gap<n>"
+type jitterProbe struct {
+	out []string
+}
+
+func newTestJitter(window, prebuffer int) (*JitterBuffer, *jitterProbe) {
+	p := &jitterProbe{}
+	j := NewJitterBuffer(window, 20*time.Millisecond,
+		func(opus []byte) { p.out = append(p.out, string(opus)) },
+		func(lost int) { p.out = append(p.out, fmt.Sprintf("gap%d", lost)) })
+	j.SetPrebuffer(prebuffer)
+	return j, p
+}
+
+func pushSeq(j *JitterBuffer, seqs ...uint32) {
+	for _, s := range seqs {
+		j.Push(s, []byte(fmt.Sprint(s)))
+	}
+}
+
+func ticks(j *JitterBuffer, n int) {
+	for i := 0; i < n; i++ {
+		j.tick()
+	}
+}
+
+func (p *jitterProbe) expect(t *testing.T, want ...string) {
+	t.Helper()
+	if fmt.Sprint(p.out) != fmt.Sprint(want) {
+		t.Fatalf("released %v, want %v", p.out, want)
+	}
+}
+
+func TestJitterPrebuffer(t *testing.T) {
+	j, p := newTestJitter(3, 2)
+	pushSeq(j, 10)
+	ticks(j, 1)
+	p.expect(t) // 未达目标延迟
+	pushSeq(j, 11)
+	ticks(j, 1)
+	p.expect(t, "10")
+	pushSeq(j, 12)
+	ticks(j, 2)
+	p.expect(t, "10", "11", "12")
+
+	// 排空后重新预缓冲；不足 prebuffer 的短音频等待超过窗口后也会释放
+	ticks(j, 1)
+	pushSeq(j, 13)
+	ticks(j, 3)
+	p.expect(t, "10", "11", "12")
+	ticks(j, 1)
+	p.expect(t, "10", "11", "12", "13")
+}
+
+func TestJitterReorderAndLoss(t *testing.T) {
+	j, p := newTestJitter(3, 1)
+	pushSeq(j, 0, 2, 1, 4, 5, 6, 7)
+	ticks(j, 8)
+	p.expect(t, "0", "1", "2", "gap1", "4", "5", "6", "7")
+	s := j.Stats()
+	if s.Reordered != 1 || s.Lost != 1 || s.Released != 7 || s.Buffered != 0 {
+		t.Errorf("stats %+v", s)
+	}
+
+	// 已判定丢失的帧迟到：丢弃
+	pushSeq(j, 3)
+	if s := j.Stats(); s.Late != 1 {
+		t.Errorf("late %d, want 1", s.Late)
+	}
+	pushSeq(j, 8, 8)
+	if s := j.Stats(); s.Duplicate != 1 {
+		t.Errorf("duplicate %d, want 1", s.Duplicate)
+	}
+}
+
+func TestJitterStalePacketKeepsBuffer(t *testing.T) {
+	j, p := newTestJitter(3, 2)
+	pushSeq(j, 500, 501, 502)
+	// 很久以前的包单独迟到：不应重新同步、丢弃缓冲或让 next 后退
+	pushSeq(j, 10)
+	pushSeq(j, 503)
+	ticks(j, 4)
+	p.expect(t, "500", "501", "502", "503")
+	s := j.Stats()
+	if s.Late != 1 || s.Lost != 0 || s.Resyncs != 0 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestJitterSustainedBackwardJumpResyncs(t *testing.T) {
+	j, p := newTestJitter(3, 1)
+	pushSeq(j, 1000, 1001)
+	ticks(j, 1)
+	p.expect(t, "1000")
+	// 服务端重置序号：持续收到远小于 next 的帧后以新序号重新同步
+	for s := uint32(1); s <= jitterResyncStale; s++ {
+		pushSeq(j, s)
+	}
+	st := j.Stats()
+	if st.Resyncs != 1 || st.Late != jitterResyncStale-1 || st.Lost != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+	pushSeq(j, jitterResyncStale+1)
+	ticks(j, 2)
+	p.expect(t, "1000", fmt.Sprint(jitterResyncStale), fmt.Sprint(jitterResyncStale+1))
+}
+
+func TestJitterForwardJumpResyncs(t *testing.T) {
+	j, p := newTestJitter(3, 1)
+	pushSeq(j, 5, 6)
+	ticks(j, 1)
+	pushSeq(j, 5000, 5001)
+	ticks(j, 2)
+	p.expect(t, "5", "5000", "5001")
+	if s := j.Stats(); s.Resyncs != 1 || s.Lost != 1 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestJitterSeqWraparound(t *testing.T) {
+	j, p := newTestJitter(3, 1)
+	pushSeq(j, 0xfffffffe, 0, 0xffffffff, 1)
+	ticks(j, 4)
+	p.expect(t, "4294967294", "4294967295", "0", "1")
+	if s := j.Stats(); s.Reordered != 1 || s.Resyncs != 0 {
+		t.Errorf("stats %+v", s)
+	}
+}
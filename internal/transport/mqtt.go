@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -21,8 +22,11 @@ type MQTTControl struct {
 	SubscribeTopic string
 
 	Handlers Handlers
+	// OnReconnected 在 paho 自动重连成功并重新订阅后调用（首次连接不触发）
+	OnReconnected func()
 
-	client mqtt.Client
+	client    mqtt.Client
+	connected int32
 }
 
 func NewMQTTControl(broker, clientID, username, password, pubTopic, subTopic string, keepAliveSec int, handlers Handlers) *MQTTControl {
@@ -37,6 +41,9 @@ func (m *MQTTControl) Open(ctx context.Context, headers map[string]string) error
 	if m.KeepAlive > 0 { opts.SetKeepAlive(m.KeepAlive) }
 	opts.SetAutoReconnect(true)
 	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) { if m.Handlers.OnError != nil { m.Handlers.OnError(context.Background(), err) }; if m.Handlers.OnClosed != nil { m.Handlers.OnClosed() } })
+	// OnConnect 在 paho 的独立 goroutine 中执行：等首次订阅完成后再返回，
+	// 否则紧随其后的 hello 应答可能在订阅生效前到达而丢失
+	subscribed := make(chan struct{})
 	opts.SetOnConnectHandler(func(c mqtt.Client) {
 		// 仅当订阅主题有效时订阅
 		topic := strings.TrimSpace(m.SubscribeTopic)
@@ -46,11 +53,25 @@ func (m *MQTTControl) Open(ctx context.Context, headers map[string]string) error
 				if m.Handlers.OnError != nil { m.Handlers.OnError(context.Background(), token.Error()) }
 			}
 		}
+		if atomic.CompareAndSwapInt32(&m.connected, 0, 1) {
+			close(subscribed)
+		} else if m.OnReconnected != nil {
+			// 回调中可能需要收发消息，不能阻塞 paho 的连接处理
+			go m.OnReconnected()
+		}
 	})
 	m.client = mqtt.NewClient(opts)
 	token := m.client.Connect()
 	if !token.WaitTimeout(20*time.Second) { return fmt.Errorf("mqtt connect timeout") }
-	return token.Error()
+	if err := token.Error(); err != nil { return err }
+	select {
+	case <-subscribed:
+		return nil
+	case <-time.After(10*time.Second):
+		return fmt.Errorf("mqtt subscribe timeout")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (m *MQTTControl) SendText(ctx context.Context, data []byte) error {
@@ -16,6 +16,10 @@ import (
 
 type UDPAudioHandlers struct {
 	OnAudioFrame func(ctx context.Context, opus []byte)
+	// OnPacket 收到并解密后、进入抖动缓冲前调用（到达顺序，用于线级录制）
+	OnPacket     func(ctx context.Context, seq uint32, opus []byte)
+	// OnAudioGap 抖动缓冲判定丢失 lost 帧时调用，可用于丢包隐藏（PLC）
+	OnAudioGap   func(ctx context.Context, lost int)
 	OnError      func(ctx context.Context, err error)
 	OnClosed     func()
 }
@@ -28,13 +32,18 @@ type UDPAudio struct {
 
 	Handlers UDPAudioHandlers
 
+	// 下行抖动缓冲参数，需在 Open 之前设置；零值使用默认值（3 帧窗口、2 帧预缓冲、60ms 帧长）
+	JitterWindow    int
+	JitterPrebuffer int
+	FrameDuration   time.Duration
+
 	conn       *net.UDPConn
 	remote     *net.UDPAddr
 	key        []byte
 	nonce      []byte
 	ssrc       uint32
 	localSeq   uint32
-	remoteSeq  uint32
+	jitter     *JitterBuffer
 	closedOnce sync.Once
 	mu         sync.Mutex
 	ctx        context.Context
@@ -53,13 +62,24 @@ func (u *UDPAudio) Open() error {
 	if len(u.key) != 16 || len(u.nonce) != 16 { return errors.New("AES-CTR requires 128-bit key and nonce") }
 	u.remote, err = net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", u.RemoteHost, u.RemotePort)); if err != nil { return err }
 	u.conn, err = net.DialUDP("udp", nil, u.remote); if err != nil { return err }
-	u.ssrc = rand.Uint32(); u.localSeq = 1; u.remoteSeq = 0
+	u.ssrc = rand.Uint32(); u.localSeq = 1
+	u.jitter = NewJitterBuffer(u.JitterWindow, u.FrameDuration,
+		func(opus []byte) { if u.Handlers.OnAudioFrame != nil { u.Handlers.OnAudioFrame(context.Background(), opus) } },
+		func(lost int) { if u.Handlers.OnAudioGap != nil { u.Handlers.OnAudioGap(context.Background(), lost) } })
+	if u.JitterPrebuffer > 0 { u.jitter.SetPrebuffer(u.JitterPrebuffer) }
+	go u.jitter.Run(u.ctx)
 	go u.readLoop()
 	return nil
 }
 
 func (u *UDPAudio) Close() error { u.closedOnce.Do(func(){ u.cancel(); if u.conn != nil { _ = u.conn.Close() }; if u.Handlers.OnClosed != nil { u.Handlers.OnClosed() } }); return nil }
 
+// JitterStats 返回下行抖动缓冲统计
+func (u *UDPAudio) JitterStats() JitterStats {
+	if u.jitter == nil { return JitterStats{} }
+	return u.jitter.Stats()
+}
+
 func deriveIV(nonce []byte, ts uint32, seq uint32) []byte {
 	iv := make([]byte, 16)
 	copy(iv, nonce)
@@ -69,6 +89,39 @@ func deriveIV(nonce []byte, ts uint32, seq uint32) []byte {
 	return iv
 }
 
+// UDPAudioHeaderSize 音频包头长度：type|flags|payload_len|ssrc|timestamp|sequence
+const UDPAudioHeaderSize = 16
+
+// UDPAudioHeader 音频包头（docs/mqtt-udp.md 4.2.1，网络字节序）
+type UDPAudioHeader struct {
+	Type       byte
+	Flags      byte
+	PayloadLen uint16
+	SSRC       uint32
+	Timestamp  uint32
+	Seq        uint32
+}
+
+// ParseUDPAudioPacket 解析包头并返回加密负载（不含 payload_len 之后的多余字节）
+func ParseUDPAudioPacket(p []byte) (UDPAudioHeader, []byte, error) {
+	if len(p) < UDPAudioHeaderSize { return UDPAudioHeader{}, nil, errors.New("udp packet too short") }
+	h := UDPAudioHeader{
+		Type: p[0], Flags: p[1], PayloadLen: binary.BigEndian.Uint16(p[2:4]),
+		SSRC: binary.BigEndian.Uint32(p[4:8]), Timestamp: binary.BigEndian.Uint32(p[8:12]), Seq: binary.BigEndian.Uint32(p[12:16]),
+	}
+	if UDPAudioHeaderSize+int(h.PayloadLen) > len(p) { return h, nil, errors.New("udp payload len mismatch") }
+	return h, p[UDPAudioHeaderSize : UDPAudioHeaderSize+int(h.PayloadLen)], nil
+}
+
+// DecryptUDPAudio 按包头的 timestamp/sequence 派生 IV 做 AES-CTR 解密（加密为同一运算），返回新切片
+func DecryptUDPAudio(key, nonce []byte, h UDPAudioHeader, payload []byte) ([]byte, error) {
+	if len(nonce) != 16 { return nil, errors.New("AES-CTR requires 128-bit nonce") }
+	block, err := aes.NewCipher(key); if err != nil { return nil, err }
+	plain := make([]byte, len(payload))
+	cipher.NewCTR(block, deriveIV(nonce, h.Timestamp, h.Seq)).XORKeyStream(plain, payload)
+	return plain, nil
+}
+
 func (u *UDPAudio) SendOpusFrame(opus []byte) error {
 	u.mu.Lock(); defer u.mu.Unlock()
 	if u.conn == nil { return errors.New("udp not open") }
@@ -103,22 +156,13 @@ func (u *UDPAudio) readLoop() {
 		select { case <-u.ctx.Done(): return; default: }
 		n, _, err := u.conn.ReadFromUDP(buf)
 		if err != nil { if u.ctx.Err() != nil { return }; if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), err) }; return }
-		if n < 16 { if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), errors.New("udp packet too short")) }; continue }
-		p := buf[:n]
-		if p[0] != 0x01 { continue }
-		payloadLen := binary.BigEndian.Uint16(p[2:4])
-		_ = binary.BigEndian.Uint32(p[4:8])
-		ts := binary.BigEndian.Uint32(p[8:12])
-		seq := binary.BigEndian.Uint32(p[12:16])
-		if int(16+payloadLen) > len(p) { if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), errors.New("udp payload len mismatch")) }; continue }
-		cipherPayload := p[16 : 16+payloadLen]
-		block, err := aes.NewCipher(u.key); if err != nil { if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), err) }; continue }
-		iv := deriveIV(u.nonce, ts, seq)
-		stream := cipher.NewCTR(block, iv)
-		plain := make([]byte, len(cipherPayload))
-		stream.XORKeyStream(plain, cipherPayload)
-		if u.remoteSeq != 0 && seq <= u.remoteSeq { continue }
-		u.remoteSeq = seq
-		if u.Handlers.OnAudioFrame != nil { u.Handlers.OnAudioFrame(context.Background(), plain) }
+		if n >= UDPAudioHeaderSize && buf[0] != 0x01 { continue }
+		h, cipherPayload, err := ParseUDPAudioPacket(buf[:n])
+		if err != nil { if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), err) }; continue }
+		plain, err := DecryptUDPAudio(u.key, u.nonce, h, cipherPayload)
+		if err != nil { if u.Handlers.OnError != nil { u.Handlers.OnError(context.Background(), err) }; continue }
+		if u.Handlers.OnPacket != nil { u.Handlers.OnPacket(context.Background(), h.Seq, plain) }
+		// 乱序/迟到/重复由抖动缓冲处理
+		u.jitter.Push(h.Seq, plain)
 	}
 }
@@ -2,6 +2,7 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,16 +13,63 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// gorilla/websocket 不允许并发写：所有数据帧经由单个 writeLoop 发出，
+// 控制消息（JSON 文本）队列优先于有界的音频队列
+
+var (
+	ErrConnClosed   = errors.New("connection closed")
+	ErrAudioDropped = errors.New("audio frame dropped: send queue full")
+)
+
+const (
+	defaultCtrlQueueSize    = 64
+	defaultAudioQueueSize   = 50 // 60ms 帧约 3 秒
+	defaultWriteTimeout     = 10 * time.Second
+	defaultAudioWaitTimeout = 200 * time.Millisecond
+)
+
+// WriteStats 发送统计
+type WriteStats struct {
+	TextSent     uint64 // 已写出的文本帧
+	BinarySent   uint64 // 已写出的二进制帧
+	AudioDropped uint64 // 音频队列满且等待超时后丢弃的帧
+	BackPressure uint64 // 音频入队时遇到队列已满（需等待）的次数
+	Expired      uint64 // 出队时 ctx 已取消/超时而未写出的消息
+	AudioQueued  int    // 当前音频队列长度
+}
+
+type writeReq struct {
+	msgType  int
+	data     []byte
+	ctx      context.Context
+	deadline time.Time
+	queue    chan *writeReq
+	done     chan error // 控制消息等待写出结果；音频为 nil
+}
+
 type WebsocketTransport struct {
 	URL          string
 	Handlers     Handlers
 	Subprotocols []string
 
+	// 发送队列参数，需在 Open 之前设置；零值使用默认值
+	AudioQueueSize   int
+	WriteTimeout     time.Duration // ctx 未设置 deadline 时的单次写超时
+	AudioWaitTimeout time.Duration // 音频队列满时的最长等待（ctx 未设置 deadline 时）
+
 	conn     *websocket.Conn
 	mu       sync.RWMutex
 	closed   int32
 	stopCh   chan struct{}
 	stopOnce sync.Once
+	ctrlQ    chan *writeReq
+	audioQ   chan *writeReq
+
+	textSent     uint64
+	binarySent   uint64
+	audioDropped uint64
+	backPressure uint64
+	expired      uint64
 
 	// 心跳和连接监控
 	lastPong     int64
@@ -37,6 +85,10 @@ func NewWebsocketTransport(url string, handlers Handlers) *WebsocketTransport {
 		stopCh:       make(chan struct{}),
 		pingInterval: 20 * time.Second,
 		pongTimeout:  10 * time.Second,
+
+		AudioQueueSize:   defaultAudioQueueSize,
+		WriteTimeout:     defaultWriteTimeout,
+		AudioWaitTimeout: defaultAudioWaitTimeout,
 	}
 }
 
@@ -85,6 +137,12 @@ func (w *WebsocketTransport) Open(ctx context.Context, headers map[string]string
 	w.conn = conn
 	atomic.StoreInt32(&w.closed, 0)
 	w.stopCh = make(chan struct{})
+	audioSize := w.AudioQueueSize
+	if audioSize <= 0 {
+		audioSize = defaultAudioQueueSize
+	}
+	w.ctrlQ = make(chan *writeReq, defaultCtrlQueueSize)
+	w.audioQ = make(chan *writeReq, audioSize)
 
 	// 设置连接参数
 	w.conn.SetReadLimit(10 * 1024 * 1024) // 10MB
@@ -105,6 +163,7 @@ func (w *WebsocketTransport) Open(ctx context.Context, headers map[string]string
 	})
 
 	// 启动各种循环
+	go w.writeLoop(conn, w.stopCh, w.ctrlQ, w.audioQ)
 	go w.readLoop()
 	go w.pingLoop()
 	go w.connectionMonitor()
@@ -240,36 +299,178 @@ func (w *WebsocketTransport) readLoop() {
 	}
 }
 
-func (w *WebsocketTransport) SendText(ctx context.Context, data []byte) error {
-	if atomic.LoadInt32(&w.closed) == 1 {
-		return fmt.Errorf("connection closed")
+// writeLoop 唯一的写协程：每次优先取控制消息，其次取音频
+func (w *WebsocketTransport) writeLoop(conn *websocket.Conn, stopCh chan struct{}, ctrlQ, audioQ chan *writeReq) {
+	for {
+		var req *writeReq
+		select {
+		case <-stopCh:
+			return
+		case req = <-ctrlQ:
+		default:
+			select {
+			case <-stopCh:
+				return
+			case req = <-ctrlQ:
+			case req = <-audioQ:
+			}
+		}
+
+		if err := req.ctx.Err(); err != nil {
+			atomic.AddUint64(&w.expired, 1)
+			req.finish(err)
+			continue
+		}
+		_ = conn.SetWriteDeadline(req.deadline)
+		err := conn.WriteMessage(req.msgType, req.data)
+		if err == nil {
+			if req.msgType == websocket.TextMessage {
+				atomic.AddUint64(&w.textSent, 1)
+			} else {
+				atomic.AddUint64(&w.binarySent, 1)
+			}
+		}
+		req.finish(err)
+		if err != nil {
+			// 写失败（含写超时）后连接已不可用
+			if atomic.LoadInt32(&w.closed) == 0 && w.Handlers.OnError != nil {
+				w.Handlers.OnError(context.Background(), fmt.Errorf("write message failed: %v", err))
+			}
+			w.Close()
+			return
+		}
 	}
+}
 
+func (r *writeReq) finish(err error) {
+	if r.done != nil {
+		r.done <- err
+	}
+}
+
+func (w *WebsocketTransport) newReq(ctx context.Context, msgType int, data []byte) (*writeReq, chan struct{}, error) {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return nil, nil, ErrConnClosed
+	}
 	w.mu.RLock()
-	conn := w.conn
+	conn, stopCh, queue := w.conn, w.stopCh, w.ctrlQ
+	if msgType == websocket.BinaryMessage {
+		queue = w.audioQ
+	}
 	w.mu.RUnlock()
-
 	if conn == nil {
-		return fmt.Errorf("connection not established")
+		return nil, nil, fmt.Errorf("connection not established")
 	}
-
-	return conn.WriteMessage(websocket.TextMessage, data)
+	timeout := w.WriteTimeout
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return &writeReq{msgType: msgType, data: data, ctx: ctx, deadline: deadline, queue: queue}, stopCh, nil
 }
 
-func (w *WebsocketTransport) SendBinary(ctx context.Context, data []byte) error {
-	if atomic.LoadInt32(&w.closed) == 1 {
-		return fmt.Errorf("connection closed")
+// SendText 经控制队列发送文本帧，阻塞至写出完成、ctx 取消或连接关闭
+func (w *WebsocketTransport) SendText(ctx context.Context, data []byte) error {
+	req, stopCh, err := w.newReq(ctx, websocket.TextMessage, data)
+	if err != nil {
+		return err
 	}
+	return w.sendAndWait(req, stopCh)
+}
 
+// SendTextAfterAudio 经音频队列发送文本帧，保证在已入队的音频之后写出（如 listen stop），
+// 阻塞至写出完成、ctx 取消或连接关闭
+func (w *WebsocketTransport) SendTextAfterAudio(ctx context.Context, data []byte) error {
+	req, stopCh, err := w.newReq(ctx, websocket.TextMessage, data)
+	if err != nil {
+		return err
+	}
 	w.mu.RLock()
-	conn := w.conn
+	req.queue = w.audioQ
 	w.mu.RUnlock()
+	return w.sendAndWait(req, stopCh)
+}
 
-	if conn == nil {
-		return fmt.Errorf("connection not established")
+func (w *WebsocketTransport) sendAndWait(req *writeReq, stopCh chan struct{}) error {
+	ctx := req.ctx
+	req.done = make(chan error, 1)
+	select {
+	case req.queue <- req:
+	case <-stopCh:
+		return ErrConnClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-stopCh:
+		// 对端收到后立即关闭（如 goodbye）时两者可能同时就绪，以写出结果为准
+		select {
+		case err := <-req.done:
+			return err
+		default:
+			return ErrConnClosed
+		}
+	case <-ctx.Done():
+		// 已入队的消息由 writeLoop 出队时丢弃
+		return ctx.Err()
+	}
+}
+
+// SendBinary 将音频帧放入有界队列后立即返回；队列满时最多等待 ctx deadline
+// 或 AudioWaitTimeout，超时则丢弃该帧并返回 ErrAudioDropped
+func (w *WebsocketTransport) SendBinary(ctx context.Context, data []byte) error {
+	req, stopCh, err := w.newReq(ctx, websocket.BinaryMessage, data)
+	if err != nil {
+		return err
+	}
+	select {
+	case req.queue <- req:
+		return nil
+	default:
 	}
 
-	return conn.WriteMessage(websocket.BinaryMessage, data)
+	atomic.AddUint64(&w.backPressure, 1)
+	wait := w.AudioWaitTimeout
+	if wait <= 0 {
+		wait = defaultAudioWaitTimeout
+	}
+	if d, ok := ctx.Deadline(); ok {
+		wait = time.Until(d)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case req.queue <- req:
+		return nil
+	case <-stopCh:
+		return ErrConnClosed
+	case <-ctx.Done():
+		atomic.AddUint64(&w.audioDropped, 1)
+		return ctx.Err()
+	case <-timer.C:
+		atomic.AddUint64(&w.audioDropped, 1)
+		return ErrAudioDropped
+	}
+}
+
+// Stats 返回发送统计
+func (w *WebsocketTransport) Stats() WriteStats {
+	w.mu.RLock()
+	queued := len(w.audioQ)
+	w.mu.RUnlock()
+	return WriteStats{
+		TextSent:     atomic.LoadUint64(&w.textSent),
+		BinarySent:   atomic.LoadUint64(&w.binarySent),
+		AudioDropped: atomic.LoadUint64(&w.audioDropped),
+		BackPressure: atomic.LoadUint64(&w.backPressure),
+		Expired:      atomic.LoadUint64(&w.expired),
+		AudioQueued:  queued,
+	}
 }
 
 func (w *WebsocketTransport) Close() error {
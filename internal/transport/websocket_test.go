@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsFrame struct {
+	msgType int
+	data    string
+}
+
+// newIdleTransport 连接到记录收到帧的测试服务端，但不启动 writeLoop，
+// 以便先填充队列再由 start 开始写出，使出队顺序可确定
+func newIdleTransport(t *testing.T, audioSize int) (w *WebsocketTransport, frames <-chan wsFrame, start func()) {
+	t.Helper()
+	ch := make(chan wsFrame, 64)
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			ch <- wsFrame{typ, string(data)}
+		}
+	}))
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	w = NewWebsocketTransport(url, Handlers{})
+	w.conn = conn
+	w.ctrlQ = make(chan *writeReq, defaultCtrlQueueSize)
+	w.audioQ = make(chan *writeReq, audioSize)
+	t.Cleanup(func() {
+		w.Close()
+		srv.Close()
+	})
+	return w, ch, func() { go w.writeLoop(conn, w.stopCh, w.ctrlQ, w.audioQ) }
+}
+
+func readFrames(t *testing.T, frames <-chan wsFrame, n int) []wsFrame {
+	t.Helper()
+	var out []wsFrame
+	for len(out) < n {
+		select {
+		case f := <-frames:
+			out = append(out, f)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d frames, want %d", len(out), n)
+		}
+	}
+	return out
+}
+
+// barrier 在音频队列末尾发送一条文本并等待写出：此前入队的帧均已计入 Stats
+func barrier(t *testing.T, w *WebsocketTransport) {
+	t.Helper()
+	if err := w.SendTextAfterAudio(context.Background(), []byte("barrier")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteLoopControlBeforeAudio(t *testing.T) {
+	w, frames, start := newIdleTransport(t, 8)
+	ctx := context.Background()
+	for _, a := range []string{"a1", "a2", "a3"} {
+		if err := w.SendBinary(ctx, []byte(a)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// SendText 与 SendTextAfterAudio 阻塞至写出，在 writeLoop 启动前入队
+	errs := make(chan error, 2)
+	go func() { errs <- w.SendTextAfterAudio(ctx, []byte("stop")) }()
+	for len(w.audioQ) < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() { errs <- w.SendText(ctx, []byte("ctrl")) }()
+	for len(w.ctrlQ) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	start()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 控制消息插到已排队的音频之前；listen stop 类消息排在其前的音频之后
+	want := []wsFrame{
+		{websocket.TextMessage, "ctrl"},
+		{websocket.BinaryMessage, "a1"},
+		{websocket.BinaryMessage, "a2"},
+		{websocket.BinaryMessage, "a3"},
+		{websocket.TextMessage, "stop"},
+	}
+	got := readFrames(t, frames, len(want))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frames %v, want %v", got, want)
+		}
+	}
+	if s := w.Stats(); s.TextSent != 2 || s.BinarySent != 3 || s.AudioQueued != 0 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestSendBinaryDropsWhenQueueFull(t *testing.T) {
+	w, frames, start := newIdleTransport(t, 2)
+	w.AudioWaitTimeout = 20 * time.Millisecond
+	ctx := context.Background()
+	for _, a := range []string{"a1", "a2"} {
+		if err := w.SendBinary(ctx, []byte(a)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	begin := time.Now()
+	if err := w.SendBinary(ctx, []byte("a3")); !errors.Is(err, ErrAudioDropped) {
+		t.Fatalf("third frame: %v, want ErrAudioDropped", err)
+	}
+	if d := time.Since(begin); d < w.AudioWaitTimeout {
+		t.Errorf("dropped after %v, want to wait %v", d, w.AudioWaitTimeout)
+	}
+	if s := w.Stats(); s.AudioDropped != 1 || s.BackPressure != 1 || s.AudioQueued != 2 || s.BinarySent != 0 {
+		t.Errorf("stats while full %+v", s)
+	}
+
+	start()
+	got := readFrames(t, frames, 2)
+	if got[0].data != "a1" || got[1].data != "a2" {
+		t.Errorf("frames %v", got)
+	}
+	// 排空后可再次发送
+	if err := w.SendBinary(ctx, []byte("a4")); err != nil {
+		t.Fatal(err)
+	}
+	barrier(t, w)
+	if s := w.Stats(); s.BinarySent != 3 || s.AudioDropped != 1 {
+		t.Errorf("stats after drain %+v", s)
+	}
+}
+
+func TestWriteLoopSkipsExpired(t *testing.T) {
+	w, frames, start := newIdleTransport(t, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := w.SendBinary(ctx, []byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := w.SendBinary(context.Background(), []byte("fresh")); err != nil {
+		t.Fatal(err)
+	}
+	start()
+	if got := readFrames(t, frames, 1); got[0].data != "fresh" {
+		t.Errorf("frames %v, want only fresh", got)
+	}
+	barrier(t, w)
+	if s := w.Stats(); s.Expired != 1 || s.BinarySent != 1 {
+		t.Errorf("stats %+v", s)
+	}
+}
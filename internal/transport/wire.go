@@ -0,0 +1,39 @@
+package transport
+
+import "context"
+
+// 线级收发方向
+const (
+	WireUp   = "up"   // 客户端 → 服务端
+	WireDown = "down" // 服务端 → 客户端
+)
+
+// WireFrame 一个线级帧：WebSocket 文本/二进制帧（二进制为协议封装后的原始字节）、
+// MQTT 消息，或 UDP 音频帧（加解密前的 Opus 负载）
+type WireFrame struct {
+	Dir     string // WireUp / WireDown
+	Channel string // ws / mqtt / udp
+	Binary  bool
+	Data    []byte // 回调返回后可能被复用，需要保留时自行拷贝
+}
+
+// Tap 返回先把收到的帧交给 fn、再调用原回调的 Handlers；fn 为 nil 时原样返回
+func (h Handlers) Tap(channel string, fn func(WireFrame)) Handlers {
+	if fn == nil {
+		return h
+	}
+	onText, onBinary := h.OnText, h.OnBinary
+	h.OnText = func(ctx context.Context, text []byte) {
+		fn(WireFrame{Dir: WireDown, Channel: channel, Data: text})
+		if onText != nil {
+			onText(ctx, text)
+		}
+	}
+	h.OnBinary = func(ctx context.Context, data []byte) {
+		fn(WireFrame{Dir: WireDown, Channel: channel, Binary: true, Data: data})
+		if onBinary != nil {
+			onBinary(ctx, data)
+		}
+	}
+	return h
+}
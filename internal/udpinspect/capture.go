@@ -0,0 +1,295 @@
+// Package udpinspect 离线分析 MQTT+UDP 音频抓包：读取 pcap/pcapng 中的 UDP 数据报，
+// 按 docs/mqtt-udp.md 第 4 节解析 16 字节包头并用会话 key/nonce 解密，
+// 统计丢包、乱序、重复、时间戳漂移与 SSRC 变化，解密后的 Opus 可导出为 Ogg
+package udpinspect
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"time"
+)
+
+// Datagram 抓包中的一个 UDP 数据报
+type Datagram struct {
+	Time    time.Time
+	Src     netip.AddrPort
+	Dst     netip.AddrPort
+	Payload []byte
+}
+
+// 支持的链路层类型（LINKTYPE_*）
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// ReadCapture 读取 pcap 或 pcapng 文件中的全部 UDP 数据报（按文件顺序）；
+// 非 UDP、IP 分片与不支持的链路层类型被跳过
+func ReadCapture(r io.Reader) ([]Datagram, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errors.New("capture too short")
+	}
+	switch binary.LittleEndian.Uint32(data) {
+	case 0x0A0D0D0A:
+		return readPcapng(data)
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1:
+		return readPcap(data)
+	}
+	return nil, errors.New("not a pcap or pcapng capture")
+}
+
+func readPcap(data []byte) ([]Datagram, error) {
+	if len(data) < 24 {
+		return nil, errors.New("pcap: short global header")
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	magic := bo.Uint32(data)
+	if magic == 0xd4c3b2a1 || magic == 0x4d3cb2a1 {
+		bo = binary.BigEndian
+		magic = bo.Uint32(data)
+	}
+	fracUnit := time.Microsecond
+	if magic == 0xa1b23c4d {
+		fracUnit = time.Nanosecond
+	}
+	link := int(bo.Uint32(data[20:24]) & 0xFFFF)
+	var out []Datagram
+	for off := 24; off+16 <= len(data); {
+		sec, frac := bo.Uint32(data[off:]), bo.Uint32(data[off+4:])
+		capLen := int(bo.Uint32(data[off+8:]))
+		off += 16
+		if capLen > len(data)-off {
+			// 抓包被截断，保留已读部分
+			break
+		}
+		ts := time.Unix(int64(sec), int64(frac)*int64(fracUnit))
+		if d, ok := decodeFrame(link, data[off:off+capLen], ts); ok {
+			out = append(out, d)
+		}
+		off += capLen
+	}
+	return out, nil
+}
+
+type pcapngIface struct {
+	link int
+	tps  uint64 // 每秒时间戳刻度数
+}
+
+func readPcapng(data []byte) ([]Datagram, error) {
+	var (
+		bo     binary.ByteOrder = binary.LittleEndian
+		ifaces []pcapngIface
+		out    []Datagram
+	)
+	for off := 0; off+12 <= len(data); {
+		typ := binary.LittleEndian.Uint32(data[off:])
+		if typ == 0x0A0D0D0A {
+			// 节头块：按字节序标记确定本节字节序，接口编号重新开始
+			switch binary.LittleEndian.Uint32(data[off+8:]) {
+			case 0x1A2B3C4D:
+				bo = binary.LittleEndian
+			case 0x4D3C2B1A:
+				bo = binary.BigEndian
+			default:
+				return nil, errors.New("pcapng: bad byte-order magic")
+			}
+			ifaces = ifaces[:0]
+		} else {
+			typ = bo.Uint32(data[off:])
+		}
+		blockLen := int(bo.Uint32(data[off+4:]))
+		if blockLen < 12 || blockLen%4 != 0 || blockLen > len(data)-off {
+			if len(out) > 0 || off > 0 {
+				break // 末尾块残缺
+			}
+			return nil, fmt.Errorf("pcapng: bad block length %d", blockLen)
+		}
+		body := data[off+8 : off+blockLen-4]
+		switch typ {
+		case 1: // 接口描述块
+			if len(body) >= 8 {
+				ifaces = append(ifaces, pcapngIface{link: int(bo.Uint16(body)), tps: tsResolution(bo, body[8:])})
+			}
+		case 6: // 增强包块
+			if len(body) >= 20 {
+				id := int(bo.Uint32(body))
+				ticks := uint64(bo.Uint32(body[4:]))<<32 | uint64(bo.Uint32(body[8:]))
+				capLen := int(bo.Uint32(body[12:]))
+				if id < len(ifaces) && capLen <= len(body)-20 {
+					if d, ok := decodeFrame(ifaces[id].link, body[20:20+capLen], ifaceTime(ifaces[id], ticks)); ok {
+						out = append(out, d)
+					}
+				}
+			}
+		case 3: // 简单包块：无时间戳，属于接口 0
+			if len(body) >= 4 && len(ifaces) > 0 {
+				pkt := body[4:]
+				if n := int(bo.Uint32(body)); n < len(pkt) {
+					pkt = pkt[:n]
+				}
+				if d, ok := decodeFrame(ifaces[0].link, pkt, time.Time{}); ok {
+					out = append(out, d)
+				}
+			}
+		case 2: // 旧式包块
+			if len(body) >= 20 {
+				id := int(bo.Uint16(body))
+				ticks := uint64(bo.Uint32(body[4:]))<<32 | uint64(bo.Uint32(body[8:]))
+				capLen := int(bo.Uint32(body[12:]))
+				if id < len(ifaces) && capLen <= len(body)-20 {
+					if d, ok := decodeFrame(ifaces[id].link, body[20:20+capLen], ifaceTime(ifaces[id], ticks)); ok {
+						out = append(out, d)
+					}
+				}
+			}
+		}
+		off += blockLen
+	}
+	return out, nil
+}
+
+// tsResolution 解析接口描述块选项中的 if_tsresol，返回每秒刻度数，默认微秒
+func tsResolution(bo binary.ByteOrder, opts []byte) uint64 {
+	for len(opts) >= 4 {
+		code, n := bo.Uint16(opts), int(bo.Uint16(opts[2:]))
+		if code == 0 || 4+n > len(opts) {
+			break
+		}
+		if code == 9 && n >= 1 {
+			v := opts[4]
+			if v&0x80 != 0 {
+				if v&0x7F < 64 {
+					return 1 << (v & 0x7F)
+				}
+				break
+			}
+			if v > 19 {
+				break
+			}
+			tps := uint64(1)
+			for i := byte(0); i < v; i++ {
+				tps *= 10
+			}
+			return tps
+		}
+		opts = opts[4+(n+3)&^3:]
+	}
+	return 1e6
+}
+
+func ifaceTime(ifc pcapngIface, ticks uint64) time.Time {
+	hi, lo := bits.Mul64(ticks%ifc.tps, 1e9)
+	ns, _ := bits.Div64(hi, lo, ifc.tps)
+	return time.Unix(int64(ticks/ifc.tps), int64(ns))
+}
+
+// decodeFrame 剥离链路层与 IP 头，返回 UDP 数据报
+func decodeFrame(link int, p []byte, ts time.Time) (Datagram, bool) {
+	var ethType uint16
+	switch link {
+	case linkEthernet:
+		if len(p) < 14 {
+			return Datagram{}, false
+		}
+		ethType, p = binary.BigEndian.Uint16(p[12:]), p[14:]
+		for (ethType == 0x8100 || ethType == 0x88a8) && len(p) >= 4 {
+			ethType, p = binary.BigEndian.Uint16(p[2:]), p[4:]
+		}
+	case linkNull, linkLoop:
+		if len(p) < 4 {
+			return Datagram{}, false
+		}
+		// 地址族为主机字节序（LOOP 为网络字节序），两种都试
+		fam := binary.LittleEndian.Uint32(p)
+		if fam > 0xFFFF {
+			fam = binary.BigEndian.Uint32(p)
+		}
+		ethType, p = 0x0800, p[4:]
+		if fam == 24 || fam == 28 || fam == 30 {
+			ethType = 0x86DD
+		}
+	case linkSLL:
+		if len(p) < 16 {
+			return Datagram{}, false
+		}
+		ethType, p = binary.BigEndian.Uint16(p[14:]), p[16:]
+	case linkSLL2:
+		if len(p) < 20 {
+			return Datagram{}, false
+		}
+		ethType, p = binary.BigEndian.Uint16(p), p[20:]
+	case linkRaw, linkIPv4, linkIPv6:
+		if len(p) == 0 {
+			return Datagram{}, false
+		}
+		ethType = 0x0800
+		if p[0]>>4 == 6 {
+			ethType = 0x86DD
+		}
+	default:
+		return Datagram{}, false
+	}
+
+	var src, dst netip.Addr
+	switch ethType {
+	case 0x0800:
+		if len(p) < 20 || p[0]>>4 != 4 {
+			return Datagram{}, false
+		}
+		ihl := int(p[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(p[2:]))
+		frag := binary.BigEndian.Uint16(p[6:])
+		if p[9] != 17 || ihl < 20 || len(p) < ihl || frag&0x3FFF != 0 {
+			return Datagram{}, false
+		}
+		if total >= ihl && total < len(p) {
+			p = p[:total] // 去掉以太网填充
+		}
+		src, dst = netip.AddrFrom4([4]byte(p[12:16])), netip.AddrFrom4([4]byte(p[16:20]))
+		p = p[ihl:]
+	case 0x86DD:
+		if len(p) < 40 {
+			return Datagram{}, false
+		}
+		next := p[6]
+		src, dst = netip.AddrFrom16([16]byte(p[8:24])), netip.AddrFrom16([16]byte(p[24:40]))
+		p = p[40:]
+		// 跳过逐跳、路由与目的选项扩展头；分片（44）不处理
+		for (next == 0 || next == 43 || next == 60) && len(p) >= 8 {
+			n := 8 + int(p[1])*8
+			if n > len(p) {
+				return Datagram{}, false
+			}
+			next, p = p[0], p[n:]
+		}
+		if next != 17 {
+			return Datagram{}, false
+		}
+	default:
+		return Datagram{}, false
+	}
+	if len(p) < 8 {
+		return Datagram{}, false
+	}
+	sport, dport := binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:])
+	payload := p[8:]
+	if n := int(binary.BigEndian.Uint16(p[4:])) - 8; n >= 0 && n < len(payload) {
+		payload = payload[:n]
+	}
+	return Datagram{Time: ts, Src: netip.AddrPortFrom(src, sport), Dst: netip.AddrPortFrom(dst, dport), Payload: payload}, true
+}
@@ -0,0 +1,269 @@
+package udpinspect
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"time"
+
+	"myproject/internal/audio/oggopus"
+	"myproject/internal/transport"
+)
+
+// Options 分析参数
+type Options struct {
+	Key   []byte // 会话 key（MQTT hello 的 udp.key），为空时不解密
+	Nonce []byte // 会话 nonce（udp.nonce）
+	Port  uint16 // 非 0 时只分析源或目的端口为 Port 的数据报
+}
+
+// Packet 一个音频包
+type Packet struct {
+	Time   time.Time // 抓包时间
+	Header transport.UDPAudioHeader
+	Opus   []byte // 解密后的负载；未提供 key 时为密文
+}
+
+// Event 流中的异常事件
+type Event struct {
+	Time   time.Time
+	Seq    uint32
+	Kind   string // gap / reorder / duplicate / ssrc / ts_backwards
+	Detail string
+}
+
+// Stream 一个方向（源 → 目的地址）上的音频包及统计
+type Stream struct {
+	Src, Dst netip.AddrPort
+	Packets  []Packet // 到达顺序
+
+	Bytes       int
+	SSRCs       []uint32 // 出现过的 SSRC，按首次出现顺序
+	Lost        int      // 序号缺口中最终未到达的包数
+	Reordered   int      // 晚于更大序号到达的包
+	Duplicates  int
+	TSBackwards int // 按序到达的包时间戳回退次数（不含 SSRC 切换）
+	// TOCMismatch 解密后 TOC 配置（模式/带宽/帧长）与流中最常见值不同的包数；
+	// 正常流中几乎为 0，接近包数时通常说明 key/nonce 不对
+	TOCMismatch int
+
+	// 时间戳漂移：发送端时间戳推进（毫秒）减去抓包时间推进，按 SSRC 分段重新计算
+	DriftMin, DriftMax, DriftLast time.Duration
+
+	Events []Event
+}
+
+// Report 分析结果
+type Report struct {
+	Datagrams int // 抓包中的 UDP 数据报总数
+	Skipped   int // 端口不符或不像音频包而跳过的数据报
+	Streams   []*Stream
+}
+
+// Analyze 按 docs/mqtt-udp.md 4.2 解析并解密音频包，按方向分流统计
+func Analyze(dgs []Datagram, opt Options) (*Report, error) {
+	if len(opt.Key) > 0 && (len(opt.Key) != 16 || len(opt.Nonce) != 16) {
+		return nil, fmt.Errorf("key and nonce must be 16 bytes, got %d/%d", len(opt.Key), len(opt.Nonce))
+	}
+	rep := &Report{Datagrams: len(dgs)}
+	byFlow := map[[2]netip.AddrPort]*streamState{}
+	var order []*streamState
+	for _, d := range dgs {
+		if opt.Port != 0 && d.Src.Port() != opt.Port && d.Dst.Port() != opt.Port {
+			rep.Skipped++
+			continue
+		}
+		h, payload, err := transport.ParseUDPAudioPacket(d.Payload)
+		if err != nil || h.Type != 0x01 {
+			rep.Skipped++
+			continue
+		}
+		var opus []byte
+		if len(opt.Key) > 0 {
+			if opus, err = transport.DecryptUDPAudio(opt.Key, opt.Nonce, h, payload); err != nil {
+				return nil, err
+			}
+		} else {
+			opus = append([]byte(nil), payload...)
+		}
+		flow := [2]netip.AddrPort{d.Src, d.Dst}
+		st := byFlow[flow]
+		if st == nil {
+			st = &streamState{Stream: &Stream{Src: d.Src, Dst: d.Dst}}
+			byFlow[flow] = st
+			order = append(order, st)
+		}
+		st.add(Packet{Time: d.Time, Header: h, Opus: opus})
+	}
+	for _, st := range order {
+		st.finish(len(opt.Key) > 0)
+		rep.Streams = append(rep.Streams, st.Stream)
+	}
+	return rep, nil
+}
+
+// maxTrackedGap 超过该长度的序号缺口直接计为丢失，不跟踪迟到补齐
+const maxTrackedGap = 10000
+
+// streamState 分析中的状态，SSRC 变化时序号与时间戳基准重新开始
+type streamState struct {
+	*Stream
+	ssrc    uint32
+	started bool
+	maxSeq  uint32
+	seen    map[uint32]bool
+	missing map[uint32]bool // 缺口中尚未到达的序号
+	ts0     uint32
+	t0      time.Time
+	lastTS  uint32
+}
+
+func (st *streamState) add(p Packet) {
+	h := p.Header
+	st.Packets = append(st.Packets, p)
+	st.Bytes += len(p.Opus)
+	if !st.started || h.SSRC != st.ssrc {
+		if st.started {
+			st.event(p, "ssrc", fmt.Sprintf("%08x -> %08x", st.ssrc, h.SSRC))
+			st.Lost += len(st.missing)
+		}
+		st.SSRCs = append(st.SSRCs, h.SSRC)
+		st.ssrc, st.started = h.SSRC, true
+		st.maxSeq = h.Seq
+		st.seen = map[uint32]bool{h.Seq: true}
+		st.missing = map[uint32]bool{}
+		st.ts0, st.t0, st.lastTS = h.Timestamp, p.Time, h.Timestamp
+		st.drift(p)
+		return
+	}
+
+	dup := st.seen[h.Seq]
+	switch d := int32(h.Seq - st.maxSeq); {
+	case dup:
+		st.Duplicates++
+		st.event(p, "duplicate", "")
+	case d > 0:
+		if d > 1 {
+			st.event(p, "gap", fmt.Sprintf("%d missing after %d", d-1, st.maxSeq))
+			if d-1 > maxTrackedGap {
+				// 序号跳变过大（多为发送端重置），不再逐个等待补齐
+				st.Lost += int(d - 1)
+			} else {
+				for s := st.maxSeq + 1; s != h.Seq; s++ {
+					st.missing[s] = true
+				}
+			}
+		}
+		st.maxSeq = h.Seq
+	default:
+		st.Reordered++
+		st.event(p, "reorder", fmt.Sprintf("arrived after %d", st.maxSeq))
+		delete(st.missing, h.Seq)
+	}
+	st.seen[h.Seq] = true
+
+	// 乱序与重复包的时间戳本就较旧，只检查按序到达的包
+	if int32(h.Seq-st.maxSeq) == 0 && !dup {
+		if int32(h.Timestamp-st.lastTS) < 0 {
+			st.TSBackwards++
+			st.event(p, "ts_backwards", fmt.Sprintf("%d -> %d", st.lastTS, h.Timestamp))
+		}
+		st.lastTS = h.Timestamp
+	}
+	st.drift(p)
+}
+
+func (st *streamState) drift(p Packet) {
+	if p.Time.IsZero() {
+		return
+	}
+	d := time.Duration(int32(p.Header.Timestamp-st.ts0))*time.Millisecond - p.Time.Sub(st.t0)
+	if len(st.Packets) == 1 || d < st.DriftMin {
+		st.DriftMin = d
+	}
+	if len(st.Packets) == 1 || d > st.DriftMax {
+		st.DriftMax = d
+	}
+	st.DriftLast = d
+}
+
+func (st *streamState) event(p Packet, kind, detail string) {
+	st.Events = append(st.Events, Event{Time: p.Time, Seq: p.Header.Seq, Kind: kind, Detail: detail})
+}
+
+func (st *streamState) finish(decrypted bool) {
+	st.Lost += len(st.missing)
+	if !decrypted {
+		return
+	}
+	var cfgs [32]int
+	total, most := 0, 0
+	for _, p := range st.Packets {
+		if len(p.Opus) == 0 {
+			continue
+		}
+		c := p.Opus[0] >> 3
+		cfgs[c]++
+		total++
+		if cfgs[c] > most {
+			most = cfgs[c]
+		}
+	}
+	st.TOCMismatch = total - most
+}
+
+// Ordered 返回按 SSRC 分段、段内按序号排序并去重后的 Opus 帧，用于导出
+func (s *Stream) Ordered() [][]byte {
+	type key struct {
+		seg int
+		off int64
+	}
+	var (
+		keys []key
+		pkts = map[key][]byte{}
+		seg  = -1
+		ssrc uint32
+		base uint32
+	)
+	for i, p := range s.Packets {
+		if i == 0 || p.Header.SSRC != ssrc {
+			seg, ssrc, base = seg+1, p.Header.SSRC, p.Header.Seq
+		}
+		k := key{seg, int64(int32(p.Header.Seq - base))}
+		if _, dup := pkts[k]; !dup {
+			pkts[k] = p.Opus
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].seg != keys[j].seg {
+			return keys[i].seg < keys[j].seg
+		}
+		return keys[i].off < keys[j].off
+	})
+	out := make([][]byte, len(keys))
+	for i, k := range keys {
+		out[i] = pkts[k]
+	}
+	return out
+}
+
+// WriteOgg 将 Ordered 的帧写为 Ogg/Opus；sampleRate/channels 写入 OpusHead，
+// 应与服务端 hello 的 audio_params 一致
+func (s *Stream) WriteOgg(w io.Writer, sampleRate, channels int) error {
+	ssrc := uint32(0)
+	if len(s.SSRCs) > 0 {
+		ssrc = s.SSRCs[0]
+	}
+	ow, err := oggopus.NewWriter(w, sampleRate, channels, ssrc)
+	if err != nil {
+		return err
+	}
+	for _, pkt := range s.Ordered() {
+		if err := ow.WritePacket(pkt); err != nil {
+			return err
+		}
+	}
+	return ow.Close()
+}
@@ -0,0 +1,266 @@
+package udpinspect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"myproject/internal/audio/oggopus"
+	"myproject/internal/transport"
+)
+
+var (
+	testKey   = []byte("0123456789abcdef")
+	testNonce = []byte("fedcba9876543210")
+	client    = netip.MustParseAddrPort("192.168.1.10:50000")
+	server    = netip.MustParseAddrPort("10.0.0.1:8888")
+	t0        = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+type sent struct {
+	at            time.Duration
+	src, dst      netip.AddrPort
+	ssrc, ts, seq uint32
+	opus          []byte
+}
+
+// audioPacket 按 docs/mqtt-udp.md 4.2 构造加密音频包
+func audioPacket(s sent) []byte {
+	h := transport.UDPAudioHeader{Type: 1, PayloadLen: uint16(len(s.opus)), SSRC: s.ssrc, Timestamp: s.ts, Seq: s.seq}
+	enc, _ := transport.DecryptUDPAudio(testKey, testNonce, h, s.opus)
+	p := make([]byte, transport.UDPAudioHeaderSize, transport.UDPAudioHeaderSize+len(enc))
+	p[0] = 1
+	binary.BigEndian.PutUint16(p[2:], h.PayloadLen)
+	binary.BigEndian.PutUint32(p[4:], h.SSRC)
+	binary.BigEndian.PutUint32(p[8:], h.Timestamp)
+	binary.BigEndian.PutUint32(p[12:], h.Seq)
+	return append(p, enc...)
+}
+
+// ethFrame 以太网 + IPv4/IPv6 + UDP 封装
+func ethFrame(src, dst netip.AddrPort, payload []byte) []byte {
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp, src.Port())
+	binary.BigEndian.PutUint16(udp[2:], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+
+	var ip []byte
+	ethType := uint16(0x0800)
+	if src.Addr().Is4() {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(udp)))
+		ip[8], ip[9] = 64, 17
+		s, d := src.Addr().As4(), dst.Addr().As4()
+		copy(ip[12:], s[:])
+		copy(ip[16:], d[:])
+	} else {
+		ethType = 0x86DD
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(udp)))
+		ip[6], ip[7] = 17, 64
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		copy(ip[8:], s[:])
+		copy(ip[24:], d[:])
+	}
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], ethType)
+	return append(append(eth, ip...), udp...)
+}
+
+func writePcap(pkts []sent) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr, 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], linkEthernet)
+	b.Write(hdr)
+	for _, s := range pkts {
+		frame := ethFrame(s.src, s.dst, audioPacket(s))
+		at := t0.Add(s.at)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec, uint32(at.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(at.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+		b.Write(rec)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+// writePcapng 大端字节序、纳秒时间戳（if_tsresol=9）
+func writePcapng(pkts []sent) []byte {
+	var b bytes.Buffer
+	be := binary.BigEndian
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		n := uint32(12 + len(body))
+		h := make([]byte, 8)
+		be.PutUint32(h, typ)
+		be.PutUint32(h[4:], n)
+		b.Write(h)
+		b.Write(body)
+		t := make([]byte, 4)
+		be.PutUint32(t, n)
+		b.Write(t)
+	}
+	shb := make([]byte, 16)
+	be.PutUint32(shb, 0x1A2B3C4D)
+	be.PutUint16(shb[4:], 1)
+	be.PutUint64(shb[8:], ^uint64(0))
+	block(0x0A0D0D0A, shb)
+	idb := make([]byte, 8, 20)
+	be.PutUint16(idb, linkEthernet)
+	idb = append(idb, 0, 9, 0, 1, 9, 0, 0, 0) // if_tsresol = 10^-9
+	idb = append(idb, 0, 0, 0, 0)             // opt_endofopt
+	block(1, idb)
+	for _, s := range pkts {
+		frame := ethFrame(s.src, s.dst, audioPacket(s))
+		ticks := uint64(t0.Add(s.at).UnixNano())
+		body := make([]byte, 20, 20+len(frame))
+		be.PutUint32(body[4:], uint32(ticks>>32))
+		be.PutUint32(body[8:], uint32(ticks))
+		be.PutUint32(body[12:], uint32(len(frame)))
+		be.PutUint32(body[16:], uint32(len(frame)))
+		block(6, append(body, frame...))
+	}
+	return b.Bytes()
+}
+
+// scenario 下行：序号 1 2 4 3 3 6（5 丢失，6 的时间戳回退）、之后 SSRC 切换；上行正常
+func scenario() []sent {
+	down := func(at, ts time.Duration, ssrc, seq uint32) sent {
+		return sent{at: at, src: server, dst: client, ssrc: ssrc, ts: uint32(1000 + ts.Milliseconds()), seq: seq, opus: []byte{0x58, byte(seq), 0xAA}}
+	}
+	ms := time.Millisecond
+	return []sent{
+		{at: 0, src: client, dst: server, ssrc: 7, ts: 50, seq: 1, opus: []byte{0x18, 1}},
+		down(10*ms, 0, 0xA, 1),
+		down(70*ms, 60*ms, 0xA, 2),
+		{at: 75 * ms, src: client, dst: server, ssrc: 7, ts: 110, seq: 2, opus: []byte{0x18, 2}},
+		down(190*ms, 180*ms, 0xA, 4),
+		down(200*ms, 120*ms, 0xA, 3),
+		down(201*ms, 120*ms, 0xA, 3),
+		down(320*ms, 100*ms, 0xA, 6),
+		down(400*ms, 400*ms, 0xB, 1),
+		down(460*ms, 460*ms, 0xB, 2),
+	}
+}
+
+func check(t *testing.T, rep *Report) {
+	t.Helper()
+	if len(rep.Streams) != 2 {
+		t.Fatalf("%d streams, want 2", len(rep.Streams))
+	}
+	up, down := rep.Streams[0], rep.Streams[1]
+	if up.Src != client || len(up.Packets) != 2 || up.Lost != 0 || len(up.Events) != 0 {
+		t.Errorf("upstream: src %v packets %d lost %d events %v", up.Src, len(up.Packets), up.Lost, up.Events)
+	}
+	if down.Lost != 1 || down.Reordered != 1 || down.Duplicates != 1 || down.TSBackwards != 1 {
+		t.Errorf("downstream lost/reordered/dup/tsback = %d/%d/%d/%d, want 1/1/1/1", down.Lost, down.Reordered, down.Duplicates, down.TSBackwards)
+	}
+	if len(down.SSRCs) != 2 || down.SSRCs[1] != 0xB {
+		t.Errorf("ssrcs %x", down.SSRCs)
+	}
+	if down.TOCMismatch != 0 {
+		t.Errorf("toc mismatch %d with correct key", down.TOCMismatch)
+	}
+	// seq 6 时间戳 100ms、抓包 310ms：漂移最小 -210ms
+	if down.DriftMin != -210*time.Millisecond || down.DriftMax != 0 {
+		t.Errorf("drift min/max %v/%v", down.DriftMin, down.DriftMax)
+	}
+	var seqs []byte
+	for _, pkt := range down.Ordered() {
+		seqs = append(seqs, pkt[1])
+	}
+	if !bytes.Equal(seqs, []byte{1, 2, 3, 4, 6, 1, 2}) {
+		t.Errorf("ordered seqs %v", seqs)
+	}
+}
+
+func TestPcap(t *testing.T) {
+	pkts := scenario()
+	data := writePcap(pkts)
+	dgs, err := ReadCapture(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dgs) != len(pkts) || !dgs[1].Time.Equal(t0.Add(10*time.Millisecond)) {
+		t.Fatalf("%d datagrams, first down at %v", len(dgs), dgs[1].Time)
+	}
+	rep, err := Analyze(dgs, Options{Key: testKey, Nonce: testNonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, rep)
+
+	// 端口过滤与错误 key
+	rep, _ = Analyze(dgs, Options{Key: []byte("0000000000000000"), Nonce: testNonce, Port: 9999})
+	if len(rep.Streams) != 0 || rep.Skipped != len(dgs) {
+		t.Errorf("port filter: %d streams, %d skipped", len(rep.Streams), rep.Skipped)
+	}
+	rep, _ = Analyze(dgs, Options{Key: []byte("0000000000000000"), Nonce: testNonce})
+	if down := rep.Streams[1]; down.TOCMismatch == 0 {
+		t.Error("wrong key not detected by toc mismatch")
+	}
+}
+
+func TestPcapngIPv6(t *testing.T) {
+	pkts := scenario()
+	v6c, v6s := netip.MustParseAddrPort("[fe80::1]:50000"), netip.MustParseAddrPort("[2001:db8::2]:8888")
+	for i := range pkts {
+		if pkts[i].src == client {
+			pkts[i].src, pkts[i].dst = v6c, v6s
+		} else {
+			pkts[i].src, pkts[i].dst = v6s, v6c
+		}
+	}
+	client, server = v6c, v6s
+	defer func() {
+		client, server = netip.MustParseAddrPort("192.168.1.10:50000"), netip.MustParseAddrPort("10.0.0.1:8888")
+	}()
+	dgs, err := ReadCapture(bytes.NewReader(writePcapng(pkts)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dgs) != len(pkts) || !dgs[1].Time.Equal(t0.Add(10*time.Millisecond)) {
+		t.Fatalf("%d datagrams, first down at %v", len(dgs), dgs[1].Time)
+	}
+	rep, err := Analyze(dgs, Options{Key: testKey, Nonce: testNonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, rep)
+}
+
+func TestWriteOgg(t *testing.T) {
+	dgs, _ := ReadCapture(bytes.NewReader(writePcap(scenario())))
+	rep, _ := Analyze(dgs, Options{Key: testKey, Nonce: testNonce})
+	down := rep.Streams[1]
+	var buf bytes.Buffer
+	if err := down.WriteOgg(&buf, 24000, 1); err != nil {
+		t.Fatal(err)
+	}
+	ogg, err := oggopus.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := down.Ordered()
+	if len(ogg.Packets) != len(want) {
+		t.Fatalf("%d ogg packets, want %d", len(ogg.Packets), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(ogg.Packets[i], want[i]) {
+			t.Errorf("packet %d: %x, want %x", i, ogg.Packets[i], want[i])
+		}
+	}
+}
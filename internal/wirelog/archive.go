@@ -0,0 +1,260 @@
+// Package wirelog 线级会话录制与回放：Recorder 挂在 client.Client.OnWire 上，
+// 按单调时钟记录双向的每个文本/二进制帧到一个 JSON Lines 归档文件；
+// Server 按录制的下行流量应答真实客户端（假服务端），Play 按原始节奏向真实服务端重发上行流量（假客户端）。
+package wirelog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"myproject/internal/transport"
+)
+
+// 归档格式标识，写在首行 Header 中
+const (
+	FormatName    = "xiaozhi-wirelog"
+	FormatVersion = 1
+)
+
+// Header 归档首行
+type Header struct {
+	Format  string            `json:"format"`
+	Version int               `json:"version"`
+	Started time.Time         `json:"started"`
+	Meta    map[string]string `json:"meta,omitempty"` // 录制方附加的信息，如协议、服务端地址
+}
+
+// Frame 一个录制的帧
+type Frame struct {
+	T       time.Duration // 距录制开始的单调时间
+	Dir     string        // transport.WireUp / WireDown
+	Channel string        // ws / mqtt / udp
+	Binary  bool
+	Data    []byte
+}
+
+// line 归档中一行帧记录：文本帧存为字符串便于阅读与 diff，二进制帧为 base64
+type line struct {
+	T       int64  `json:"t"` // 纳秒
+	Dir     string `json:"dir"`
+	Channel string `json:"ch"`
+	Text    string `json:"text,omitempty"`
+	Binary  bool   `json:"binary,omitempty"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// flushInterval 二进制帧（音频）缓冲的最长刷新间隔；文本帧写入后立即刷新
+const flushInterval = time.Second
+
+// Recorder 录制器，Record 可直接赋给 client.Client.OnWire。
+// 控制通道的文本帧写入后立即刷新到底层 Writer，进程崩溃时归档仍包含出问题前的全部控制消息
+type Recorder struct {
+	start time.Time
+
+	mu      sync.Mutex
+	bw      *bufio.Writer
+	enc     *json.Encoder
+	closer  io.Closer
+	frames  int
+	flushed time.Time
+	err     error
+}
+
+// NewRecorder 写入归档头并开始计时；meta 可为 nil
+func NewRecorder(w io.Writer, meta map[string]string) (*Recorder, error) {
+	r := &Recorder{start: time.Now(), bw: bufio.NewWriter(w)}
+	r.flushed = r.start
+	r.enc = json.NewEncoder(r.bw)
+	r.enc.SetEscapeHTML(false)
+	if err := r.enc.Encode(Header{Format: FormatName, Version: FormatVersion, Started: r.start, Meta: meta}); err != nil {
+		return nil, err
+	}
+	return r, r.bw.Flush()
+}
+
+// Create 创建归档文件并开始录制
+func Create(path string, meta map[string]string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(f, meta)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Record 追加一帧；写入错误保留到 Close 返回
+func (r *Recorder) Record(f transport.WireFrame) {
+	l := line{Dir: f.Dir, Channel: f.Channel}
+	if f.Binary {
+		l.Binary, l.Data = true, f.Data
+	} else {
+		l.Text = string(f.Data)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.enc == nil {
+		return
+	}
+	// 在锁内取时间，保证文件中时间单调
+	now := time.Now()
+	l.T = int64(now.Sub(r.start))
+	if r.err = r.enc.Encode(l); r.err != nil {
+		return
+	}
+	r.frames++
+	if !f.Binary || now.Sub(r.flushed) >= flushInterval {
+		r.err = r.bw.Flush()
+		r.flushed = now
+	}
+}
+
+// Frames 已录制的帧数
+func (r *Recorder) Frames() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frames
+}
+
+// Flush 将缓冲写入底层 Writer
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.bw.Flush()
+}
+
+// Close 刷新并关闭归档文件，返回录制期间的首个错误；之后的 Record 被忽略
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enc == nil {
+		return r.err
+	}
+	r.enc = nil
+	err := r.err
+	if ferr := r.bw.Flush(); err == nil {
+		err = ferr
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	r.err = err
+	return err
+}
+
+// Archive 读入内存的归档
+type Archive struct {
+	Header Header
+	Frames []Frame
+}
+
+// Read 读取归档；录制中途被打断导致的末行残缺会被忽略
+func Read(rd io.Reader) (*Archive, error) {
+	dec := json.NewDecoder(bufio.NewReader(rd))
+	a := &Archive{}
+	if err := dec.Decode(&a.Header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if a.Header.Format != FormatName {
+		return nil, fmt.Errorf("not a %s archive (format %q)", FormatName, a.Header.Format)
+	}
+	if a.Header.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported %s version %d", FormatName, a.Header.Version)
+	}
+	for {
+		var l line
+		err := dec.Decode(&l)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return a, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read frame %d: %w", len(a.Frames), err)
+		}
+		f := Frame{T: time.Duration(l.T), Dir: l.Dir, Channel: l.Channel, Binary: l.Binary, Data: l.Data}
+		if !l.Binary {
+			f.Data = []byte(l.Text)
+		}
+		a.Frames = append(a.Frames, f)
+	}
+}
+
+// Open 读取归档文件
+func Open(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Protocol 按录制的通道判断协议："mqtt"（MQTT+UDP）或 "ws"
+func (a *Archive) Protocol() string {
+	for _, f := range a.Frames {
+		if f.Channel == "mqtt" || f.Channel == "udp" {
+			return "mqtt"
+		}
+	}
+	return "ws"
+}
+
+// Duration 录制时长（最后一帧的时间）
+func (a *Archive) Duration() time.Duration {
+	if len(a.Frames) == 0 {
+		return 0
+	}
+	return a.Frames[len(a.Frames)-1].T
+}
+
+// scaled 按回放速度换算录制时间间隔
+func scaled(d time.Duration, speed float64) time.Duration {
+	if speed <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) / speed)
+}
+
+// sleepUntil 等到 at；done 关闭时返回 false
+func sleepUntil(at time.Time, done <-chan struct{}) bool {
+	d := time.Until(at)
+	if d <= 0 {
+		select {
+		case <-done:
+			return false
+		default:
+			return true
+		}
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// jsonType 返回文本帧的 type 字段
+func jsonType(data []byte) string {
+	var m struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(data, &m)
+	return m.Type
+}
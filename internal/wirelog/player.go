@@ -0,0 +1,288 @@
+package wirelog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"myproject/internal/transport"
+)
+
+// PlayerConfig 假客户端参数
+type PlayerConfig struct {
+	Speed        float64       // 回放速度倍率，默认 1（按录制节奏）
+	HelloTimeout time.Duration // 等待服务端 hello 的超时，默认 10s
+	// Linger 发完最后一个上行帧后继续接收下行的时长；默认取录制中最后一个上行帧之后的时长
+	Linger time.Duration
+
+	// WebSocket：Headers 未含 Protocol-Version 时取录制的上行 hello 中的 version
+	URL     string
+	Headers map[string]string
+
+	// MQTT+UDP
+	Broker         string
+	Username       string
+	Password       string
+	ClientID       string
+	PublishTopic   string // 默认 device-server
+	SubscribeTopic string
+	KeepAliveSec   int
+
+	// OnFrame 本次回放的线级收发帧，可接另一个 Recorder 以便与原录制对比
+	OnFrame func(transport.WireFrame)
+}
+
+// Play 以假客户端身份连接真实服务端，按录制的节奏重发上行帧。发送上行 hello 后等待
+// 服务端 hello，此后的时间以其到达时刻重新对齐；上行 JSON 中的 session_id 替换为本次会话的值，
+// MQTT 录制按新 hello 下发的 udp 参数重建 UDP 通道
+func Play(ctx context.Context, a *Archive, cfg PlayerConfig) error {
+	if cfg.HelloTimeout <= 0 {
+		cfg.HelloTimeout = 10 * time.Second
+	}
+	var ups []Frame
+	for _, f := range a.Frames {
+		if f.Dir == transport.WireUp {
+			ups = append(ups, f)
+		}
+	}
+	if len(ups) == 0 {
+		return errors.New("archive has no upstream frames")
+	}
+	if cfg.Linger <= 0 {
+		cfg.Linger = scaled(a.Duration()-ups[len(ups)-1].T, cfg.Speed)
+	}
+
+	p := &player{cfg: cfg, helloCh: make(chan struct{}, 1)}
+	if err := p.open(ctx, a.Protocol(), ups); err != nil {
+		return err
+	}
+	defer p.close()
+
+	anchorRec, anchorLive := ups[0].T, time.Now()
+	for i, f := range ups {
+		if !sleepUntil(anchorLive.Add(scaled(f.T-anchorRec, cfg.Speed)), ctx.Done()) {
+			return ctx.Err()
+		}
+		isHello := !f.Binary && jsonType(f.Data) == "hello"
+		if err := p.send(ctx, f); err != nil {
+			return fmt.Errorf("send frame %d (%s): %w", i, f.Channel, err)
+		}
+		if !isHello {
+			continue
+		}
+		if err := p.waitHello(ctx); err != nil {
+			return err
+		}
+		// 以录制中对应的下行 hello 为新的时间基准
+		anchorRec, anchorLive = f.T, time.Now()
+		if t, ok := downHelloAfter(a, f.T); ok {
+			anchorRec = t
+		}
+	}
+	if !sleepUntil(time.Now().Add(cfg.Linger), ctx.Done()) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// downHelloAfter 返回录制中 t 之后第一个下行 hello 的时间
+func downHelloAfter(a *Archive, t time.Duration) (time.Duration, bool) {
+	for _, f := range a.Frames {
+		if f.T >= t && f.Dir == transport.WireDown && !f.Binary && jsonType(f.Data) == "hello" {
+			return f.T, true
+		}
+	}
+	return 0, false
+}
+
+type player struct {
+	cfg  PlayerConfig
+	ws   *transport.WebsocketTransport
+	mqtt *transport.MQTTControl
+
+	mu        sync.Mutex
+	udp       *transport.UDPAudio
+	sessionID string
+	helloUDP  *udpInfo
+	helloCh   chan struct{}
+}
+
+type udpInfo struct {
+	Server string `json:"server"`
+	Port   int    `json:"port"`
+	Key    string `json:"key"`
+	Nonce  string `json:"nonce"`
+}
+
+func (p *player) open(ctx context.Context, protocol string, ups []Frame) error {
+	handlers := transport.Handlers{OnText: p.onText}.Tap(protocol, p.cfg.OnFrame)
+	if protocol == "ws" {
+		if p.cfg.URL == "" {
+			return errors.New("websocket url required")
+		}
+		headers := make(map[string]string, len(p.cfg.Headers)+1)
+		for k, v := range p.cfg.Headers {
+			headers[k] = v
+		}
+		if _, ok := headers["Protocol-Version"]; !ok {
+			if v := recordedVersion(ups); v > 0 {
+				headers["Protocol-Version"] = strconv.Itoa(v)
+			}
+		}
+		p.ws = transport.NewWebsocketTransport(p.cfg.URL, handlers)
+		return p.ws.Open(ctx, headers)
+	}
+	if p.cfg.Broker == "" {
+		return errors.New("mqtt broker required")
+	}
+	pub := p.cfg.PublishTopic
+	if pub == "" {
+		pub = "device-server"
+	}
+	p.mqtt = transport.NewMQTTControl(p.cfg.Broker, p.cfg.ClientID, p.cfg.Username, p.cfg.Password, pub, p.cfg.SubscribeTopic, p.cfg.KeepAliveSec, handlers)
+	return p.mqtt.Open(ctx, nil)
+}
+
+// recordedVersion 录制的上行 hello 中的协议版本
+func recordedVersion(ups []Frame) int {
+	for _, f := range ups {
+		if f.Binary {
+			continue
+		}
+		var m struct {
+			Type    string `json:"type"`
+			Version int    `json:"version"`
+		}
+		if json.Unmarshal(f.Data, &m) == nil && m.Type == "hello" {
+			return m.Version
+		}
+	}
+	return 0
+}
+
+func (p *player) onText(ctx context.Context, text []byte) {
+	var m struct {
+		Type      string   `json:"type"`
+		SessionID string   `json:"session_id"`
+		UDP       *udpInfo `json:"udp"`
+	}
+	if json.Unmarshal(text, &m) != nil || m.Type != "hello" {
+		return
+	}
+	p.mu.Lock()
+	p.sessionID, p.helloUDP = m.SessionID, m.UDP
+	p.mu.Unlock()
+	select {
+	case p.helloCh <- struct{}{}:
+	default:
+	}
+}
+
+// waitHello 等待服务端 hello；MQTT 时按其 udp 参数重建 UDP 通道
+func (p *player) waitHello(ctx context.Context) error {
+	select {
+	case <-p.helloCh:
+	case <-time.After(p.cfg.HelloTimeout):
+		return errors.New("hello timeout")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if p.mqtt == nil {
+		return nil
+	}
+	p.mu.Lock()
+	info := p.helloUDP
+	p.mu.Unlock()
+	if info == nil {
+		return errors.New("hello missing udp block")
+	}
+	u := transport.NewUDPAudio(info.Server, info.Port, info.Key, info.Nonce, transport.UDPAudioHandlers{
+		OnPacket: func(ctx context.Context, seq uint32, opus []byte) {
+			if p.cfg.OnFrame != nil {
+				p.cfg.OnFrame(transport.WireFrame{Dir: transport.WireDown, Channel: "udp", Binary: true, Data: opus})
+			}
+		},
+	})
+	if err := u.Open(); err != nil {
+		return fmt.Errorf("open udp %s:%d: %w", info.Server, info.Port, err)
+	}
+	p.mu.Lock()
+	old := p.udp
+	p.udp = u
+	p.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+func (p *player) send(ctx context.Context, f Frame) error {
+	data := f.Data
+	if !f.Binary {
+		data = p.rewriteSession(data)
+	}
+	var err error
+	switch {
+	case f.Channel == "udp":
+		p.mu.Lock()
+		u := p.udp
+		p.mu.Unlock()
+		if u == nil {
+			return errors.New("udp not open")
+		}
+		err = u.SendOpusFrame(data)
+	case p.ws != nil && f.Binary:
+		err = p.ws.SendBinary(ctx, data)
+	case p.ws != nil:
+		err = p.ws.SendText(ctx, data)
+	case p.mqtt != nil:
+		err = p.mqtt.SendText(ctx, data)
+	}
+	if err == nil && p.cfg.OnFrame != nil {
+		p.cfg.OnFrame(transport.WireFrame{Dir: transport.WireUp, Channel: f.Channel, Binary: f.Binary, Data: data})
+	}
+	return err
+}
+
+// rewriteSession 将上行 JSON 中录制时的 session_id 替换为本次会话的值
+func (p *player) rewriteSession(data []byte) []byte {
+	p.mu.Lock()
+	sid := p.sessionID
+	p.mu.Unlock()
+	if sid == "" {
+		return data
+	}
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		return data
+	}
+	if old, ok := m["session_id"]; !ok || old == sid {
+		return data
+	}
+	m["session_id"] = sid
+	b, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+	return b
+}
+
+func (p *player) close() {
+	p.mu.Lock()
+	u := p.udp
+	p.udp = nil
+	p.mu.Unlock()
+	if u != nil {
+		_ = u.Close()
+	}
+	if p.ws != nil {
+		_ = p.ws.Close()
+	}
+	if p.mqtt != nil {
+		_ = p.mqtt.Close()
+	}
+}
@@ -0,0 +1,300 @@
+package wirelog
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"myproject/internal/logging"
+	"myproject/internal/mockserver"
+	"myproject/internal/mqttbroker"
+	"myproject/internal/transport"
+)
+
+// ServerConfig 回放服务端参数
+type ServerConfig struct {
+	Speed float64 // 回放速度倍率，默认 1（按录制节奏）
+
+	// MQTT+UDP 回放
+	RequestTopic string                       // 设备发布的主题，默认 device-server
+	ReplyTopic   func(clientID string) string // 下发主题，默认 mockserver.DefaultReplyTopic
+	UDPHost      string                       // UDP 监听地址，默认 127.0.0.1
+}
+
+// Server 假服务端：对每个连接按录制顺序下发下行帧。每个下行帧要等录制时排在它之前的
+// 上行文本帧在本次连接中都已到达，再按与最近一个上行文本帧的录制间隔发送，
+// 因此客户端较慢或较快时应答仍对得上请求。上行音频只接收不参与同步。
+type Server struct {
+	arc      *Archive
+	cfg      ServerConfig
+	ln       net.Listener
+	srv      *http.Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	plays []*playback
+	mqtt  map[string]*mqttPlay // clientID → 当前会话
+}
+
+// NewServer 创建回放服务端（尚未监听）
+func NewServer(a *Archive, cfg ServerConfig) *Server {
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+	if cfg.RequestTopic == "" {
+		cfg.RequestTopic = "device-server"
+	}
+	if cfg.ReplyTopic == nil {
+		cfg.ReplyTopic = mockserver.DefaultReplyTopic
+	}
+	if cfg.UDPHost == "" {
+		cfg.UDPHost = "127.0.0.1"
+	}
+	return &Server{arc: a, cfg: cfg, mqtt: make(map[string]*mqttPlay)}
+}
+
+// Start 监听 addr 并提供 WebSocket 回放
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.srv = &http.Server{Handler: s}
+	go s.srv.Serve(ln)
+	return nil
+}
+
+// URL 返回 WebSocket 地址
+func (s *Server) URL() string { return "ws://" + s.ln.Addr().String() + "/xiaozhi/v1/" }
+
+// Close 停止全部回放并关闭监听
+func (s *Server) Close() error {
+	s.mu.Lock()
+	plays := s.plays
+	s.plays = nil
+	s.mqtt = make(map[string]*mqttPlay)
+	s.mu.Unlock()
+	for _, p := range plays {
+		p.close()
+	}
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+// ServeHTTP 处理 WebSocket 连接，回放录制中 ws 通道的下行帧
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	var wmu sync.Mutex
+	p := s.newPlayback("ws", func(f Frame) error {
+		typ := websocket.TextMessage
+		if f.Binary {
+			typ = websocket.BinaryMessage
+		}
+		wmu.Lock()
+		defer wmu.Unlock()
+		return ws.WriteMessage(typ, f.Data)
+	})
+	p.onClose = func() { _ = ws.Close() }
+	go p.run()
+	defer p.close()
+	for {
+		typ, _, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ == websocket.TextMessage {
+			p.upstreamText()
+		}
+	}
+}
+
+// ServeMQTT 订阅 broker 上的设备请求主题，为每个设备回放录制中 mqtt/udp 通道的下行帧；
+// 下行 hello 中的 udp 字段替换为新分配的本地 UDP 端点。返回取消订阅函数
+func (s *Server) ServeMQTT(b *mqttbroker.Broker) (stop func()) {
+	return b.Subscribe(s.cfg.RequestTopic, func(m mqttbroker.Message) {
+		if m.ClientID == "" {
+			return
+		}
+		s.mu.Lock()
+		mp := s.mqtt[m.ClientID]
+		s.mu.Unlock()
+		// 录制中可能含重连后的再次 hello，仅在没有进行中的回放时开启新会话
+		if mp == nil || mp.finished() {
+			var err error
+			if mp, err = s.newMQTTPlay(b, m.ClientID); err != nil {
+				logging.L().With("module", "wirelog").Warn("创建 UDP 端点失败", "client_id", m.ClientID, "err", err)
+				return
+			}
+		}
+		mp.upstreamText()
+	})
+}
+
+type mqttPlay struct {
+	*playback
+	udp *mockserver.UDPEndpoint
+}
+
+func (s *Server) newMQTTPlay(b *mqttbroker.Broker, clientID string) (*mqttPlay, error) {
+	udp, err := mockserver.NewUDPEndpoint(net.JoinHostPort(s.cfg.UDPHost, "0"))
+	if err != nil {
+		return nil, err
+	}
+	topic := s.cfg.ReplyTopic(clientID)
+	mp := &mqttPlay{udp: udp}
+	mp.playback = s.newPlayback("mqtt", func(f Frame) error {
+		switch {
+		case f.Channel == "udp":
+			return udp.Send(f.Data)
+		case jsonType(f.Data) == "hello":
+			b.Publish(topic, rewriteUDP(f.Data, udp.Info()), 1, false)
+		default:
+			b.Publish(topic, f.Data, 1, false)
+		}
+		return nil
+	})
+	mp.onClose = func() { _ = udp.Close() }
+
+	s.mu.Lock()
+	old := s.mqtt[clientID]
+	s.mqtt[clientID] = mp
+	s.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	go mp.run()
+	return mp, nil
+}
+
+// rewriteUDP 替换服务端 hello 中的 udp 字段
+func rewriteUDP(hello []byte, udp map[string]any) []byte {
+	var m map[string]any
+	if json.Unmarshal(hello, &m) != nil {
+		return hello
+	}
+	m["udp"] = udp
+	b, err := json.Marshal(m)
+	if err != nil {
+		return hello
+	}
+	return b
+}
+
+func (s *Server) newPlayback(protocol string, send func(Frame) error) *playback {
+	p := &playback{speed: s.cfg.Speed, send: send, notify: make(chan struct{}, 1), done: make(chan struct{})}
+	for _, f := range s.arc.Frames {
+		if (protocol == "ws") == (f.Channel == "ws") {
+			p.frames = append(p.frames, f)
+		}
+	}
+	s.mu.Lock()
+	s.plays = append(s.plays, p)
+	s.mu.Unlock()
+	return p
+}
+
+// playback 一个连接上的下行回放
+type playback struct {
+	frames  []Frame
+	speed   float64
+	send    func(Frame) error
+	onClose func()
+
+	mu       sync.Mutex
+	arrivals []time.Time // 本次连接中各上行文本帧的到达时间
+	ended    bool
+	notify   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (p *playback) upstreamText() {
+	p.mu.Lock()
+	p.arrivals = append(p.arrivals, time.Now())
+	p.mu.Unlock()
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// waitUpstream 等待第 n 个上行文本帧，返回其到达时间
+func (p *playback) waitUpstream(n int) (time.Time, bool) {
+	for {
+		p.mu.Lock()
+		if len(p.arrivals) >= n {
+			t := p.arrivals[n-1]
+			p.mu.Unlock()
+			return t, true
+		}
+		p.mu.Unlock()
+		select {
+		case <-p.notify:
+		case <-p.done:
+			return time.Time{}, false
+		}
+	}
+}
+
+func (p *playback) run() {
+	defer func() {
+		p.mu.Lock()
+		p.ended = true
+		p.mu.Unlock()
+	}()
+	log := logging.L().With("module", "wirelog")
+	var anchorRec time.Duration
+	anchorLive := time.Now()
+	ups := 0
+	for _, f := range p.frames {
+		if f.Dir == transport.WireUp {
+			if f.Binary {
+				continue
+			}
+			ups++
+			t, ok := p.waitUpstream(ups)
+			if !ok {
+				return
+			}
+			anchorRec, anchorLive = f.T, t
+			continue
+		}
+		if !sleepUntil(anchorLive.Add(scaled(f.T-anchorRec, p.speed)), p.done) {
+			return
+		}
+		if err := p.send(f); err != nil {
+			// UDP 对端未知（客户端尚未发送上行音频）等情况跳过该帧
+			log.Debug("回放下行帧失败", "channel", f.Channel, "t", f.T, "err", err)
+			if errors.Is(err, websocket.ErrCloseSent) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+		}
+	}
+}
+
+// finished 下行帧已全部发送（或已关闭）
+func (p *playback) finished() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ended
+}
+
+func (p *playback) close() {
+	p.once.Do(func() {
+		close(p.done)
+		if p.onClose != nil {
+			p.onClose()
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package wirelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"myproject/internal/client"
+	"myproject/internal/mockserver"
+	"myproject/internal/mockserver/mocktest"
+	"myproject/internal/mqttbroker"
+	"myproject/internal/transport"
+)
+
+// runSession 固定流程：文本提问一轮、上行音频一轮，返回两轮结果
+func runSession(t *testing.T, cfg client.Config, onWire func(transport.WireFrame)) []client.TurnResult {
+	t.Helper()
+	c := mocktest.Dial(t, cfg, func(c *client.Client) { c.OnWire = onWire })
+	defer c.Close()
+	var out []client.TurnResult
+	turn, err := c.Ask(context.Background(), "开灯")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = append(out, mocktest.WaitTurn(t, turn))
+	frames := [][]byte{{0x18, 1}, {0x18, 2}, {0x18, 3}}
+	// 按实时节奏发送：MQTT+UDP 下 listen stop 与 UDP 音频分属两条通道，快速模式下可能先于音频到达
+	if turn, err = c.SpeakOpus(context.Background(), frames); err != nil {
+		t.Fatal(err)
+	}
+	out = append(out, mocktest.WaitTurn(t, turn))
+	// UDP 下行帧经抖动缓冲输出，可能晚于 tts stop
+	time.Sleep(300 * time.Millisecond)
+	return out
+}
+
+func record(t *testing.T, cfg client.Config) (*Archive, []client.TurnResult) {
+	t.Helper()
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf, map[string]string{"test": t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := runSession(t, cfg, rec.Record)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.Meta["test"] != t.Name() {
+		t.Errorf("header meta %v", a.Header.Meta)
+	}
+	return a, results
+}
+
+// count 统计某方向、通道的帧数，binary 区分文本/二进制
+func count(a *Archive, dir, channel string, binary bool) int {
+	n := 0
+	for _, f := range a.Frames {
+		if f.Dir == dir && f.Channel == channel && f.Binary == binary {
+			n++
+		}
+	}
+	return n
+}
+
+func sameResults(t *testing.T, got, want []client.TurnResult) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d turns, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.STT != w.STT || g.Emotion != w.Emotion || len(g.Sentences) != len(w.Sentences) {
+			t.Errorf("turn %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestRecordAndServeWebsocket(t *testing.T) {
+	srv := mocktest.StartServer(t, mockserver.Config{SessionID: "rec-ws", Echo: true})
+	a, want := record(t, mocktest.WSConfig(srv.URL()))
+
+	if a.Protocol() != "ws" {
+		t.Errorf("protocol %q", a.Protocol())
+	}
+	if f := a.Frames[0]; f.Dir != transport.WireUp || jsonType(f.Data) != "hello" {
+		t.Errorf("first frame %s %q, want upstream hello", f.Dir, f.Data)
+	}
+	if n := count(a, transport.WireUp, "ws", true); n != 3 {
+		t.Errorf("%d upstream audio frames, want 3", n)
+	}
+	if n := count(a, transport.WireDown, "ws", true); n < 4 {
+		t.Errorf("%d downstream audio frames, want >= 4", n)
+	}
+	for i := 1; i < len(a.Frames); i++ {
+		if a.Frames[i].T < a.Frames[i-1].T {
+			t.Fatalf("frame %d timestamp goes backwards", i)
+		}
+	}
+
+	// 假服务端：新客户端重复同样的流程应得到相同的应答
+	rs := NewServer(a, ServerConfig{})
+	if err := rs.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	var buf bytes.Buffer
+	replayed, _ := NewRecorder(&buf, nil)
+	got := runSession(t, mocktest.WSConfig(rs.URL()), replayed.Record)
+	sameResults(t, got, want)
+	_ = replayed.Close()
+	b, _ := Read(&buf)
+	if n, w := count(b, transport.WireDown, "ws", true), count(a, transport.WireDown, "ws", true); n != w {
+		t.Errorf("replayed %d downstream audio frames, want %d", n, w)
+	}
+}
+
+func TestPlayWebsocket(t *testing.T) {
+	src := mocktest.StartServer(t, mockserver.Config{SessionID: "rec-ws", Echo: true})
+	a, _ := record(t, mocktest.WSConfig(src.URL()))
+
+	// 假客户端：向另一个服务端按原节奏重发上行流量
+	live := mocktest.StartServer(t, mockserver.Config{SessionID: "live-ws", Echo: true})
+	var mu sync.Mutex
+	var down []string
+	err := Play(context.Background(), a, PlayerConfig{URL: live.URL(), OnFrame: func(f transport.WireFrame) {
+		if f.Dir == transport.WireDown && !f.Binary {
+			mu.Lock()
+			down = append(down, jsonType(f.Data))
+			mu.Unlock()
+		}
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := live.Conns()
+	if len(conns) != 1 {
+		t.Fatalf("%d connections, want 1", len(conns))
+	}
+	if conns[0].Version != 3 {
+		t.Errorf("protocol version %d, want 3 from recorded hello", conns[0].Version)
+	}
+	if conns[0].UpstreamFrames != 3 {
+		t.Errorf("%d upstream frames, want 3", conns[0].UpstreamFrames)
+	}
+	for _, m := range conns[0].Messages {
+		if sid, ok := m["session_id"]; ok && sid != "live-ws" {
+			t.Errorf("%v carries recorded session_id", m)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stt := 0
+	for _, typ := range down {
+		if typ == "stt" {
+			stt++
+		}
+	}
+	if stt != 2 {
+		t.Errorf("received %d stt messages, want 2 (%v)", stt, down)
+	}
+}
+
+func TestMQTTRecordServeAndPlay(t *testing.T) {
+	src, _ := mocktest.StartMQTT(t, mockserver.MQTTConfig{Config: mockserver.Config{SessionID: "rec-mqtt", Echo: true}})
+	a, want := record(t, mocktest.MQTTConfig(src, "dev-rec"))
+	if a.Protocol() != "mqtt" {
+		t.Errorf("protocol %q", a.Protocol())
+	}
+	if n := count(a, transport.WireUp, "udp", true); n != 3 {
+		t.Errorf("%d upstream udp frames, want 3", n)
+	}
+	if n := count(a, transport.WireDown, "udp", true); n != 3 {
+		t.Errorf("%d downstream udp frames, want 3", n)
+	}
+
+	// 假服务端：hello 中的 udp 参数换成新端点，其余按录制下发
+	rb := mocktest.StartBroker(t, mqttbroker.Config{})
+	rs := NewServer(a, ServerConfig{})
+	defer rs.Close()
+	stop := rs.ServeMQTT(rb)
+	defer stop()
+	var echoed int
+	var mu sync.Mutex
+	got := runSession(t, mocktest.MQTTConfig(rb, "dev-replay"), func(f transport.WireFrame) {
+		if f.Dir == transport.WireDown && f.Channel == "udp" {
+			mu.Lock()
+			echoed++
+			mu.Unlock()
+		}
+	})
+	sameResults(t, got, want)
+	mu.Lock()
+	if echoed != 3 {
+		t.Errorf("replayed %d udp frames, want 3", echoed)
+	}
+	mu.Unlock()
+
+	// 假客户端：对新的 MQTT+UDP 服务端重发
+	lb := mocktest.StartBroker(t, mqttbroker.Config{})
+	live := mockserver.NewMQTTResponder(lb, mockserver.MQTTConfig{Config: mockserver.Config{SessionID: "live-mqtt"}})
+	defer live.Close()
+	err := Play(context.Background(), a, PlayerConfig{Broker: lb.URL(), ClientID: "dev-play", SubscribeTopic: mockserver.DefaultReplyTopic("dev-play")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := live.Sessions()
+	if len(sessions) != 1 || sessions[0].UpstreamFrames != 3 {
+		t.Fatalf("sessions: %+v", sessions)
+	}
+	for _, m := range sessions[0].Messages {
+		if sid, ok := m["session_id"]; ok && sid != "live-mqtt" {
+			b, _ := json.Marshal(m)
+			t.Errorf("%s carries recorded session_id", b)
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	rec, _ := NewRecorder(&buf, nil)
+	rec.Record(transport.WireFrame{Dir: transport.WireUp, Channel: "ws", Data: []byte(`{"type":"hello"}`)})
+	rec.Record(transport.WireFrame{Dir: transport.WireDown, Channel: "ws", Binary: true, Data: []byte{1, 2, 3}})
+	_ = rec.Close()
+	data := buf.Bytes()
+	a, err := Read(bytes.NewReader(data[:len(data)-5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 1 || string(a.Frames[0].Data) != `{"type":"hello"}` {
+		t.Errorf("frames: %+v", a.Frames)
+	}
+	a, _ = Read(bytes.NewReader(data))
+	if len(a.Frames) != 2 || !a.Frames[1].Binary || !bytes.Equal(a.Frames[1].Data, []byte{1, 2, 3}) {
+		t.Errorf("frames: %+v", a.Frames)
+	}
+}
+
+func TestRecorderFlushesControlFrames(t *testing.T) {
+	var buf bytes.Buffer
+	rec, _ := NewRecorder(&buf, nil)
+	frames := func() int {
+		a, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(a.Frames)
+	}
+	// 未调用 Close：文本帧立即可见，音频帧在下一次刷新时随之写出
+	rec.Record(transport.WireFrame{Dir: transport.WireDown, Channel: "ws", Binary: true, Data: []byte{1}})
+	if n := frames(); n != 0 {
+		t.Errorf("%d frames after binary, want buffered", n)
+	}
+	rec.Record(transport.WireFrame{Dir: transport.WireDown, Channel: "ws", Data: []byte(`{"type":"tts","state":"stop"}`)})
+	if n := frames(); n != 2 {
+		t.Errorf("%d frames after text, want 2", n)
+	}
+
+	rec.flushed = time.Now().Add(-flushInterval)
+	rec.Record(transport.WireFrame{Dir: transport.WireDown, Channel: "ws", Binary: true, Data: []byte{2}})
+	if n := frames(); n != 3 {
+		t.Errorf("%d frames after flush interval, want 3", n)
+	}
+}
@@ -21,6 +21,7 @@ func main() {
 		Height: 768,
 		AssetServer: &assetserver.Options{ Assets: assets },
 		OnStartup: app.startup,
+		OnShutdown: app.shutdown,
 		Bind: []interface{}{ app },
 		// 自定义窗口设置
 		Frameless: true,